@@ -2,17 +2,29 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"order-back-end/internal/cache"
+	"order-back-end/internal/codec"
 	"order-back-end/internal/config"
 	hand "order-back-end/internal/handler"
+	"order-back-end/internal/health"
+	"order-back-end/internal/idempotency"
+	kfkcfg "order-back-end/internal/kafka/config"
 	consumer "order-back-end/internal/kafka/consumer"
+	"order-back-end/internal/kafka/dlq"
 	producer "order-back-end/internal/kafka/producer"
+	"order-back-end/internal/kafka/transport"
+	"order-back-end/internal/kafka/transport/memory"
+	"order-back-end/internal/lifecycle"
 	"order-back-end/internal/logger"
+	"order-back-end/internal/metrics"
 	"order-back-end/internal/postgres"
 	repo "order-back-end/internal/repository"
+	"order-back-end/internal/schemaregistry"
 	serv "order-back-end/internal/service"
+	"order-back-end/internal/validator"
 	"os"
 	"os/signal"
 	"syscall"
@@ -41,66 +53,181 @@ func main() {
 		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "postgres.New error", zap.Error(err))
 	}
 
-	err = postgres.Migrate(ctx, cfg.Postgres) // выполняем миграции
+	// управляет запуском и остановкой фоновых компонентов; пул закрывается последним
+	lc := lifecycle.New(ctx)
+	lc.OnStop("postgres", func(context.Context) error {
+		db.Close()
+		return nil
+	})
+
+	cachePolicy, err := cache.ParsePolicy(cfg.Cache.Policy) // выбираем стратегию вытеснения
 	if err != nil {
-		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "postgres.Migrate error", zap.Error(err))
+		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "cache.ParsePolicy error", zap.Error(err))
 	}
 
-	cacheIn := cache.NewCache(time.Duration(time.Minute*20), 40) // создаём кэш для хранения заказов
+	cacheIn := cache.NewCacheWithPolicy(lc.Context(), cfg.Cache.TTL, cfg.Cache.MaxSize, cachePolicy) // создаём кэш для хранения заказов
 
 	repository := repo.NewRepository(db) // создаём репозиторий для работы с базой
 
-	orders, err := repository.GetAllOrders(ctx) // получаем все заказы из базы
+	orderService := serv.NewOrderService(repository) // создаём сервис для работы с заказами
+
+	// проверки готовности: до завершения всех шагов запуска /readyz отвечает 503
+	checker := health.NewChecker(2 * time.Second)
+	migrated := health.NewFlag()
+	warmedUp := health.NewFlag()
+	checker.Register("postgres", db.Ping)
+	checker.Register("migrations", migrated.Check)
+	checker.Register("cache_warmup", warmedUp.Check)
+	checker.Register("kafka_consumers", health.NewFlag().Check)
+
+	router := gin.Default()             // создаём новый gin router
+	router.Use(metrics.GinMiddleware()) // латентность и статусы HTTP запросов
+	router.Use(cors.New(cors.Config{    // настраиваем cors для фронтенда
+		AllowOrigins:     []string{"http://localhost:8080"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", hand.HeaderIdempotencyKey},
+		AllowCredentials: true,
+	}))
+
+	httpHandler := hand.NewHandler(orderService, router, cacheIn) // создаём обработчик http запросов
+
+	httpHandler.RegisterRoutes() // регистрируем маршруты
+
+	tr, err := newTransport(cfg.Kafka) // kafka или in-memory брокер для локального запуска
 	if err != nil {
-		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "repository.GetAllOrders error", zap.Error(err))
+		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "newTransport error", zap.Error(err))
+	}
+	dlqTopic := cfg.Kafka.DLQTopic
+	if cfg.Kafka.Transport == transport.NameMemory {
+		dlqTopic = "" // DLQ работает только с настоящей Kafka
 	}
 
-	for _, orderElem := range orders { // наполняем кэш существующими заказами
-		cacheIn.Set(orderElem.OrderUID, orderElem)
+	deadLetters, err := dlq.New(cfg.Kafka.Brokers, dlqTopic) // dead-letter топик для отклонённых сообщений
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "dlq.New error", zap.Error(err))
+	}
+	lc.OnStop("dlq", func(context.Context) error {
+		deadLetters.Close()
+		return nil
+	})
+
+	decoders := codec.NewDecoders(cfg.Kafka.StrictDecoding) // JSON и Protobuf; Avro только с реестром схем
+	if cfg.Kafka.SchemaRegistry.URL != "" {
+		decoders.Register(codec.ContentTypeAvro, codec.NewAvro(schemaregistry.NewClient(cfg.Kafka.SchemaRegistry), cfg.Kafka.StrictDecoding))
 	}
 
-	go producer.StartProducer(ctx, cfg.Kafka.Brokers, cfg.Kafka.Topic) // запускаем продьюсера в отдельной горутине
+	rules, err := validator.New(cfg.Validation) // проверка заказов: правила полей из конфига, форматы и согласованность сумм
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "validator.New error", zap.Error(err))
+	}
 
-	go consumer.StartConsuming(ctx, cfg.Kafka.Brokers, cfg.Kafka.GroupID, cfg.Kafka.Topic, db, cacheIn)
+	consumers, err := consumer.NewPool(lc, cfg.Kafka, tr, repository, cacheIn, deadLetters, decoders, rules) // пул консьюмеров, запускается после прогрева кэша
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "consumer.NewPool error", zap.Error(err))
+	}
 
-	router := gin.Default()          // создаём новый gin router
-	router.Use(cors.New(cors.Config{ // настраиваем cors для фронтенда
-		AllowOrigins:     []string{"http://localhost:8080"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		AllowCredentials: true,
-	}))
+	ingestService := serv.NewIngestService(repository, rules, cacheIn) // приём заказов через HTTP тем же путём, что из Kafka
+	ingestHandler := hand.NewIngestHandler(router, ingestService, decoders, idempotency.NewStore(cfg.HTTP.IdempotencyTTL, cfg.HTTP.IdempotencyMaxKeys))
+	ingestHandler.RegisterRoutes()
 
-	orderService := serv.NewOrderService(repository) // создаём сервис для работы с заказами
+	adminHandler := hand.NewAdminHandler(router, cacheIn, deadLetters, consumers) // служебные ручки
+	adminHandler.RegisterRoutes()
 
-	httpHandler := hand.NewHandler(orderService, router, cacheIn) // создаём обработчик http запросов
+	healthHandler := hand.NewHealthHandler(router, checker) // liveness и readiness пробы
+	healthHandler.RegisterRoutes()
 
-	httpHandler.RegisterRoutes() // регистрируем маршруты
+	schemaHandler := hand.NewSchemaHandler(router, rules, cfg.Kafka.StrictDecoding) // JSON Schema заказа для продьюсеров
+	schemaHandler.RegisterRoutes()
+
+	metrics.Registry.MustRegister(
+		metrics.NewCacheCollector(cacheIn), // метрики кэша
+		metrics.NewPoolCollector(db),       // метрики пула соединений Postgres
+	)
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
 
 	srv := &http.Server{
 		Addr:    ":" + cfg.HTTP.Port,
 		Handler: router,
 	}
 
-	go func() { // запускаем http сервер в отдельной горутине
+	go func() { // запускаем http сервер до миграций, чтобы пробы отвечали с самого старта
 		fmt.Println("start http server on :8081")
-		if err := srv.ListenAndServe(); err != nil {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logger.GetLoggerFromCtx(ctx).Fatal(ctx, "http.ListenAndServe error", zap.Error(err))
 		}
 	}()
+	lc.OnStop("http", srv.Shutdown)
+
+	// ошибка миграций или прогрева завершает процесс, до этого /readyz отвечает pending
+	err = postgres.Migrate(ctx, cfg.Postgres) // выполняем миграции
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "postgres.Migrate error", zap.Error(err))
+	}
+	migrated.Done()
+
+	report, err := orderService.WarmUpCache(ctx, cacheIn, cfg.Cache.WarmUpChunk) // прогреваем кэш свежими заказами
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "orderService.WarmUpCache error", zap.Error(err))
+	}
+	warmedUp.Done()
+	logger.GetLoggerFromCtx(ctx).Info(ctx, "cache warmed up",
+		zap.Int("orders", report.Loaded),
+		zap.Int("chunks", report.Chunks),
+		zap.Duration("duration", report.Duration),
+	)
+
+	err = lc.Go("producer", func(ctx context.Context) error { // запускаем продьюсера в отдельной горутине
+		return producer.StartProducer(ctx, tr, cfg.Kafka.Brokers, cfg.Kafka.Topic)
+	})
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "lc.Go producer error", zap.Error(err))
+	}
+
+	if err := consumers.Start(ctx); err != nil { // запускаем консьюмеров
+		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "consumers.Start error", zap.Error(err))
+	}
+	checker.Register("kafka_consumers", consumers.Check)
 
 	signalCh := make(chan os.Signal, 1)
-	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
-	<-signalCh
+	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := <-signalCh; sig == syscall.SIGHUP; sig = <-signalCh { // SIGHUP перечитывает правила проверки заказов
+		reloadValidation(ctx, rules)
+	}
 
 	fmt.Println("shutdown server ...")
 
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	// останавливаем консьюмеров, продьюсера и очистку кэша, затем http сервер и пул соединений
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
 	defer cancel()
 
-	if err := srv.Shutdown(shutdownCtx); err != nil {
-		logger.GetLoggerFromCtx(ctx).Fatal(ctx, "srv.Shutdown error", zap.Error(err))
+	if err := lc.Shutdown(shutdownCtx); err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, "lifecycle.Shutdown error", zap.Error(err))
 	}
 
 	fmt.Println("server exit")
 }
+
+// reloadValidation перечитывает секцию validation конфига; при ошибке остаются прежние правила
+func reloadValidation(ctx context.Context, rules *validator.Validator) {
+	cfg, err := config.NewConfig()
+	if err == nil {
+		err = rules.Reload(cfg.Validation)
+	}
+	if err != nil {
+		logger.GetLoggerFromCtx(ctx).Error(ctx, "validation reload error", zap.Error(err))
+		return
+	}
+	logger.GetLoggerFromCtx(ctx).Info(ctx, "validation rules reloaded", zap.Int("rules", len(cfg.Validation.Rules)))
+}
+
+// newTransport выбирает транспорт сообщений по конфигу; in-memory брокеру хватает партиций на max_count консьюмеров
+func newTransport(cfg kfkcfg.Config) (transport.Transport, error) {
+	switch cfg.Transport {
+	case "", transport.NameKafka:
+		return transport.Kafka{}, nil
+	case transport.NameMemory:
+		return memory.NewBroker(cfg.Consumer.MaxCount), nil
+	default:
+		return nil, fmt.Errorf("unknown kafka transport %q", cfg.Transport)
+	}
+}
@@ -1,7 +1,10 @@
 package cache
 
 import (
+	"context"
 	"order-back-end/internal/model"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 )
@@ -10,6 +13,29 @@ type Cache interface {
 	Set(id string, o model.OrderInfo)
 	Get(orderUID string) (model.OrderInfo, bool)
 	Delete(orderUID string)
+	GetByTrack(trackNumber string) ([]model.OrderInfo, bool)
+	SetTrack(trackNumber string, orders []model.OrderInfo)
+	Len() int
+	Capacity() int
+	Stats() Stats
+}
+
+// Stats счётчики эффективности кэша с момента создания
+type Stats struct {
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Expirations uint64 `json:"expirations"`
+	Evictions   uint64 `json:"evictions"`
+	Size        int    `json:"size"`
+	Capacity    int    `json:"capacity"`
+}
+
+// Config настройки кэша заказов
+type Config struct {
+	TTL         time.Duration `yaml:"ttl" env:"CACHE_TTL" env-default:"20m"`
+	MaxSize     int           `yaml:"max_size" env:"CACHE_MAX_SIZE" env-default:"40"`
+	WarmUpChunk int           `yaml:"warmup_chunk" env:"CACHE_WARMUP_CHUNK" env-default:"100"`
+	Policy      string        `yaml:"policy" env:"CACHE_POLICY" env-default:"lru"`
 }
 
 type cacheItem struct {
@@ -18,24 +44,38 @@ type cacheItem struct {
 }
 
 type OrderCache struct {
-	mu      sync.RWMutex
-	orders  map[string]cacheItem
-	ttl     time.Duration
-	maxSize int
+	mu     sync.RWMutex
+	orders map[string]cacheItem
+	tracks map[string]map[string]struct{} // вторичный индекс track_number -> order_uid
+	// complete track_number, все заказы которых лежат в кэше; сбрасывается при любом изменении заказов трека
+	complete map[string]struct{}
+	policy   evictionPolicy
+	ttl      time.Duration
+	maxSize  int
+	stats    Stats // счётчики меняются под mu.Lock
 }
 
 var _ Cache = (*OrderCache)(nil) // На этапе компиляции будет проверка удовлетворяет ли OrderCache интерфейсу
 
-// NewCache создаём кэш с TTL и ограничением по размеру
+// NewCache создаём кэш с TTL и ограничением по размеру, вытеснение по LRU
 func NewCache(ttl time.Duration, maxSize int) Cache {
+	return NewCacheWithPolicy(context.Background(), ttl, maxSize, PolicyLRU)
+}
+
+// NewCacheWithPolicy создаём кэш с TTL, ограничением по размеру и выбранной стратегией вытеснения;
+// фоновая очистка работает до отмены ctx
+func NewCacheWithPolicy(ctx context.Context, ttl time.Duration, maxSize int, policy Policy) Cache {
 	c := &OrderCache{
-		orders:  make(map[string]cacheItem),
-		ttl:     ttl,
-		maxSize: maxSize,
+		orders:   make(map[string]cacheItem),
+		tracks:   make(map[string]map[string]struct{}),
+		complete: make(map[string]struct{}),
+		policy:   newEvictionPolicy(policy),
+		ttl:      ttl,
+		maxSize:  maxSize,
 	}
 
 	// запускаем фоновую очистку
-	go c.сleanup()
+	go c.сleanup(ctx)
 
 	return c
 }
@@ -44,30 +84,80 @@ func NewCache(ttl time.Duration, maxSize int) Cache {
 func (c *OrderCache) Set(id string, o model.OrderInfo) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.setLocked(id, o)
+}
+
+// SetTrack кладёт в кэш все заказы трека, прочитанные из базы, и отмечает трек полным:
+// GetByTrack отдаёт его из кэша, пока ни один из заказов не изменится и не будет вытеснен
+func (c *OrderCache) SetTrack(trackNumber string, orders []model.OrderInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, o := range orders {
+		c.setLocked(o.OrderUID, o)
+	}
+	// трек больше кэша: часть заказов вытеснена его же записью
+	for _, o := range orders {
+		if _, ok := c.orders[o.OrderUID]; !ok {
+			return
+		}
+	}
+	if len(c.tracks[trackNumber]) == len(orders) {
+		c.complete[trackNumber] = struct{}{}
+	}
+}
 
-	// если кэш переполнен → удалим случайный элемент
-	if c.maxSize > 0 && len(c.orders) >= c.maxSize {
-		for k := range c.orders {
-			delete(c.orders, k)
-			break
+// setLocked добавляет или обновляет заказ, вызывается под mu.Lock
+func (c *OrderCache) setLocked(id string, o model.OrderInfo) {
+	if old, exists := c.orders[id]; exists {
+		c.unindexLocked(id, old.value)
+		c.policy.touch(id)
+	} else {
+		// если кэш переполнен → вытесняем элемент по стратегии
+		if c.maxSize > 0 && len(c.orders) >= c.maxSize {
+			if victim, ok := c.policy.victim(); ok {
+				c.removeLocked(victim)
+				c.stats.Evictions++
+			}
 		}
+		c.policy.add(id)
 	}
 
 	c.orders[id] = cacheItem{
 		value:      o,
 		expiration: time.Now().Add(c.ttl).UnixNano(),
 	}
+	for _, track := range trackNumbers(o) {
+		// в трек мог добавиться заказ, которого нет в кэше полного трека
+		delete(c.complete, track)
+		ids, ok := c.tracks[track]
+		if !ok {
+			ids = make(map[string]struct{})
+			c.tracks[track] = ids
+		}
+		ids[id] = struct{}{}
+	}
 }
 
-// Get возвращает заказ, если он ещё валиден
+// Get возвращает заказ, если он ещё валиден, и отмечает обращение для стратегии вытеснения
 func (c *OrderCache) Get(orderUID string) (model.OrderInfo, bool) {
-	c.mu.RLock()
-	defer c.mu.RUnlock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
 	item, ok := c.orders[orderUID]
-	if !ok || (item.expiration > 0 && time.Now().UnixNano() > item.expiration) {
+	if !ok {
+		c.stats.Misses++
+		return model.OrderInfo{}, false
+	}
+	if item.expiration > 0 && time.Now().UnixNano() > item.expiration {
+		// не ждём фоновую очистку: просроченный заказ всё равно больше не отдадим
+		c.removeLocked(orderUID)
+		c.stats.Expirations++
+		c.stats.Misses++
 		return model.OrderInfo{}, false
 	}
+	c.stats.Hits++
+	c.policy.touch(orderUID)
 	return item.value, true
 }
 
@@ -75,7 +165,113 @@ func (c *OrderCache) Get(orderUID string) (model.OrderInfo, bool) {
 func (c *OrderCache) Delete(orderUID string) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
+	c.removeLocked(orderUID)
+}
+
+// GetByTrack возвращает заказы с данным track_number заказа или товара, только если в кэше лежат
+// все заказы трека, положенные через SetTrack; иначе промах, и трек нужно прочитать из базы
+func (c *OrderCache) GetByTrack(trackNumber string) ([]model.OrderInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.complete[trackNumber]; !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	now := time.Now().UnixNano()
+	ids := c.tracks[trackNumber]
+	result := make([]model.OrderInfo, 0, len(ids))
+	for id := range ids {
+		item := c.orders[id]
+		if item.expiration > 0 && now > item.expiration {
+			// часть трека устарела: удаляем её, трек перестаёт быть полным
+			c.removeLocked(id)
+			c.stats.Expirations++
+			c.stats.Misses++
+			return nil, false
+		}
+		result = append(result, item.value)
+	}
+	for id := range ids {
+		c.policy.touch(id)
+	}
+	// порядок как у выборки из базы: сначала новые
+	slices.SortFunc(result, func(a, b model.OrderInfo) int {
+		if n := b.DateCreated.Compare(a.DateCreated); n != 0 {
+			return n
+		}
+		return strings.Compare(b.OrderUID, a.OrderUID)
+	})
+	c.stats.Hits++
+	return result, true
+}
+
+// Len количество заказов в кэше, включая ещё не удалённые просроченные
+func (c *OrderCache) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.orders)
+}
+
+// Capacity максимальный размер кэша, 0 - без ограничения
+func (c *OrderCache) Capacity() int {
+	return c.maxSize
+}
+
+// Stats снимок счётчиков кэша
+func (c *OrderCache) Stats() Stats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	st := c.stats
+	st.Size = len(c.orders)
+	st.Capacity = c.maxSize
+	return st
+}
+
+// removeLocked удаляет заказ, его записи во вторичном индексе и в стратегии, вызывается под mu.Lock
+func (c *OrderCache) removeLocked(orderUID string) {
+	item, ok := c.orders[orderUID]
+	if !ok {
+		return
+	}
 	delete(c.orders, orderUID)
+	c.policy.remove(orderUID)
+	c.unindexLocked(orderUID, item.value)
+}
+
+// unindexLocked удаляет заказ из вторичного индекса, вызывается под mu.Lock
+func (c *OrderCache) unindexLocked(orderUID string, o model.OrderInfo) {
+	for _, track := range trackNumbers(o) {
+		delete(c.complete, track)
+		ids := c.tracks[track]
+		delete(ids, orderUID)
+		if len(ids) == 0 {
+			delete(c.tracks, track)
+		}
+	}
+}
+
+// trackNumbers уникальные track_number заказа и его товаров
+func trackNumbers(o model.OrderInfo) []string {
+	tracks := make([]string, 0, len(o.Items)+1)
+	seen := make(map[string]struct{}, len(o.Items)+1)
+	add := func(t string) {
+		if t == "" {
+			return
+		}
+		if _, ok := seen[t]; ok {
+			return
+		}
+		seen[t] = struct{}{}
+		tracks = append(tracks, t)
+	}
+	add(o.TrackNumber)
+	for _, it := range o.Items {
+		add(it.TrackNumber)
+	}
+	return tracks
 }
 
 func (c *OrderCache) cleanupExpired() {
@@ -83,16 +279,23 @@ func (c *OrderCache) cleanupExpired() {
 	c.mu.Lock()
 	for k, v := range c.orders {
 		if now > v.expiration {
-			delete(c.orders, k)
+			c.removeLocked(k)
+			c.stats.Expirations++
 		}
 	}
 	c.mu.Unlock()
 }
 
-// Сleanup периодически удаляет устаревшие элементы
-func (c *OrderCache) сleanup() {
+// Сleanup периодически удаляет устаревшие элементы до отмены ctx
+func (c *OrderCache) сleanup(ctx context.Context) {
 	ticker := time.NewTicker(c.ttl)
-	for range ticker.C {
-		c.cleanupExpired()
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.cleanupExpired()
+		}
 	}
 }
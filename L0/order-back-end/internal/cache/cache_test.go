@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"github.com/stretchr/testify/require"
 	"order-back-end/internal/model"
 	"testing"
@@ -64,3 +65,181 @@ func TestOrderCacheCleanupExpired(t *testing.T) {
 	_, ok = c.Get("123")
 	require.False(t, ok, "expected order to be expired and cleaned up")
 }
+
+func TestOrderCacheGetByTrack(t *testing.T) {
+	c := NewCache(1*time.Second, 10)
+
+	first := model.OrderInfo{OrderUID: "111", TrackNumber: "TRACK1"}
+	second := model.OrderInfo{
+		OrderUID:    "222",
+		TrackNumber: "TRACK2",
+		Items:       []model.Item{{TrackNumber: "TRACK1"}},
+	}
+
+	// заказы, положенные по одному, не дают полного трека
+	c.Set(first.OrderUID, first)
+	_, ok := c.GetByTrack("TRACK1")
+	require.False(t, ok, "a track is served from cache only when all its orders are there")
+
+	c.SetTrack("TRACK1", []model.OrderInfo{first, second})
+	got, ok := c.GetByTrack("TRACK1")
+	require.True(t, ok, "expected orders by order and item track number")
+	require.Len(t, got, 2)
+
+	// удаление заказа трека сбрасывает полноту, частичный ответ не отдаётся
+	c.Delete("111")
+	_, ok = c.GetByTrack("TRACK1")
+	require.False(t, ok)
+
+	c.SetTrack("TRACK1", []model.OrderInfo{first, second})
+	// новый заказ с этим треком мог быть записан в базу: трек нужно перечитать
+	c.Set("333", model.OrderInfo{OrderUID: "333", TrackNumber: "TRACK1"})
+	_, ok = c.GetByTrack("TRACK1")
+	require.False(t, ok)
+
+	// обновление заказа убирает старые записи индекса
+	c.SetTrack("TRACK3", []model.OrderInfo{{OrderUID: "222", TrackNumber: "TRACK3"}})
+	got, ok = c.GetByTrack("TRACK3")
+	require.True(t, ok)
+	require.Len(t, got, 1)
+	_, ok = c.GetByTrack("TRACK1")
+	require.False(t, ok, "expected stale index entry to be removed")
+}
+
+func TestOrderCacheGetByTrackEviction(t *testing.T) {
+	c := NewCache(1*time.Second, 2)
+	now := time.Now()
+	orders := []model.OrderInfo{
+		{OrderUID: "111", TrackNumber: "TRACK", DateCreated: now.Add(-time.Hour)},
+		{OrderUID: "222", TrackNumber: "TRACK", DateCreated: now},
+	}
+
+	c.SetTrack("TRACK", orders)
+	got, ok := c.GetByTrack("TRACK")
+	require.True(t, ok)
+	require.Equal(t, []string{"222", "111"}, []string{got[0].OrderUID, got[1].OrderUID}, "newest first, as from the database")
+
+	// вытеснение одного заказа трека
+	c.Set("333", model.OrderInfo{OrderUID: "333"})
+	_, ok = c.GetByTrack("TRACK")
+	require.False(t, ok)
+
+	// трек больше кэша не отмечается полным
+	c.SetTrack("BIG", []model.OrderInfo{
+		{OrderUID: "a", TrackNumber: "BIG"}, {OrderUID: "b", TrackNumber: "BIG"}, {OrderUID: "c", TrackNumber: "BIG"},
+	})
+	_, ok = c.GetByTrack("BIG")
+	require.False(t, ok)
+}
+
+func TestOrderCacheLRUGetPromotes(t *testing.T) {
+	c := NewCacheWithPolicy(context.Background(), 1*time.Second, 2, PolicyLRU)
+
+	c.Set("111", model.OrderInfo{OrderUID: "111"})
+	c.Set("222", model.OrderInfo{OrderUID: "222"})
+
+	// обращение к 111 делает его самым свежим, вытеснен должен быть 222
+	_, ok := c.Get("111")
+	require.True(t, ok)
+
+	c.Set("333", model.OrderInfo{OrderUID: "333"})
+
+	_, ok = c.Get("111")
+	require.True(t, ok, "expected recently used order to stay in cache")
+	_, ok = c.Get("222")
+	require.False(t, ok, "expected least recently used order to be evicted")
+	_, ok = c.Get("333")
+	require.True(t, ok)
+}
+
+func TestOrderCacheLFUEvictsLeastFrequent(t *testing.T) {
+	c := NewCacheWithPolicy(context.Background(), 1*time.Second, 2, PolicyLFU)
+
+	c.Set("111", model.OrderInfo{OrderUID: "111"})
+	c.Set("222", model.OrderInfo{OrderUID: "222"})
+
+	for i := 0; i < 3; i++ {
+		c.Get("111")
+	}
+	c.Get("222")
+
+	c.Set("333", model.OrderInfo{OrderUID: "333"})
+
+	_, ok := c.Get("111")
+	require.True(t, ok, "expected frequently used order to stay in cache")
+	_, ok = c.Get("222")
+	require.False(t, ok, "expected least frequently used order to be evicted")
+
+	// новый элемент с одним обращением вытесняется раньше частого
+	c.Set("444", model.OrderInfo{OrderUID: "444"})
+	_, ok = c.Get("333")
+	require.False(t, ok)
+	_, ok = c.Get("111")
+	require.True(t, ok)
+}
+
+func TestOrderCacheEvictionKeepsTTL(t *testing.T) {
+	c := NewCacheWithPolicy(context.Background(), time.Hour, 10, PolicyLRU).(*OrderCache)
+
+	c.Set("111", model.OrderInfo{OrderUID: "111"})
+
+	// имитируем истёкший TTL: обращение не должно его продлевать
+	c.mu.Lock()
+	item := c.orders["111"]
+	item.expiration = time.Now().Add(-time.Second).UnixNano()
+	c.orders["111"] = item
+	c.mu.Unlock()
+
+	_, ok := c.Get("111")
+	require.False(t, ok, "expected expired order not to be returned")
+
+	c.cleanupExpired()
+	require.Equal(t, 0, c.Len())
+	_, ok = c.policy.victim()
+	require.False(t, ok, "expected expired order to be removed from eviction policy")
+}
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("")
+	require.NoError(t, err)
+	require.Equal(t, PolicyLRU, p)
+
+	p, err = ParsePolicy("lfu")
+	require.NoError(t, err)
+	require.Equal(t, PolicyLFU, p)
+
+	_, err = ParsePolicy("random")
+	require.Error(t, err)
+}
+
+func TestOrderCacheStats(t *testing.T) {
+	c := NewCache(time.Hour, 1)
+
+	c.Set("111", model.OrderInfo{OrderUID: "111"})
+	c.Get("111")
+	c.Get("222")
+	c.Set("333", model.OrderInfo{OrderUID: "333"}) // вытесняет 111
+
+	st := c.Stats()
+	require.Equal(t, uint64(1), st.Hits)
+	require.Equal(t, uint64(1), st.Misses)
+	require.Equal(t, uint64(1), st.Evictions)
+	require.Equal(t, uint64(0), st.Expirations)
+	require.Equal(t, 1, st.Size)
+	require.Equal(t, 1, st.Capacity)
+
+	oc := c.(*OrderCache)
+	oc.mu.Lock()
+	item := oc.orders["333"]
+	item.expiration = time.Now().Add(-time.Second).UnixNano()
+	oc.orders["333"] = item
+	oc.mu.Unlock()
+
+	_, ok := c.Get("333")
+	require.False(t, ok)
+
+	st = c.Stats()
+	require.Equal(t, uint64(1), st.Expirations)
+	require.Equal(t, uint64(2), st.Misses)
+	require.Equal(t, 0, st.Size)
+}
@@ -5,6 +5,7 @@
 package mock_cache
 
 import (
+	cache "order-back-end/internal/cache"
 	model "order-back-end/internal/model"
 	reflect "reflect"
 
@@ -34,6 +35,20 @@ func (m *MockCache) EXPECT() *MockCacheMockRecorder {
 	return m.recorder
 }
 
+// Capacity mocks base method.
+func (m *MockCache) Capacity() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Capacity")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Capacity indicates an expected call of Capacity.
+func (mr *MockCacheMockRecorder) Capacity() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Capacity", reflect.TypeOf((*MockCache)(nil).Capacity))
+}
+
 // Delete mocks base method.
 func (m *MockCache) Delete(orderUID string) {
 	m.ctrl.T.Helper()
@@ -61,6 +76,35 @@ func (mr *MockCacheMockRecorder) Get(orderUID interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockCache)(nil).Get), orderUID)
 }
 
+// GetByTrack mocks base method.
+func (m *MockCache) GetByTrack(trackNumber string) ([]model.OrderInfo, bool) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetByTrack", trackNumber)
+	ret0, _ := ret[0].([]model.OrderInfo)
+	ret1, _ := ret[1].(bool)
+	return ret0, ret1
+}
+
+// GetByTrack indicates an expected call of GetByTrack.
+func (mr *MockCacheMockRecorder) GetByTrack(trackNumber interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetByTrack", reflect.TypeOf((*MockCache)(nil).GetByTrack), trackNumber)
+}
+
+// Len mocks base method.
+func (m *MockCache) Len() int {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Len")
+	ret0, _ := ret[0].(int)
+	return ret0
+}
+
+// Len indicates an expected call of Len.
+func (mr *MockCacheMockRecorder) Len() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Len", reflect.TypeOf((*MockCache)(nil).Len))
+}
+
 // Set mocks base method.
 func (m *MockCache) Set(id string, o model.OrderInfo) {
 	m.ctrl.T.Helper()
@@ -72,3 +116,29 @@ func (mr *MockCacheMockRecorder) Set(id, o interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Set", reflect.TypeOf((*MockCache)(nil).Set), id, o)
 }
+
+// SetTrack mocks base method.
+func (m *MockCache) SetTrack(trackNumber string, orders []model.OrderInfo) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetTrack", trackNumber, orders)
+}
+
+// SetTrack indicates an expected call of SetTrack.
+func (mr *MockCacheMockRecorder) SetTrack(trackNumber, orders interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetTrack", reflect.TypeOf((*MockCache)(nil).SetTrack), trackNumber, orders)
+}
+
+// Stats mocks base method.
+func (m *MockCache) Stats() cache.Stats {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Stats")
+	ret0, _ := ret[0].(cache.Stats)
+	return ret0
+}
+
+// Stats indicates an expected call of Stats.
+func (mr *MockCacheMockRecorder) Stats() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Stats", reflect.TypeOf((*MockCache)(nil).Stats))
+}
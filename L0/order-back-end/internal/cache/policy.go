@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"container/list"
+	"fmt"
+)
+
+// Policy стратегия вытеснения при переполнении кэша
+type Policy string
+
+const (
+	// PolicyLRU вытесняет заказ, к которому дольше всего не обращались
+	PolicyLRU Policy = "lru"
+	// PolicyLFU вытесняет заказ с наименьшим числом обращений, при равенстве - самый давний
+	PolicyLFU Policy = "lfu"
+)
+
+// ParsePolicy разбирает название стратегии из конфига, пустая строка означает LRU
+func ParsePolicy(s string) (Policy, error) {
+	switch Policy(s) {
+	case "", PolicyLRU:
+		return PolicyLRU, nil
+	case PolicyLFU:
+		return PolicyLFU, nil
+	default:
+		return "", fmt.Errorf("unknown cache eviction policy %q", s)
+	}
+}
+
+// evictionPolicy учитывает обращения к ключам и выбирает кандидата на вытеснение.
+// Не потокобезопасна, вызывается под mu кэша.
+type evictionPolicy interface {
+	add(key string)
+	touch(key string)
+	remove(key string)
+	victim() (string, bool)
+}
+
+// newEvictionPolicy создаёт реализацию по названию
+func newEvictionPolicy(p Policy) evictionPolicy {
+	if p == PolicyLFU {
+		return newLFU()
+	}
+	return newLRU()
+}
+
+// lruPolicy двусвязный список: в начале самые свежие ключи, в конце кандидат на вытеснение
+type lruPolicy struct {
+	order *list.List
+	elems map[string]*list.Element
+}
+
+func newLRU() *lruPolicy {
+	return &lruPolicy{
+		order: list.New(),
+		elems: make(map[string]*list.Element),
+	}
+}
+
+func (p *lruPolicy) add(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.elems[key] = p.order.PushFront(key)
+}
+
+func (p *lruPolicy) touch(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy) remove(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *lruPolicy) victim() (string, bool) {
+	e := p.order.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+// lfuEntry ключ и текущее число обращений
+type lfuEntry struct {
+	key  string
+	freq int
+}
+
+// lfuPolicy O(1) LFU: для каждой частоты свой LRU-список, minFreq указывает на список с кандидатом
+type lfuPolicy struct {
+	elems   map[string]*list.Element
+	freqs   map[int]*list.List
+	minFreq int
+}
+
+func newLFU() *lfuPolicy {
+	return &lfuPolicy{
+		elems: make(map[string]*list.Element),
+		freqs: make(map[int]*list.List),
+	}
+}
+
+func (p *lfuPolicy) add(key string) {
+	if _, ok := p.elems[key]; ok {
+		p.touch(key)
+		return
+	}
+	p.elems[key] = p.bucket(1).PushFront(&lfuEntry{key: key, freq: 1})
+	p.minFreq = 1
+}
+
+func (p *lfuPolicy) touch(key string) {
+	e, ok := p.elems[key]
+	if !ok {
+		return
+	}
+	entry := e.Value.(*lfuEntry)
+	p.unlink(e, entry.freq)
+	if p.minFreq == entry.freq && p.freqs[entry.freq] == nil {
+		p.minFreq++
+	}
+	entry.freq++
+	p.elems[key] = p.bucket(entry.freq).PushFront(entry)
+}
+
+func (p *lfuPolicy) remove(key string) {
+	e, ok := p.elems[key]
+	if !ok {
+		return
+	}
+	entry := e.Value.(*lfuEntry)
+	p.unlink(e, entry.freq)
+	delete(p.elems, key)
+	if p.minFreq == entry.freq && p.freqs[entry.freq] == nil {
+		p.recalcMinFreq()
+	}
+}
+
+func (p *lfuPolicy) victim() (string, bool) {
+	l := p.freqs[p.minFreq]
+	if l == nil || l.Back() == nil {
+		return "", false
+	}
+	return l.Back().Value.(*lfuEntry).key, true
+}
+
+// bucket список ключей с частотой freq, создаётся по необходимости
+func (p *lfuPolicy) bucket(freq int) *list.List {
+	l, ok := p.freqs[freq]
+	if !ok {
+		l = list.New()
+		p.freqs[freq] = l
+	}
+	return l
+}
+
+// unlink убирает элемент из списка частоты и удаляет опустевший список
+func (p *lfuPolicy) unlink(e *list.Element, freq int) {
+	l := p.freqs[freq]
+	l.Remove(e)
+	if l.Len() == 0 {
+		delete(p.freqs, freq)
+	}
+}
+
+// recalcMinFreq ищет минимальную частоту после удаления, вызывается только при Delete и очистке TTL
+func (p *lfuPolicy) recalcMinFreq() {
+	p.minFreq = 0
+	for freq := range p.freqs {
+		if p.minFreq == 0 || freq < p.minFreq {
+			p.minFreq = freq
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package codec
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"order-back-end/internal/model"
+	"order-back-end/internal/schemaregistry"
+	"sync"
+
+	"github.com/hamba/avro/v2"
+)
+
+// orderAvroSchema схема чтения заказа в Avro
+//
+//go:embed order.avsc
+var orderAvroSchema string
+
+// SchemaSource источник схем по идентификатору из wire format; реализуется *schemaregistry.Client
+type SchemaSource interface {
+	SchemaByID(ctx context.Context, id int) (*schemaregistry.Schema, error)
+}
+
+// Avro декодер заказа в Avro, записанного в Confluent wire format. Схема записи берётся из реестра
+// по идентификатору в сообщении и сводится со схемой чтения сервиса по правилам эволюции Avro:
+// новые поля отбрасываются, отсутствующие заполняются значениями по умолчанию
+type Avro struct {
+	registry SchemaSource
+	reader   avro.Schema
+	api      avro.API
+	strict   bool
+
+	mu       sync.RWMutex
+	resolved map[int]avro.Schema // сведённые схемы по идентификатору схемы записи
+}
+
+// NewAvro создаёт декодер, который берёт схемы записи из registry; strict отклоняет схемы записи
+// с полями, которых нет в схеме чтения
+func NewAvro(registry SchemaSource, strict bool) *Avro {
+	return &Avro{
+		registry: registry,
+		reader:   avro.MustParse(orderAvroSchema),
+		api:      avro.Config{TagKey: "json"}.Freeze(),
+		strict:   strict,
+		resolved: make(map[int]avro.Schema),
+	}
+}
+
+// Decode разбирает сообщение в заказ
+func (a *Avro) Decode(ctx context.Context, data []byte, order *model.OrderInfo) error {
+	id, payload, err := schemaregistry.ParseWireFormat(data)
+	if err != nil {
+		return err
+	}
+	schema, err := a.schema(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := a.api.Unmarshal(schema, payload, order); err != nil {
+		return fmt.Errorf("invalid avro message: %w", err)
+	}
+	return nil
+}
+
+// schema схема записи id, сведённая со схемой чтения
+func (a *Avro) schema(ctx context.Context, id int) (avro.Schema, error) {
+	a.mu.RLock()
+	schema, ok := a.resolved[id]
+	a.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	writer, err := a.registry.SchemaByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if writer.Type != schemaregistry.TypeAvro {
+		return nil, fmt.Errorf("%w: schema %d is %s, not %s", ErrIncompatibleSchema, id, writer.Type, schemaregistry.TypeAvro)
+	}
+	// у каждой схемы записи свой кэш имён, иначе разные версии одного record'а конфликтуют
+	parsed, err := avro.ParseWithCache(writer.Schema, "", &avro.SchemaCache{})
+	if err != nil {
+		return nil, fmt.Errorf("%w: schema %d: %w", ErrIncompatibleSchema, id, err)
+	}
+	if a.strict {
+		if err := checkStrictAvro(a.reader, parsed, ""); err != nil {
+			return nil, fmt.Errorf("schema %d: %w", id, err)
+		}
+	}
+	schema, err = avro.NewSchemaCompatibility().Resolve(a.reader, parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%w: schema %d: %w", ErrIncompatibleSchema, id, err)
+	}
+
+	a.mu.Lock()
+	a.resolved[id] = schema
+	a.mu.Unlock()
+	return schema, nil
+}
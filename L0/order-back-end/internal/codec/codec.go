@@ -0,0 +1,82 @@
+package codec
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"order-back-end/internal/model"
+	"strings"
+)
+
+// HeaderContentType заголовок сообщения с форматом заказа; без заголовка сообщение считается JSON
+const HeaderContentType = "content-type"
+
+// Форматы заказа в заголовке content-type
+const (
+	ContentTypeJSON     = "application/json"
+	ContentTypeAvro     = "application/avro"
+	ContentTypeProtobuf = "application/x-protobuf"
+)
+
+// aliases другие распространённые названия тех же форматов
+var aliases = map[string]string{
+	"":                                   ContentTypeJSON,
+	"text/json":                          ContentTypeJSON,
+	"avro/binary":                        ContentTypeAvro,
+	"application/vnd.apache.avro+binary": ContentTypeAvro,
+	"application/protobuf":               ContentTypeProtobuf,
+	"application/vnd.google.protobuf":    ContentTypeProtobuf,
+}
+
+var (
+	// ErrUnsupportedContentType для формата сообщения нет декодера
+	ErrUnsupportedContentType = errors.New("unsupported content type")
+	// ErrIncompatibleSchema схема, которой записано сообщение, несовместима со схемой заказа сервиса
+	ErrIncompatibleSchema = errors.New("incompatible schema")
+)
+
+// Decoder разбирает сообщение одного формата в заказ
+type Decoder interface {
+	Decode(ctx context.Context, data []byte, order *model.OrderInfo) error
+}
+
+// Decoders декодеры по форматам сообщений
+type Decoders struct {
+	decoders map[string]Decoder
+}
+
+// NewDecoders создаёт набор с декодерами JSON и Protobuf; Avro требует реестра схем и регистрируется отдельно.
+// strict включает строгий разбор: неизвестные поля и несовпадение типов отклоняют сообщение
+func NewDecoders(strict bool) *Decoders {
+	d := &Decoders{decoders: make(map[string]Decoder)}
+	d.Register(ContentTypeJSON, JSON{Strict: strict})
+	d.Register(ContentTypeProtobuf, NewProtobuf(strict))
+	return d
+}
+
+// Register добавляет или заменяет декодер формата contentType
+func (d *Decoders) Register(contentType string, decoder Decoder) {
+	d.decoders[normalize(contentType)] = decoder
+}
+
+// Decode разбирает сообщение декодером, выбранным по contentType
+func (d *Decoders) Decode(ctx context.Context, contentType string, data []byte, order *model.OrderInfo) error {
+	decoder, ok := d.decoders[normalize(contentType)]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedContentType, contentType)
+	}
+	return decoder.Decode(ctx, data, order)
+}
+
+// normalize приводит content-type к каноническому виду без параметров
+func normalize(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if canonical, ok := aliases[mediaType]; ok {
+		return canonical
+	}
+	return mediaType
+}
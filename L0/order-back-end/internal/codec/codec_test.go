@@ -0,0 +1,503 @@
+package codec
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"order-back-end/internal/model"
+	"order-back-end/internal/schemaregistry"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hamba/avro/v2"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func testOrder() model.OrderInfo {
+	return model.OrderInfo{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Entry:       "WBIL",
+		Delivery: model.Delivery{
+			Name: "Test Testov", Phone: "+9720000000", Zip: "2639809", City: "Kiryat Mozkin",
+			Address: "Ploshad Mira 15", Region: "Kraiot", Email: "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction: "b563feb7b2b84b6test", Currency: "USD", Provider: "wbpay", Amount: 1817,
+			PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 1500, GoodsTotal: 317, CustomFee: 7,
+		},
+		Items: []model.Item{{
+			ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Price: 453, RID: "ab4219087a764ae0btest",
+			Name: "Mascaras", Sale: 30, Size: "0", TotalPrice: 317, NmID: 2389212, Brand: "Vivienne Sabo", Status: 202,
+		}},
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		ShardKey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+	}
+}
+
+func TestDecodersSelectByContentType(t *testing.T) {
+	order := testOrder()
+	value, err := json.Marshal(order)
+	require.NoError(t, err)
+
+	decoders := NewDecoders(false)
+	for _, contentType := range []string{"", "application/json", "application/json; charset=utf-8", "Application/JSON"} {
+		var got model.OrderInfo
+		require.NoError(t, decoders.Decode(context.Background(), contentType, value, &got), contentType)
+		require.Equal(t, order, got)
+	}
+
+	var got model.OrderInfo
+	err = decoders.Decode(context.Background(), "application/xml", value, &got)
+	require.ErrorIs(t, err, ErrUnsupportedContentType)
+
+	// Avro без реестра схем не регистрируется
+	err = decoders.Decode(context.Background(), ContentTypeAvro, value, &got)
+	require.ErrorIs(t, err, ErrUnsupportedContentType)
+
+	err = decoders.Decode(context.Background(), "", []byte("{"), &got)
+	require.ErrorContains(t, err, "invalid JSON")
+}
+
+// stubRegistry поднимает заглушку реестра, отдающую схемы по идентификаторам
+func stubRegistry(t *testing.T, schemas map[int]string) *schemaregistry.Client {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/schemas/ids/"))
+		schema, ok := schemas[id]
+		switch {
+		case err != nil || !ok:
+			w.WriteHeader(http.StatusNotFound)
+		case id >= 500:
+			w.WriteHeader(id)
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"schema": schema})
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return schemaregistry.NewClient(schemaregistry.Config{URL: srv.URL, Timeout: time.Second})
+}
+
+// avroSchema схема заказа, изменённая edit: так получаются старые и новые версии схемы продьюсеров
+func avroSchema(t *testing.T, edit func(order map[string]any)) string {
+	t.Helper()
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal([]byte(orderAvroSchema), &schema))
+	edit(schema)
+	data, err := json.Marshal(schema)
+	require.NoError(t, err)
+	return string(data)
+}
+
+// withoutField убирает поле из record'а
+func withoutField(record map[string]any, name string) {
+	record["fields"] = slices.DeleteFunc(record["fields"].([]any), func(f any) bool {
+		return f.(map[string]any)["name"] == name
+	})
+}
+
+// nested record вложенного поля
+func nested(record map[string]any, name string) map[string]any {
+	for _, f := range record["fields"].([]any) {
+		if field := f.(map[string]any); field["name"] == name {
+			return field["type"].(map[string]any)
+		}
+	}
+	panic("no field " + name)
+}
+
+// avroMessage кодирует заказ схемой записи schema в wire format с идентификатором id
+func avroMessage(t *testing.T, id int, schema string, value any) []byte {
+	t.Helper()
+	payload, err := avro.Config{TagKey: "json"}.Freeze().Marshal(avro.MustParse(schema), value)
+	require.NoError(t, err)
+	return schemaregistry.AppendWireFormat(nil, id, payload)
+}
+
+func TestAvroSchemaEvolution(t *testing.T) {
+	// v1: ещё нет региона доставки, пошлины и oof_shard
+	v1 := avroSchema(t, func(order map[string]any) {
+		withoutField(order, "oof_shard")
+		withoutField(nested(order, "delivery"), "region")
+		withoutField(nested(order, "payment"), "custom_fee")
+	})
+	// v3: добавлен комментарий к заказу, о котором сервис не знает
+	v3 := avroSchema(t, func(order map[string]any) {
+		order["fields"] = append(order["fields"].([]any), map[string]any{"name": "comment", "type": "string", "default": ""})
+	})
+	// несовместимая: order_uid стал числом
+	broken := avroSchema(t, func(order map[string]any) {
+		for _, f := range order["fields"].([]any) {
+			if field := f.(map[string]any); field["name"] == "order_uid" {
+				field["type"] = "long"
+			}
+		}
+	})
+
+	decoders := NewDecoders(false)
+	decoders.Register(ContentTypeAvro, NewAvro(stubRegistry(t, map[int]string{1: v1, 2: orderAvroSchema, 3: v3, 4: broken, 503: v1}), false))
+	ctx := context.Background()
+
+	t.Run("current", func(t *testing.T) {
+		var got model.OrderInfo
+		require.NoError(t, decoders.Decode(ctx, ContentTypeAvro, avroMessage(t, 2, orderAvroSchema, testOrder()), &got))
+		require.Equal(t, testOrder(), got)
+	})
+
+	t.Run("older writer", func(t *testing.T) {
+		var got model.OrderInfo
+		require.NoError(t, decoders.Decode(ctx, "avro/binary", avroMessage(t, 1, v1, testOrder()), &got))
+
+		want := testOrder()
+		want.OofShard, want.Delivery.Region, want.Payment.CustomFee = "", "", 0
+		require.Equal(t, want, got)
+	})
+
+	t.Run("newer writer", func(t *testing.T) {
+		var value map[string]any
+		data, err := json.Marshal(testOrder())
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(data, &value))
+		value["comment"] = "leave at the door"
+		value["date_created"] = testOrder().DateCreated
+
+		var got model.OrderInfo
+		require.NoError(t, decoders.Decode(ctx, ContentTypeAvro, avroMessage(t, 3, v3, avroValue(value)), &got))
+		require.Equal(t, testOrder(), got)
+	})
+
+	t.Run("incompatible writer", func(t *testing.T) {
+		var got model.OrderInfo
+		err := decoders.Decode(ctx, ContentTypeAvro, schemaregistry.AppendWireFormat(nil, 4, []byte{2}), &got)
+		require.ErrorIs(t, err, ErrIncompatibleSchema)
+	})
+
+	t.Run("unknown schema", func(t *testing.T) {
+		var got model.OrderInfo
+		err := decoders.Decode(ctx, ContentTypeAvro, schemaregistry.AppendWireFormat(nil, 42, []byte{2}), &got)
+		require.ErrorIs(t, err, schemaregistry.ErrNotFound)
+	})
+
+	t.Run("registry unavailable", func(t *testing.T) {
+		var got model.OrderInfo
+		err := decoders.Decode(ctx, ContentTypeAvro, schemaregistry.AppendWireFormat(nil, 503, []byte{2}), &got)
+		require.ErrorIs(t, err, schemaregistry.ErrUnavailable)
+	})
+
+	t.Run("not wire format", func(t *testing.T) {
+		var got model.OrderInfo
+		err := decoders.Decode(ctx, ContentTypeAvro, []byte(`{"order_uid":"1"}`), &got)
+		require.ErrorIs(t, err, schemaregistry.ErrNotWireFormat)
+	})
+}
+
+// avroValue приводит числа из JSON к int64, как их ждёт Avro long
+func avroValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			v[k] = avroValue(field)
+		}
+		return v
+	case []any:
+		for i, item := range v {
+			v[i] = avroValue(item)
+		}
+		return v
+	case float64:
+		return int64(v)
+	default:
+		return v
+	}
+}
+
+// protoMessage кодирует заказ в Protobuf по схеме order.proto
+func protoMessage(t *testing.T, order model.OrderInfo) []byte {
+	t.Helper()
+	value, err := json.Marshal(order)
+	require.NoError(t, err)
+	msg := dynamicpb.NewMessage(orderDescriptor())
+	require.NoError(t, protojson.Unmarshal(value, msg))
+	data, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	return data
+}
+
+//go:embed order.proto
+var orderProto string
+
+// protoFieldLine поле сообщения в order.proto: [repeated] тип имя = номер;
+var protoFieldLine = regexp.MustCompile(`^(repeated\s+)?([\w.]+)\s+(\w+)\s*=\s*(\d+)\s*;$`)
+
+// parseOrderProto поля всех сообщений order.proto: "OrderInfo.Delivery.name" -> "2 string".
+// Разбирается только то, что есть в схеме: вложенные сообщения и поля без опций
+func parseOrderProto(t *testing.T) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	var pkg string
+	var scope []string
+	for _, line := range strings.Split(orderProto, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "//") || strings.HasPrefix(line, "syntax") || strings.HasPrefix(line, "import"):
+		case strings.HasPrefix(line, "package "):
+			pkg = strings.TrimSuffix(strings.TrimPrefix(line, "package "), ";")
+		case strings.HasPrefix(line, "message "):
+			scope = append(scope, strings.TrimSuffix(strings.TrimPrefix(line, "message "), " {"))
+		case line == "}":
+			require.NotEmpty(t, scope, "unbalanced braces in order.proto")
+			scope = scope[:len(scope)-1]
+		default:
+			m := protoFieldLine.FindStringSubmatch(line)
+			require.NotNil(t, m, "unsupported line in order.proto: %q", line)
+			typ := m[2]
+			if !strings.Contains(typ, ".") && typ != "string" && typ != "int64" {
+				// вложенное сообщение текущей области
+				typ = pkg + "." + strings.Join(scope, ".") + "." + typ
+			}
+			if m[1] != "" {
+				typ = "repeated " + typ
+			}
+			fields[strings.Join(append(scope, m[3]), ".")] = m[4] + " " + typ
+		}
+	}
+	require.Empty(t, scope, "unbalanced braces in order.proto")
+	return fields
+}
+
+// descriptorFields поля дескриптора в том же виде, что и parseOrderProto
+func descriptorFields(md protoreflect.MessageDescriptor, prefix string, fields map[string]string) {
+	for i := range md.Fields().Len() {
+		fd := md.Fields().Get(i)
+		typ := fd.Kind().String()
+		if fd.Message() != nil {
+			typ = string(fd.Message().FullName())
+		}
+		if fd.IsList() {
+			typ = "repeated " + typ
+		}
+		fields[prefix+string(fd.Name())] = strconv.Itoa(int(fd.Number())) + " " + typ
+	}
+	for i := range md.Messages().Len() {
+		nested := md.Messages().Get(i)
+		descriptorFields(nested, prefix+string(nested.Name())+".", fields)
+	}
+}
+
+// TestOrderDescriptorMatchesProto дескриптор, собранный в коде, совпадает с опубликованной схемой order.proto
+func TestOrderDescriptorMatchesProto(t *testing.T) {
+	md := orderDescriptor()
+	require.Equal(t, protoreflect.FullName("order.v1.OrderInfo"), md.FullName())
+
+	got := make(map[string]string)
+	descriptorFields(md, "OrderInfo.", got)
+	require.Equal(t, parseOrderProto(t), got)
+}
+
+func TestProtobufDecode(t *testing.T) {
+	decoders := NewDecoders(false)
+	ctx := context.Background()
+	payload := protoMessage(t, testOrder())
+
+	t.Run("raw", func(t *testing.T) {
+		var got model.OrderInfo
+		require.NoError(t, decoders.Decode(ctx, ContentTypeProtobuf, payload, &got))
+		require.Equal(t, testOrder(), got)
+	})
+
+	t.Run("wire format", func(t *testing.T) {
+		// индексы сообщения: пустой список и явный [0]
+		for _, indexes := range [][]byte{{0}, {2, 0}} {
+			var got model.OrderInfo
+			data := schemaregistry.AppendWireFormat(nil, 9, append(slices.Clone(indexes), payload...))
+			require.NoError(t, decoders.Decode(ctx, "application/vnd.google.protobuf", data, &got))
+			require.Equal(t, testOrder(), got)
+		}
+
+		var got model.OrderInfo
+		data := schemaregistry.AppendWireFormat(nil, 9, append([]byte{2, 2}, payload...))
+		require.ErrorIs(t, decoders.Decode(ctx, ContentTypeProtobuf, data, &got), errMessageIndex)
+	})
+
+	t.Run("older writer", func(t *testing.T) {
+		// старая версия ещё не знала oof_shard и пошлину: поля остаются пустыми
+		order := testOrder()
+		order.OofShard, order.Payment.CustomFee = "", 0
+
+		var got model.OrderInfo
+		require.NoError(t, decoders.Decode(ctx, ContentTypeProtobuf, protoMessage(t, order), &got))
+		require.Equal(t, order, got)
+	})
+
+	t.Run("newer writer", func(t *testing.T) {
+		// новая версия добавила поле 15 в заказ и поле 12 в товар
+		item := protowire.AppendTag(nil, 12, protowire.BytesType)
+		item = protowire.AppendString(item, "gift wrap")
+		data := protowire.AppendTag(slices.Clone(payload), 15, protowire.VarintType)
+		data = protowire.AppendVarint(data, 1)
+		data = protowire.AppendTag(data, 6, protowire.BytesType)
+		data = protowire.AppendBytes(data, append(itemBytes(t, testOrder().Items[0]), item...))
+
+		var got model.OrderInfo
+		require.NoError(t, decoders.Decode(ctx, ContentTypeProtobuf, data, &got))
+		want := testOrder()
+		want.Items = append(want.Items, want.Items[0])
+		require.Equal(t, want, got)
+	})
+
+	t.Run("field changed type", func(t *testing.T) {
+		// sm_id (12) стал строкой
+		data := protowire.AppendTag(slices.Clone(payload), 12, protowire.BytesType)
+		data = protowire.AppendString(data, "99")
+
+		var got model.OrderInfo
+		require.ErrorIs(t, decoders.Decode(ctx, ContentTypeProtobuf, data, &got), ErrIncompatibleSchema)
+	})
+}
+
+// itemBytes кодирует товар в Protobuf
+func itemBytes(t *testing.T, item model.Item) []byte {
+	t.Helper()
+	value, err := json.Marshal(item)
+	require.NoError(t, err)
+	msg := dynamicpb.NewMessage(orderDescriptor().Messages().ByName("Item"))
+	require.NoError(t, protojson.Unmarshal(value, msg))
+	data, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	return data
+}
+
+func TestStrictJSON(t *testing.T) {
+	value, err := json.Marshal(testOrder())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	var got model.OrderInfo
+	require.NoError(t, NewDecoders(true).Decode(ctx, ContentTypeJSON, value, &got))
+	require.Equal(t, testOrder(), got)
+
+	tests := []struct {
+		name    string
+		edit    func(order map[string]any)
+		wantErr error
+		message string
+	}{
+		{
+			name:    "unknown field",
+			edit:    func(o map[string]any) { o["comment"] = "leave at the door" },
+			wantErr: ErrUnknownField,
+			message: "unknown field: comment",
+		},
+		{
+			name: "field name in other case",
+			edit: func(o map[string]any) {
+				delivery := o["delivery"].(map[string]any)
+				delivery["Email"] = delivery["email"]
+				delete(delivery, "email")
+			},
+			wantErr: ErrUnknownField,
+			message: "unknown field: delivery.Email",
+		},
+		{
+			name:    "string instead of integer",
+			edit:    func(o map[string]any) { o["payment"].(map[string]any)["amount"] = "1817" },
+			wantErr: ErrTypeMismatch,
+			message: "type mismatch: payment.amount must be integer, got string",
+		},
+		{
+			name:    "fractional number",
+			edit:    func(o map[string]any) { o["items"].([]any)[0].(map[string]any)["price"] = 45.3 },
+			wantErr: ErrTypeMismatch,
+			message: "type mismatch: items[0].price must be 64-bit integer, got number",
+		},
+		{
+			name:    "null",
+			edit:    func(o map[string]any) { o["items"] = nil },
+			wantErr: ErrTypeMismatch,
+			message: "type mismatch: items must be array, got null",
+		},
+		{
+			name: "several problems",
+			edit: func(o map[string]any) {
+				o["comment"] = "leave at the door"
+				o["sm_id"] = "99"
+				o["payment"].(map[string]any)["amount"] = "1817"
+				o["delivery"].(map[string]any)["Email"] = "test@gmail.com"
+			},
+			wantErr: ErrUnknownField,
+			message: "unknown field: comment",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var order map[string]any
+			require.NoError(t, json.Unmarshal(value, &order))
+			tt.edit(order)
+			data, err := json.Marshal(order)
+			require.NoError(t, err)
+
+			// порядок обхода полей не влияет на ошибку
+			for range 20 {
+				var got model.OrderInfo
+				err = NewDecoders(true).Decode(ctx, ContentTypeJSON, data, &got)
+				require.ErrorIs(t, err, tt.wantErr)
+				require.EqualError(t, err, tt.message)
+			}
+		})
+	}
+
+	// без строгого режима неизвестные поля пропускаются
+	data := append(value[:len(value)-1:len(value)-1], `,"comment":"leave at the door"}`...)
+	require.NoError(t, NewDecoders(false).Decode(ctx, ContentTypeJSON, data, &got))
+}
+
+func TestStrictProtobuf(t *testing.T) {
+	// поле 99 из новой версии схемы продьюсера
+	payload := protowire.AppendVarint(protowire.AppendTag(protoMessage(t, testOrder()), 99, protowire.VarintType), 1)
+	ctx := context.Background()
+
+	var got model.OrderInfo
+	require.NoError(t, NewDecoders(false).Decode(ctx, ContentTypeProtobuf, payload, &got))
+
+	err := NewDecoders(true).Decode(ctx, ContentTypeProtobuf, payload, &got)
+	require.ErrorIs(t, err, ErrUnknownField)
+	require.ErrorContains(t, err, "field number 99 in order.v1.OrderInfo")
+}
+
+func TestStrictAvro(t *testing.T) {
+	withComment := avroSchema(t, func(order map[string]any) {
+		for _, f := range order["fields"].([]any) {
+			if field := f.(map[string]any); field["name"] == "items" {
+				item := field["type"].(map[string]any)["items"].(map[string]any)
+				item["fields"] = append(item["fields"].([]any), map[string]any{"name": "comment", "type": "string", "default": ""})
+			}
+		}
+	})
+	decoders := NewDecoders(true)
+	decoders.Register(ContentTypeAvro, NewAvro(stubRegistry(t, map[int]string{1: orderAvroSchema, 2: withComment}), true))
+	ctx := context.Background()
+
+	var got model.OrderInfo
+	require.NoError(t, decoders.Decode(ctx, ContentTypeAvro, avroMessage(t, 1, orderAvroSchema, testOrder()), &got))
+	require.Equal(t, testOrder(), got)
+
+	err := decoders.Decode(ctx, ContentTypeAvro, schemaregistry.AppendWireFormat(nil, 2, []byte{2}), &got)
+	require.ErrorIs(t, err, ErrUnknownField)
+	require.ErrorContains(t, err, "items[].comment")
+}
@@ -0,0 +1,27 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"order-back-end/internal/model"
+)
+
+// JSON декодер заказа в JSON
+type JSON struct {
+	// Strict отклоняет неизвестные поля, поля в другом регистре и null
+	Strict bool
+}
+
+// Decode разбирает JSON в заказ
+func (j JSON) Decode(_ context.Context, data []byte, order *model.OrderInfo) error {
+	if j.Strict {
+		if err := checkStrictJSON(data); err != nil {
+			return err
+		}
+	}
+	if err := json.Unmarshal(data, order); err != nil {
+		return errors.New("invalid JSON: " + err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package codec
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"order-back-end/internal/model"
+	"order-back-end/internal/schemaregistry"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// errMessageIndex сообщение в wire format ссылается не на OrderInfo
+var errMessageIndex = errors.New("protobuf message index must be [0] (order.v1.OrderInfo)")
+
+// Protobuf декодер заказа в Protobuf по схеме order.proto: без обрамления или в Confluent wire format.
+// Эволюция держится на номерах полей: неизвестные номера от новых версий пропускаются, отсутствующие поля
+// старых версий остаются нулевыми. Известный номер с другим типом на проводе - несовместимая схема
+type Protobuf struct {
+	desc   protoreflect.MessageDescriptor
+	strict bool
+}
+
+// NewProtobuf создаёт декодер; strict отклоняет сообщения с неизвестными номерами полей
+func NewProtobuf(strict bool) *Protobuf {
+	return &Protobuf{desc: orderDescriptor(), strict: strict}
+}
+
+// Decode разбирает сообщение в заказ
+func (p *Protobuf) Decode(_ context.Context, data []byte, order *model.OrderInfo) error {
+	// сериализованное сообщение Protobuf не может начинаться с нулевого байта, поэтому он однозначно
+	// указывает на wire format; идентификатор схемы не нужен, читаем по своей схеме
+	if len(data) > 0 && data[0] == 0 {
+		_, payload, err := schemaregistry.ParseWireFormat(data)
+		if err != nil {
+			return err
+		}
+		if data, err = skipMessageIndexes(payload); err != nil {
+			return err
+		}
+	}
+
+	msg := dynamicpb.NewMessage(p.desc)
+	if err := proto.Unmarshal(data, msg); err != nil {
+		return fmt.Errorf("invalid protobuf message: %w", err)
+	}
+	if err := checkUnknown(msg, p.strict); err != nil {
+		return err
+	}
+
+	// поля схемы названы как json теги модели, поэтому заказ собирается через JSON
+	value, err := json.Marshal(messageMap(msg))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(value, order); err != nil {
+		return fmt.Errorf("invalid protobuf message: %w", err)
+	}
+	return nil
+}
+
+// skipMessageIndexes пропускает индексы сообщения в файле схемы, которыми Confluent предваряет полезную нагрузку.
+// Индексы - zigzag varint: длина, затем сами индексы; длина 0 означает первое сообщение файла
+func skipMessageIndexes(data []byte) ([]byte, error) {
+	count, n := protowire.ConsumeVarint(data)
+	if n < 0 {
+		return nil, protowire.ParseError(n)
+	}
+	data = data[n:]
+	switch protowire.DecodeZigZag(count) {
+	case 0:
+		return data, nil
+	case 1:
+		index, n := protowire.ConsumeVarint(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		if protowire.DecodeZigZag(index) != 0 {
+			return nil, errMessageIndex
+		}
+		return data[n:], nil
+	default:
+		return nil, errMessageIndex
+	}
+}
+
+// checkUnknown ищет среди неизвестных полей сообщения и вложенных сообщений известные номера:
+// так выглядит поле, тип которого поменялся в схеме продьюсера. strict отклоняет любое неизвестное поле
+func checkUnknown(msg protoreflect.Message, strict bool) error {
+	fields := msg.Descriptor().Fields()
+	for b := msg.GetUnknown(); len(b) > 0; {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if fd := fields.ByNumber(num); fd != nil {
+			return fmt.Errorf("%w: field %s (%d) has unexpected wire type %d", ErrIncompatibleSchema, fd.FullName(), num, typ)
+		}
+		if strict {
+			return fmt.Errorf("%w: field number %d in %s", ErrUnknownField, num, msg.Descriptor().FullName())
+		}
+		m := protowire.ConsumeFieldValue(num, typ, b[n:])
+		if m < 0 {
+			return protowire.ParseError(m)
+		}
+		b = b[n+m:]
+	}
+
+	var err error
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		switch {
+		case fd.Message() == nil:
+		case fd.IsList():
+			for i := 0; i < v.List().Len() && err == nil; i++ {
+				err = checkUnknown(v.List().Get(i).Message(), strict)
+			}
+		default:
+			err = checkUnknown(v.Message(), strict)
+		}
+		return err == nil
+	})
+	return err
+}
+
+// messageMap заполненные поля сообщения по именам
+func messageMap(msg protoreflect.Message) map[string]any {
+	result := make(map[string]any)
+	msg.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		if fd.IsList() {
+			values := make([]any, v.List().Len())
+			for i := range values {
+				values[i] = fieldValue(fd, v.List().Get(i))
+			}
+			result[string(fd.Name())] = values
+			return true
+		}
+		result[string(fd.Name())] = fieldValue(fd, v)
+		return true
+	})
+	return result
+}
+
+// fieldValue значение поля; google.protobuf.Timestamp превращается во время
+func fieldValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) any {
+	if fd.Message() == nil {
+		return v.Interface()
+	}
+	msg := v.Message()
+	if msg.Descriptor().FullName() == timestampName {
+		fields := msg.Descriptor().Fields()
+		return time.Unix(msg.Get(fields.ByName("seconds")).Int(), msg.Get(fields.ByName("nanos")).Int()).UTC()
+	}
+	return messageMap(msg)
+}
+
+var timestampName = (&timestamppb.Timestamp{}).ProtoReflect().Descriptor().FullName()
+
+// orderDescriptor дескриптор order.v1.OrderInfo из order.proto
+func orderDescriptor() protoreflect.MessageDescriptor {
+	const (
+		str = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i64 = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		msg = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("order.proto"),
+		Package:    proto.String("order.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("OrderInfo"),
+			NestedType: []*descriptorpb.DescriptorProto{
+				{
+					Name: proto.String("Delivery"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("name", 1, str, ""), field("phone", 2, str, ""), field("zip", 3, str, ""),
+						field("city", 4, str, ""), field("address", 5, str, ""), field("region", 6, str, ""),
+						field("email", 7, str, ""),
+					},
+				},
+				{
+					Name: proto.String("Payment"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("transaction", 1, str, ""), field("request_id", 2, str, ""), field("currency", 3, str, ""),
+						field("provider", 4, str, ""), field("amount", 5, i64, ""), field("payment_dt", 6, i64, ""),
+						field("bank", 7, str, ""), field("delivery_cost", 8, i64, ""), field("goods_total", 9, i64, ""),
+						field("custom_fee", 10, i64, ""),
+					},
+				},
+				{
+					Name: proto.String("Item"),
+					Field: []*descriptorpb.FieldDescriptorProto{
+						field("chrt_id", 1, i64, ""), field("track_number", 2, str, ""), field("price", 3, i64, ""),
+						field("rid", 4, str, ""), field("name", 5, str, ""), field("sale", 6, i64, ""),
+						field("size", 7, str, ""), field("total_price", 8, i64, ""), field("nm_id", 9, i64, ""),
+						field("brand", 10, str, ""), field("status", 11, i64, ""),
+					},
+				},
+			},
+			Field: []*descriptorpb.FieldDescriptorProto{
+				field("order_uid", 1, str, ""), field("track_number", 2, str, ""), field("entry", 3, str, ""),
+				field("delivery", 4, msg, ".order.v1.OrderInfo.Delivery"),
+				field("payment", 5, msg, ".order.v1.OrderInfo.Payment"),
+				repeated(field("items", 6, msg, ".order.v1.OrderInfo.Item")),
+				field("locale", 7, str, ""), field("internal_signature", 8, str, ""), field("customer_id", 9, str, ""),
+				field("delivery_service", 10, str, ""), field("shardkey", 11, str, ""), field("sm_id", 12, i64, ""),
+				field("date_created", 13, msg, ".google.protobuf.Timestamp"), field("oof_shard", 14, str, ""),
+			},
+		}},
+	}
+
+	fd, err := protodesc.NewFile(file, protoregistry.GlobalFiles)
+	if err != nil {
+		panic(fmt.Sprintf("order.proto descriptor: %v", err))
+	}
+	return fd.Messages().ByName("OrderInfo")
+}
+
+// field одиночное поле дескриптора; typeName только для сообщений
+func field(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Type:   typ.Enum(),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = proto.String(typeName)
+	}
+	return f
+}
+
+// repeated делает поле повторяющимся
+func repeated(f *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+	f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return f
+}
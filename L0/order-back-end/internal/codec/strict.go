@@ -0,0 +1,195 @@
+package codec
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"order-back-end/internal/jsonschema"
+	"order-back-end/internal/model"
+	"reflect"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/hamba/avro/v2"
+)
+
+var (
+	// ErrUnknownField в строгом режиме: в сообщении поле, которого нет в заказе
+	ErrUnknownField = errors.New("unknown field")
+	// ErrTypeMismatch в строгом режиме: тип значения не совпадает с типом поля заказа
+	ErrTypeMismatch = errors.New("type mismatch")
+)
+
+var (
+	orderType = reflect.TypeOf(model.OrderInfo{})
+	timeType  = reflect.TypeOf(time.Time{})
+)
+
+// checkStrictJSON сверяет JSON с заказом: имена полей совпадают с json тегами с учётом регистра,
+// null и значения другого типа не принимаются
+func checkStrictJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return errors.New("invalid JSON: " + err.Error())
+	}
+	return matchJSON(value, orderType, "")
+}
+
+// matchJSON проверяет значение по типу поля; path - JSON путь для сообщения об ошибке.
+// Поля объекта обходятся по имени, так что из нескольких нарушений всегда сообщается одно и то же
+func matchJSON(value any, typ reflect.Type, path string) error {
+	mismatch := func(want string) error {
+		return fmt.Errorf("%w: %s must be %s, got %s", ErrTypeMismatch, displayPath(path), want, jsonKind(value))
+	}
+
+	if typ == timeType {
+		s, ok := value.(string)
+		if !ok {
+			return mismatch("RFC 3339 string")
+		}
+		if _, err := time.Parse(time.RFC3339Nano, s); err != nil {
+			return mismatch("RFC 3339 string")
+		}
+		return nil
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			return mismatch("string")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, ok := value.(json.Number)
+		if !ok {
+			return mismatch("integer")
+		}
+		if _, err := strconv.ParseInt(n.String(), 10, typ.Bits()); err != nil {
+			return mismatch(fmt.Sprintf("%d-bit integer", typ.Bits()))
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			return mismatch("boolean")
+		}
+	case reflect.Slice:
+		values, ok := value.([]any)
+		if !ok {
+			return mismatch("array")
+		}
+		for i, v := range values {
+			if err := matchJSON(v, typ.Elem(), path+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+	case reflect.Struct:
+		object, ok := value.(map[string]any)
+		if !ok {
+			return mismatch("object")
+		}
+		fields := make(map[string]reflect.Type, typ.NumField())
+		for i := range typ.NumField() {
+			if name, ok := jsonschema.FieldName(typ.Field(i)); ok {
+				fields[name] = typ.Field(i).Type
+			}
+		}
+		for _, name := range slices.Sorted(maps.Keys(object)) {
+			field := joinPath(path, name)
+			fieldType, ok := fields[name]
+			if !ok {
+				return fmt.Errorf("%w: %s", ErrUnknownField, field)
+			}
+			if err := matchJSON(object[name], fieldType, field); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func jsonKind(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	case []any:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "order"
+	}
+	return path
+}
+
+// checkStrictAvro ищет в схеме записи поля, которых нет в схеме чтения: при сведении схем они молча отбрасываются
+func checkStrictAvro(reader, writer avro.Schema, path string) error {
+	if ref, ok := writer.(*avro.RefSchema); ok {
+		writer = ref.Schema()
+	}
+	if ref, ok := reader.(*avro.RefSchema); ok {
+		reader = ref.Schema()
+	}
+
+	switch w := writer.(type) {
+	case *avro.UnionSchema:
+		for _, member := range w.Types() {
+			if err := checkStrictAvro(reader, member, path); err != nil {
+				return err
+			}
+		}
+	case *avro.ArraySchema:
+		if r, ok := reader.(*avro.ArraySchema); ok {
+			return checkStrictAvro(r.Items(), w.Items(), path+"[]")
+		}
+	case *avro.RecordSchema:
+		r, ok := reader.(*avro.RecordSchema)
+		if !ok {
+			return nil
+		}
+		for _, wf := range w.Fields() {
+			rf := avroField(r, wf)
+			if rf == nil {
+				return fmt.Errorf("%w: %s", ErrUnknownField, joinPath(path, wf.Name()))
+			}
+			if err := checkStrictAvro(rf.Type(), wf.Type(), joinPath(path, rf.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// avroField поле схемы чтения, в которое читается поле записи: по имени или псевдониму
+func avroField(reader *avro.RecordSchema, field *avro.Field) *avro.Field {
+	for _, f := range reader.Fields() {
+		if f.Name() == field.Name() {
+			return f
+		}
+		for _, alias := range f.Aliases() {
+			if alias == field.Name() {
+				return f
+			}
+		}
+	}
+	return nil
+}
@@ -2,9 +2,12 @@ package config
 
 import (
 	"fmt"
+	"order-back-end/internal/cache"
 	kfk "order-back-end/internal/kafka/config"
 	"order-back-end/internal/postgres"
+	"order-back-end/internal/validator"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
@@ -12,6 +15,10 @@ import (
 // httpConfig структура которая содержит порт для подключения по HTTP
 type httpConfig struct {
 	Port string `yaml:"port" envconfig:"PORT" default:"8081"`
+	// IdempotencyTTL сколько хранить ответ на запрос с заголовком Idempotency-Key
+	IdempotencyTTL time.Duration `yaml:"idempotency_ttl" env:"HTTP_IDEMPOTENCY_TTL" env-default:"24h"`
+	// IdempotencyMaxKeys сколько ключей хранить в памяти; сверх него вытесняются самые старые выполненные
+	IdempotencyMaxKeys int `yaml:"idempotency_max_keys" env:"HTTP_IDEMPOTENCY_MAX_KEYS" env-default:"100000"`
 }
 
 // Config структура содержащая основные параменты в конфиге
@@ -19,12 +26,16 @@ type Config struct {
 	HTTP     httpConfig      `yaml:"http" envconfig:"HTTP"`
 	Postgres postgres.Config `yaml:"postgres" envconfig:"POSTGRES"`
 	Kafka    kfk.Config      `yaml:"kafka" envconfig:"KAFKA"`
+	Cache    cache.Config    `yaml:"cache" envconfig:"CACHE"`
+	// Validation режим проверки бизнес-правил заказа и допуск для сумм
+	Validation validator.Config `yaml:"validation"`
+
+	// ShutdownTimeout сколько ждать остановки всех компонентов после SIGTERM
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" env-default:"15s"`
 }
 
 // NewConfig создает Config
 func NewConfig() (*Config, error) {
-	var cfg Config
-
 	// Ищем config.yaml в нескольких местах для совместимости с тестами и Docker
 	configPaths := []string{
 		"./configs/config.yaml",
@@ -45,7 +56,18 @@ func NewConfig() (*Config, error) {
 		return &Config{}, fmt.Errorf("config file not found")
 	}
 
-	if err := cleanenv.ReadConfig(configPath, &cfg); err != nil {
+	return readConfig(configPath)
+}
+
+// readConfig читает конфиг из файла и переменных окружения
+func readConfig(path string) (*Config, error) {
+	// env-default заменяет и нулевое значение из YAML, поэтому значения, которые можно отключить пустой
+	// строкой или нулём, задаются до чтения файла: они остаются, только если ключа в файле нет
+	cfg := Config{
+		Kafka:      kfk.Config{Consumer: kfk.ConsumerConfig{Count: kfk.DefaultConsumerCount}},
+		Validation: validator.Config{DefaultRegion: validator.DefaultPhoneRegion},
+	}
+	if err := cleanenv.ReadConfig(path, &cfg); err != nil {
 		return &Config{}, fmt.Errorf("error reading config: %w", err)
 	}
 	return &cfg, nil
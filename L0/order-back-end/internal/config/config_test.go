@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigDefaultRegion(t *testing.T) {
+	for yaml, want := range map[string]string{
+		"validation:\n  mode: strict\n":           "RU",
+		"validation:\n  default_region: \"\"\n":   "",
+		"validation:\n  default_region: \"KZ\"\n": "KZ",
+		"shutdown_timeout: 5s\n":                  "RU",
+	} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(yaml), 0o600))
+		cfg, err := readConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, want, cfg.Validation.DefaultRegion, yaml)
+	}
+}
+
+func TestReadConfigConsumerCount(t *testing.T) {
+	for yaml, want := range map[string]int{
+		"kafka:\n  consumer:\n    count: 0\n":     0,
+		"kafka:\n  consumer:\n    count: 5\n":     5,
+		"kafka:\n  consumer:\n    max_count: 4\n": 3,
+	} {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(yaml), 0o600))
+		cfg, err := readConfig(path)
+		require.NoError(t, err)
+		require.Equal(t, want, cfg.Kafka.Consumer.Count, yaml)
+	}
+}
@@ -0,0 +1,156 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"order-back-end/internal/cache"
+	kfk "order-back-end/internal/kafka/consumer"
+	"order-back-end/internal/kafka/dlq"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	codeDLQDisabled     = "dlq_disabled"
+	codeDLQNotFound     = "dlq_message_not_found"
+	codeInvalidPoolSize = "invalid_pool_size"
+	codePoolStopped     = "consumer_pool_stopped"
+
+	defaultDLQListLimit = 50
+	maxDLQListLimit     = 500
+)
+
+// deadLetters просмотр и переотправка сообщений dead-letter топика
+type deadLetters interface {
+	List(ctx context.Context, limit int) ([]dlq.Message, error)
+	Redrive(ctx context.Context, partition int32, offset int64) error
+}
+
+// consumerPool состояние и масштабирование пула консьюмеров
+type consumerPool interface {
+	Status() kfk.PoolStatus
+	Scale(ctx context.Context, size int) error
+}
+
+// scaleRequest тело запроса PUT /admin/consumers
+type scaleRequest struct {
+	Size *int `json:"size"`
+}
+
+// AdminHandler служебные ручки для эксплуатации сервиса
+type AdminHandler struct {
+	router    *gin.Engine
+	cache     cache.Cache
+	dlq       deadLetters
+	consumers consumerPool
+}
+
+// NewAdminHandler создает экземпляр AdminHandler
+func NewAdminHandler(router *gin.Engine, cache cache.Cache, dlq deadLetters, consumers consumerPool) *AdminHandler {
+	return &AdminHandler{
+		router:    router,
+		cache:     cache,
+		dlq:       dlq,
+		consumers: consumers,
+	}
+}
+
+// CacheStats handler который реализует ручку GET /admin/cache/stats
+func (h *AdminHandler) CacheStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.cache.Stats())
+}
+
+// ListDeadLetters handler который реализует ручку GET /admin/dlq?limit=N
+func (h *AdminHandler) ListDeadLetters(c *gin.Context) {
+	limit := defaultDLQListLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxDLQListLimit {
+			abortWithError(c, http.StatusBadRequest, codeInvalidFilter, "limit must be between 1 and 500")
+			return
+		}
+		limit = n
+	}
+
+	messages, err := h.dlq.List(c.Request.Context(), limit)
+	if err != nil {
+		writeDLQError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages})
+}
+
+// RedriveDeadLetter handler который реализует ручку POST /admin/dlq/:partition/:offset/redrive
+func (h *AdminHandler) RedriveDeadLetter(c *gin.Context) {
+	partition, err := strconv.ParseInt(c.Param("partition"), 10, 32)
+	if err != nil || partition < 0 {
+		abortWithError(c, http.StatusBadRequest, codeInvalidID, "partition must be a non-negative integer")
+		return
+	}
+	offset, err := strconv.ParseInt(c.Param("offset"), 10, 64)
+	if err != nil || offset < 0 {
+		abortWithError(c, http.StatusBadRequest, codeInvalidID, "offset must be a non-negative integer")
+		return
+	}
+
+	if err := h.dlq.Redrive(c.Request.Context(), int32(partition), offset); err != nil {
+		writeDLQError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"partition": partition, "offset": offset, "status": "redriven"})
+}
+
+// ConsumerPool handler который реализует ручку GET /admin/consumers
+func (h *AdminHandler) ConsumerPool(c *gin.Context) {
+	c.JSON(http.StatusOK, h.consumers.Status())
+}
+
+// ScaleConsumerPool handler который реализует ручку PUT /admin/consumers с телом {"size": N}
+func (h *AdminHandler) ScaleConsumerPool(c *gin.Context) {
+	var req scaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Size == nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidPoolSize, `body must be {"size": N}`)
+		return
+	}
+
+	if err := h.consumers.Scale(c.Request.Context(), *req.Size); err != nil {
+		switch {
+		case errors.Is(err, kfk.ErrInvalidPoolSize):
+			abortWithError(c, http.StatusBadRequest, codeInvalidPoolSize, err.Error())
+		case errors.Is(err, kfk.ErrPoolStopped):
+			abortWithError(c, http.StatusServiceUnavailable, codePoolStopped, err.Error())
+		default:
+			writeError(c, err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, h.consumers.Status())
+}
+
+// writeDLQError выбирает HTTP статус для ошибок dead-letter топика
+func writeDLQError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, dlq.ErrDisabled):
+		abortWithError(c, http.StatusNotFound, codeDLQDisabled, dlq.ErrDisabled.Error())
+	case errors.Is(err, dlq.ErrNotFound):
+		abortWithError(c, http.StatusNotFound, codeDLQNotFound, dlq.ErrNotFound.Error())
+	default:
+		writeError(c, err)
+	}
+}
+
+// RegisterRoutes регистрируем служебные ручки
+func (h *AdminHandler) RegisterRoutes() {
+	adminR := h.router.Group("/admin")
+
+	adminR.GET("/cache/stats", h.CacheStats)
+	adminR.GET("/dlq", h.ListDeadLetters)
+	adminR.POST("/dlq/:partition/:offset/redrive", h.RedriveDeadLetter)
+	adminR.GET("/consumers", h.ConsumerPool)
+	adminR.PUT("/consumers", h.ScaleConsumerPool)
+}
@@ -0,0 +1,72 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-back-end/internal/cache"
+	kfk "order-back-end/internal/kafka/consumer"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePool пул консьюмеров без kafka
+type fakePool struct {
+	size, max int
+}
+
+func (p *fakePool) Status() kfk.PoolStatus {
+	return kfk.PoolStatus{Size: p.size, MaxSize: p.max}
+}
+
+func (p *fakePool) Scale(_ context.Context, size int) error {
+	if size < 0 || size > p.max {
+		return fmt.Errorf("%w: must be between 0 and %d", kfk.ErrInvalidPoolSize, p.max)
+	}
+	p.size = size
+	return nil
+}
+
+func TestAdminHandler_ScaleConsumerPool(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+		wantSize int
+	}{
+		{name: "scale up", body: `{"size": 5}`, wantCode: http.StatusOK, wantSize: 5},
+		{name: "scale to zero", body: `{"size": 0}`, wantCode: http.StatusOK, wantSize: 0},
+		{name: "above max", body: `{"size": 9}`, wantCode: http.StatusBadRequest, wantSize: 3},
+		{name: "missing size", body: `{}`, wantCode: http.StatusBadRequest, wantSize: 3},
+		{name: "invalid json", body: `size=5`, wantCode: http.StatusBadRequest, wantSize: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := &fakePool{size: 3, max: 8}
+			router := gin.New()
+			NewAdminHandler(router, cache.NewCache(time.Second*10, 10), nil, pool).RegisterRoutes()
+
+			req := httptest.NewRequestWithContext(context.Background(), http.MethodPut, "/admin/consumers", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantCode, rec.Code)
+			require.Equal(t, tt.wantSize, pool.size)
+			if tt.wantCode == http.StatusOK {
+				var status kfk.PoolStatus
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &status))
+				require.Equal(t, tt.wantSize, status.Size)
+			}
+		})
+	}
+}
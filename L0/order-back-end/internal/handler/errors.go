@@ -0,0 +1,86 @@
+package order
+
+import (
+	"errors"
+	"net/http"
+	"order-back-end/internal/logger"
+	order "order-back-end/internal/service"
+	"order-back-end/internal/validator"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Машиночитаемые коды ошибок API
+const (
+	codeOrderNotFound      = "order_not_found"
+	codeInvalidID          = "invalid_id"
+	codeInvalidFilter      = "invalid_filter"
+	codeOrderExists        = "order_exists"
+	codeTransactionExists  = "transaction_exists"
+	codeConflict           = "conflict"
+	codeInvalidValue       = "invalid_value"
+	codeStorageUnavailable = "storage_unavailable"
+	codeValidationFailed   = "validation_failed"
+	codeInternal           = "internal_error"
+)
+
+// errorBody стабильный формат ошибки: {"error": {"code": "...", "message": "..."}};
+// для невалидного заказа в details перечислены все нарушения
+type errorBody struct {
+	Code    string                `json:"code"`
+	Message string                `json:"message"`
+	Details []validator.Violation `json:"details,omitempty"`
+}
+
+// errorResponse конверт ответа с ошибкой
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+// writeError выбирает HTTP статус по доменной ошибке; текст ошибок базы наружу не отдаётся
+func writeError(c *gin.Context, err error) {
+	var verr *validator.ValidationError
+	switch {
+	case errors.As(err, &verr):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, errorResponse{Error: errorBody{
+			Code:    codeValidationFailed,
+			Message: "order validation failed",
+			Details: verr.Violations,
+		}})
+	case errors.Is(err, order.ErrOrderNotFound):
+		abortWithError(c, http.StatusNotFound, codeOrderNotFound, order.ErrOrderNotFound.Error())
+	case errors.Is(err, order.ErrInvalidID):
+		abortWithError(c, http.StatusBadRequest, codeInvalidID, order.ErrInvalidID.Error())
+	case errors.Is(err, order.ErrInvalidFilter):
+		abortWithError(c, http.StatusBadRequest, codeInvalidFilter, order.ErrInvalidFilter.Error())
+	case errors.Is(err, order.ErrOrderExists):
+		abortWithError(c, http.StatusConflict, codeOrderExists, order.ErrOrderExists.Error())
+	case errors.Is(err, order.ErrTransactionExists):
+		abortWithError(c, http.StatusConflict, codeTransactionExists, order.ErrTransactionExists.Error())
+	case errors.Is(err, order.ErrConflict):
+		logError(c, err)
+		abortWithError(c, http.StatusConflict, codeConflict, order.ErrConflict.Error())
+	case errors.Is(err, order.ErrInvalidValue):
+		logError(c, err)
+		abortWithError(c, http.StatusUnprocessableEntity, codeInvalidValue, order.ErrInvalidValue.Error())
+	case errors.Is(err, order.ErrStorageUnavailable):
+		logError(c, err)
+		abortWithError(c, http.StatusServiceUnavailable, codeStorageUnavailable, order.ErrStorageUnavailable.Error())
+	default:
+		logError(c, err)
+		abortWithError(c, http.StatusInternalServerError, codeInternal, "internal error")
+	}
+}
+
+// abortWithError отдаёт конверт с ошибкой и прерывает цепочку обработчиков
+func abortWithError(c *gin.Context, status int, code, message string) {
+	c.AbortWithStatusJSON(status, errorResponse{Error: errorBody{Code: code, Message: message}})
+}
+
+// logError пишет полную ошибку в лог, раз клиент её не увидит
+func logError(c *gin.Context, err error) {
+	ctx := c.Request.Context()
+	logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "request failed",
+		zap.String("path", c.FullPath()), zap.Error(err))
+}
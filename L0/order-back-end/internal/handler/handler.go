@@ -1,9 +1,13 @@
 package order
 
 import (
+	"errors"
 	"net/http"
 	"order-back-end/internal/cache"
+	"order-back-end/internal/model"
 	order "order-back-end/internal/service"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -34,16 +38,88 @@ func (h *OrderHandler) GetOrder(c *gin.Context) {
 	// получаем orderInfo из service
 	orderInfo, err := h.service.GetOrderFromDB(ctx, orderIdStr, h.cache)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		writeError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, orderInfo)
 }
 
+// GetOrdersByTrack handler который реализует ручку GET /order/by-track/:track
+func (h *OrderHandler) GetOrdersByTrack(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	track := c.Param("track")
+
+	orders, err := h.service.GetOrdersByTrack(ctx, track, h.cache)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, orders)
+}
+
+// ListOrders handler который реализует ручку GET /order
+func (h *OrderHandler) ListOrders(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	filter, err := parseOrderFilter(c)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidFilter, err.Error())
+		return
+	}
+
+	page, err := h.service.ListOrders(ctx, filter)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// parseOrderFilter собирает фильтр из query параметров. date_created хранится в UTC в колонке без часового
+// пояса, поэтому границы дат со смещением приводятся к UTC, иначе база сравнит их местное время
+func parseOrderFilter(c *gin.Context) (model.OrderFilter, error) {
+	filter := model.OrderFilter{
+		CustomerID:      c.Query("customer_id"),
+		DeliveryService: c.Query("delivery_service"),
+		Locale:          c.Query("locale"),
+		Currency:        c.Query("currency"),
+		Cursor:          c.Query("cursor"),
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			return filter, errors.New("limit must be a positive integer")
+		}
+		filter.Limit = limit
+	}
+	if v := c.Query("date_from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, errors.New("date_from must be in RFC3339 format")
+		}
+		filter.DateFrom = t.UTC()
+	}
+	if v := c.Query("date_to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return filter, errors.New("date_to must be in RFC3339 format")
+		}
+		filter.DateTo = t.UTC()
+	}
+
+	return filter, nil
+}
+
 // RegisterRoutes регистрируем все ручки
 func (h *OrderHandler) RegisterRoutes() {
 	orderR := h.router.Group("/order")
 
+	orderR.GET("", h.ListOrders)
 	orderR.GET("/:id", h.GetOrder)
+	orderR.GET("/by-track/:track", h.GetOrdersByTrack)
 }
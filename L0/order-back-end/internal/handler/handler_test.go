@@ -0,0 +1,151 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"order-back-end/internal/cache"
+	"order-back-end/internal/model"
+	mock_order "order-back-end/internal/repository/mocks"
+	serv "order-back-end/internal/service"
+	"order-back-end/internal/validator"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderHandler_GetOrder_Errors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		id       string
+		repoErr  error
+		wantCode int
+		wantBody string
+	}{
+		{name: "not found", id: "missing", repoErr: pgx.ErrNoRows, wantCode: http.StatusNotFound, wantBody: "order_not_found"},
+		{name: "invalid id", id: "bad%20id", wantCode: http.StatusBadRequest, wantBody: "invalid_id"},
+		{name: "storage unavailable", id: "123", repoErr: &pgconn.PgError{Code: "08006", Message: "connection failure"}, wantCode: http.StatusServiceUnavailable, wantBody: "storage_unavailable"},
+		{name: "internal", id: "123", repoErr: errors.New("syntax error at or near"), wantCode: http.StatusInternalServerError, wantBody: "internal_error"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctr := gomock.NewController(t)
+			defer ctr.Finish()
+
+			repo := mock_order.NewMockRepo(ctr)
+			if tt.repoErr != nil {
+				repo.EXPECT().GetOrderFromDB(gomock.Any(), tt.id).Return(nil, tt.repoErr).Times(1)
+			}
+
+			router := gin.New()
+			h := NewHandler(serv.NewOrderService(repo), router, cache.NewCache(time.Second*10, 10))
+			h.RegisterRoutes()
+
+			req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/order/"+tt.id, nil)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			require.Equal(t, tt.wantCode, rec.Code)
+
+			var body errorResponse
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+			require.Equal(t, tt.wantBody, body.Error.Code)
+			if tt.repoErr != nil {
+				require.NotContains(t, rec.Body.String(), tt.repoErr.Error(), "database message must not leak")
+			}
+		})
+	}
+}
+
+func TestOrderHandler_ListOrders_DatesInUTC(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	repo := mock_order.NewMockRepo(gomock.NewController(t))
+	router := gin.New()
+	h := NewHandler(serv.NewOrderService(repo), router, cache.NewCache(time.Second*10, 10))
+	h.RegisterRoutes()
+
+	// 09:00 по Москве - это 06:00 UTC
+	repo.EXPECT().ListOrders(gomock.Any(), model.OrderFilter{
+		DateFrom: time.Date(2021, 11, 26, 6, 0, 0, 0, time.UTC),
+		DateTo:   time.Date(2021, 11, 27, 6, 0, 0, 0, time.UTC),
+	}).Return(&model.OrderPage{Orders: []model.OrderInfo{}}, nil)
+
+	req := httptest.NewRequestWithContext(context.Background(), http.MethodGet,
+		"/order?date_from=2021-11-26T09:00:00%2B03:00&date_to=2021-11-27T06:00:00Z", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestWriteErrorValidation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+
+	violations := []validator.Violation{
+		{Path: "order_uid", Rule: validator.RuleRequired, Message: "order_uid is required"},
+		{Path: "items[1].price", Rule: validator.RulePositive, Message: "items[1].price must be > 0"},
+	}
+	writeError(c, fmt.Errorf("decode order: %w", &validator.ValidationError{Violations: violations}))
+
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var body errorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, "validation_failed", body.Error.Code)
+	require.Equal(t, violations, body.Error.Details)
+}
+
+func TestWriteErrorClientConflicts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		err      error
+		wantCode int
+		wantBody string
+	}{
+		{err: serv.ErrOrderExists, wantCode: http.StatusConflict, wantBody: codeOrderExists},
+		{err: serv.ErrTransactionExists, wantCode: http.StatusConflict, wantBody: codeTransactionExists},
+		{err: serv.ErrConflict, wantCode: http.StatusConflict, wantBody: codeConflict},
+		{err: serv.ErrInvalidValue, wantCode: http.StatusUnprocessableEntity, wantBody: codeInvalidValue},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(rec)
+		c.Request = httptest.NewRequestWithContext(context.Background(), http.MethodPost, "/order", nil)
+		writeError(c, fmt.Errorf("CreateOrder: %w: %w", tt.err, &pgconn.PgError{Code: "23505", Message: "duplicate key"}))
+
+		require.Equal(t, tt.wantCode, rec.Code, tt.wantBody)
+		require.Equal(t, tt.wantBody, errorCode(t, rec))
+		require.NotContains(t, rec.Body.String(), "duplicate key", "database message must not leak")
+	}
+}
+
+func TestSchemaHandler_OrderSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	NewSchemaHandler(router, &validator.Validator{}, true).RegisterRoutes()
+
+	req := httptest.NewRequestWithContext(context.Background(), http.MethodGet, "/schema/order", nil)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "application/schema+json", rec.Header().Get("Content-Type"))
+	var schema map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &schema))
+	require.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+	require.Equal(t, false, schema["additionalProperties"])
+	require.Contains(t, schema["properties"], "order_uid")
+}
@@ -0,0 +1,43 @@
+package order
+
+import (
+	"net/http"
+	"order-back-end/internal/health"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthHandler ручки liveness и readiness проб для оркестратора
+type HealthHandler struct {
+	router  *gin.Engine
+	checker *health.Checker
+}
+
+// NewHealthHandler создает экземпляр HealthHandler
+func NewHealthHandler(router *gin.Engine, checker *health.Checker) *HealthHandler {
+	return &HealthHandler{
+		router:  router,
+		checker: checker,
+	}
+}
+
+// Liveness handler который реализует ручку GET /healthz: процесс жив и обслуживает запросы
+func (h *HealthHandler) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": health.StatusUp})
+}
+
+// Readiness handler который реализует ручку GET /readyz: все зависимости готовы принимать трафик
+func (h *HealthHandler) Readiness(c *gin.Context) {
+	report := h.checker.Run(c.Request.Context())
+	if report.Status != health.StatusUp {
+		c.JSON(http.StatusServiceUnavailable, report)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// RegisterRoutes регистрируем ручки проб
+func (h *HealthHandler) RegisterRoutes() {
+	h.router.GET("/healthz", h.Liveness)
+	h.router.GET("/readyz", h.Readiness)
+}
@@ -0,0 +1,267 @@
+package order
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"order-back-end/internal/codec"
+	"order-back-end/internal/idempotency"
+	"order-back-end/internal/model"
+	order "order-back-end/internal/service"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	codeInvalidBody            = "invalid_body"
+	codeInvalidQuery           = "invalid_query"
+	codeUnsupportedMediaType   = "unsupported_media_type"
+	codeInvalidIdempotencyKey  = "invalid_idempotency_key"
+	codeIdempotencyKeyReused   = "idempotency_key_reused"
+	codeIdempotencyKeyInFlight = "idempotency_key_in_progress"
+
+	// HeaderIdempotencyKey ключ, по которому повтор запроса получает сохранённый ответ, а не пишет заказ ещё раз
+	HeaderIdempotencyKey = "Idempotency-Key"
+	// headerIdempotentReplayed отмечает ответ, отданный из сохранённых
+	headerIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	maxIngestBodySize       = 10 << 20
+	// MaxBulkOrders сколько заказов принимает POST /orders за раз
+	MaxBulkOrders = 500
+)
+
+// orderIngester приём заказов; реализуется *service.IngestService
+type orderIngester interface {
+	CreateOrder(ctx context.Context, o *model.OrderInfo, replace bool) (order.IngestResult, error)
+	CreateOrders(ctx context.Context, orders []model.OrderInfo, replace bool) ([]order.IngestResult, error)
+}
+
+// IngestHandler ручки приёма заказов для партнёров без Kafka и ручной дозагрузки
+type IngestHandler struct {
+	router   *gin.Engine
+	ingest   orderIngester
+	decoders *codec.Decoders
+	keys     *idempotency.Store
+}
+
+// NewIngestHandler создает экземпляр IngestHandler; тело заказа разбирается декодерами консьюмеров
+func NewIngestHandler(router *gin.Engine, ingest orderIngester, decoders *codec.Decoders, keys *idempotency.Store) *IngestHandler {
+	return &IngestHandler{
+		router:   router,
+		ingest:   ingest,
+		decoders: decoders,
+		keys:     keys,
+	}
+}
+
+// CreateOrder handler который реализует ручку POST /order. Формат тела выбирается по Content-Type,
+// как формат сообщения по заголовку content-type в Kafka. 201 - заказ записан, 409 - заказ с таким order_uid
+// уже есть. С ?replace=true заказ заменяется, 200 - такой заказ уже сохранён
+func (h *IngestHandler) CreateOrder(c *gin.Context) {
+	replace, ok := replaceParam(c)
+	if !ok {
+		return
+	}
+	body, ok := readBody(c)
+	if !ok {
+		return
+	}
+
+	var o model.OrderInfo
+	if err := h.decoders.Decode(c.Request.Context(), c.GetHeader("Content-Type"), body, &o); err != nil {
+		writeDecodeError(c, err)
+		return
+	}
+
+	result, err := h.ingest.CreateOrder(c.Request.Context(), &o, replace)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	c.JSON(ingestStatusCode(result), result)
+}
+
+// CreateOrders handler который реализует ручку POST /orders с JSON массивом заказов. Заказы записываются
+// одной транзакцией; если хотя бы один не прошёл валидацию или, без ?replace=true, уже сохранён,
+// не записывается ни один
+func (h *IngestHandler) CreateOrders(c *gin.Context) {
+	replace, ok := replaceParam(c)
+	if !ok {
+		return
+	}
+	body, ok := readBody(c)
+	if !ok {
+		return
+	}
+
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidBody, "body must be a JSON array of orders")
+		return
+	}
+	if len(raw) == 0 || len(raw) > MaxBulkOrders {
+		abortWithError(c, http.StatusBadRequest, codeInvalidBody, "body must contain from 1 to "+strconv.Itoa(MaxBulkOrders)+" orders")
+		return
+	}
+
+	orders := make([]model.OrderInfo, len(raw))
+	for i, value := range raw {
+		if err := h.decoders.Decode(c.Request.Context(), codec.ContentTypeJSON, value, &orders[i]); err != nil {
+			abortWithError(c, http.StatusBadRequest, codeInvalidBody, "order ["+strconv.Itoa(i)+"]: "+err.Error())
+			return
+		}
+	}
+
+	results, err := h.ingest.CreateOrders(c.Request.Context(), orders, replace)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	status := http.StatusOK
+	for _, result := range results {
+		status = max(status, ingestStatusCode(result))
+	}
+	c.JSON(status, gin.H{"orders": results})
+}
+
+// RegisterRoutes регистрируем ручки приёма заказов
+func (h *IngestHandler) RegisterRoutes() {
+	h.router.POST("/order", Idempotency(h.keys), h.CreateOrder)
+	h.router.POST("/orders", Idempotency(h.keys), h.CreateOrders)
+}
+
+// ingestStatusCode 201 для записанного заказа, 200 для уже сохранённого
+func ingestStatusCode(result order.IngestResult) int {
+	if result.Status == order.IngestCreated {
+		return http.StatusCreated
+	}
+	return http.StatusOK
+}
+
+// replaceParam флаг ?replace=true, разрешающий заменить уже сохранённый заказ
+func replaceParam(c *gin.Context) (bool, bool) {
+	raw, ok := c.GetQuery("replace")
+	if !ok {
+		return false, true
+	}
+	replace, err := strconv.ParseBool(raw)
+	if err != nil {
+		abortWithError(c, http.StatusBadRequest, codeInvalidQuery, "replace must be true or false")
+		return false, false
+	}
+	return replace, true
+}
+
+// readBody читает тело запроса с ограничением размера
+func readBody(c *gin.Context) ([]byte, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxIngestBodySize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			abortWithError(c, http.StatusRequestEntityTooLarge, codeInvalidBody, "body is larger than "+strconv.Itoa(maxIngestBodySize)+" bytes")
+			return nil, false
+		}
+		abortWithError(c, http.StatusBadRequest, codeInvalidBody, "cannot read body")
+		return nil, false
+	}
+	return body, true
+}
+
+// writeDecodeError 415 для формата без декодера, 400 для тела, которое не удалось разобрать
+func writeDecodeError(c *gin.Context, err error) {
+	if errors.Is(err, codec.ErrUnsupportedContentType) {
+		abortWithError(c, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, err.Error())
+		return
+	}
+	abortWithError(c, http.StatusBadRequest, codeInvalidBody, err.Error())
+}
+
+// Idempotency middleware для ручек с заголовком Idempotency-Key: первый запрос с ключом выполняется,
+// его ответ сохраняется и отдаётся на повторы с тем же телом. Тот же ключ с другим телом или пока первый
+// запрос ещё выполняется - 409. Ответы 5xx не сохраняются, такой запрос можно повторить с тем же ключом
+func Idempotency(keys *idempotency.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(HeaderIdempotencyKey)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			abortWithError(c, http.StatusBadRequest, codeInvalidIdempotencyKey,
+				"Idempotency-Key must be at most "+strconv.Itoa(maxIdempotencyKeyLength)+" characters")
+			return
+		}
+
+		body, ok := readBody(c)
+		if !ok {
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ticket, response, state := keys.Begin(key, fingerprint(c, body))
+		switch state {
+		case idempotency.StateMismatch:
+			abortWithError(c, http.StatusConflict, codeIdempotencyKeyReused, "Idempotency-Key was already used with a different request")
+			return
+		case idempotency.StateInProgress:
+			abortWithError(c, http.StatusConflict, codeIdempotencyKeyInFlight, "request with this Idempotency-Key is still in progress")
+			return
+		case idempotency.StateCompleted:
+			c.Header(headerIdempotentReplayed, "true")
+			c.Data(response.Status, response.ContentType, response.Body)
+			c.Abort()
+			return
+		}
+
+		rec := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = rec
+		completed := false
+		defer func() {
+			if !completed {
+				keys.Release(ticket) // паника или 5xx: ответ не сохраняем
+			}
+		}()
+
+		c.Next()
+
+		if rec.Status() < http.StatusInternalServerError {
+			keys.Complete(ticket, idempotency.Response{
+				Status:      rec.Status(),
+				ContentType: rec.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+			})
+			completed = true
+		}
+	}
+}
+
+// fingerprint отпечаток запроса: метод, путь с параметрами и тело
+func fingerprint(c *gin.Context, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(c.Request.Method + " " + c.Request.URL.RequestURI() + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter копирует тело ответа, чтобы сохранить его под ключом идемпотентности
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
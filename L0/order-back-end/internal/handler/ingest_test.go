@@ -0,0 +1,180 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"order-back-end/internal/codec"
+	"order-back-end/internal/idempotency"
+	"order-back-end/internal/model"
+	serv "order-back-end/internal/service"
+	"order-back-end/internal/validator"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIngester считает записи; заказ без товаров не проходит валидацию, сохранённый заказ без replace - конфликт
+type fakeIngester struct {
+	calls int
+	saved map[string]bool
+}
+
+func (f *fakeIngester) CreateOrder(_ context.Context, o *model.OrderInfo, replace bool) (serv.IngestResult, error) {
+	results, err := f.CreateOrders(context.Background(), []model.OrderInfo{*o}, replace)
+	if err != nil {
+		return serv.IngestResult{}, err
+	}
+	return results[0], nil
+}
+
+func (f *fakeIngester) CreateOrders(_ context.Context, orders []model.OrderInfo, replace bool) ([]serv.IngestResult, error) {
+	f.calls++
+	for _, o := range orders {
+		if len(o.Items) == 0 {
+			return nil, &validator.ValidationError{Violations: []validator.Violation{
+				{Path: "items", Rule: validator.RuleMinItems, Message: "at least one item is required"},
+			}}
+		}
+		if f.saved[o.OrderUID] && !replace {
+			return nil, fmt.Errorf("CreateOrders: %w", serv.ErrOrderExists)
+		}
+	}
+	results := make([]serv.IngestResult, len(orders))
+	for i, o := range orders {
+		results[i] = serv.IngestResult{OrderUID: o.OrderUID, Status: serv.IngestUnchanged}
+		if !f.saved[o.OrderUID] {
+			f.saved[o.OrderUID] = true
+			results[i].Status = serv.IngestCreated
+		}
+	}
+	return results, nil
+}
+
+func newIngestRouter() (*gin.Engine, *fakeIngester) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ingest := &fakeIngester{saved: make(map[string]bool)}
+	NewIngestHandler(router, ingest, codec.NewDecoders(false), idempotency.NewStore(time.Hour, 0)).RegisterRoutes()
+	return router, ingest
+}
+
+func postIngest(router *gin.Engine, path, contentType, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequestWithContext(context.Background(), http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	if key != "" {
+		req.Header.Set(HeaderIdempotencyKey, key)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body errorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	return body.Error.Code
+}
+
+func TestIngestHandler_CreateOrder(t *testing.T) {
+	router, _ := newIngestRouter()
+
+	rec := postIngest(router, "/order", "application/json", "", `{"order_uid":"o1","items":[{"chrt_id":1}]}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.JSONEq(t, `{"order_uid":"o1","status":"created"}`, rec.Body.String())
+
+	// сохранённый заказ не перезаписывается молча
+	rec = postIngest(router, "/order", "application/json; charset=utf-8", "", `{"order_uid":"o1","items":[{"chrt_id":1}]}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Equal(t, codeOrderExists, errorCode(t, rec))
+
+	rec = postIngest(router, "/order?replace=true", "application/json", "", `{"order_uid":"o1","items":[{"chrt_id":1}]}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.JSONEq(t, `{"order_uid":"o1","status":"unchanged"}`, rec.Body.String())
+
+	rec = postIngest(router, "/order?replace=yes", "application/json", "", `{"order_uid":"o1","items":[{"chrt_id":1}]}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, codeInvalidQuery, errorCode(t, rec))
+
+	rec = postIngest(router, "/order", "application/json", "", `{"order_uid":"o2"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	var body errorResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Equal(t, codeValidationFailed, body.Error.Code)
+	require.Equal(t, "items", body.Error.Details[0].Path)
+
+	rec = postIngest(router, "/order", "text/plain", "", `order`)
+	require.Equal(t, http.StatusUnsupportedMediaType, rec.Code)
+	require.Equal(t, codeUnsupportedMediaType, errorCode(t, rec))
+
+	rec = postIngest(router, "/order", "application/json", "", `{"order_uid":`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, codeInvalidBody, errorCode(t, rec))
+}
+
+func TestIngestHandler_CreateOrders(t *testing.T) {
+	router, ingest := newIngestRouter()
+
+	rec := postIngest(router, "/orders", "application/json", "",
+		`[{"order_uid":"o1","items":[{"chrt_id":1}]},{"order_uid":"o2","items":[{"chrt_id":2}]}]`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	require.JSONEq(t, `{"orders":[{"order_uid":"o1","status":"created"},{"order_uid":"o2","status":"created"}]}`, rec.Body.String())
+
+	rec = postIngest(router, "/orders", "application/json", "", `[{"order_uid":"o1","items":[{"chrt_id":1}]}]`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Equal(t, codeOrderExists, errorCode(t, rec))
+
+	rec = postIngest(router, "/orders?replace=true", "application/json", "", `[{"order_uid":"o1","items":[{"chrt_id":1}]}]`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	for _, body := range []string{`[]`, `{"order_uid":"o1"}`, `[{"order_uid":1}]`} {
+		rec = postIngest(router, "/orders", "application/json", "", body)
+		require.Equal(t, http.StatusBadRequest, rec.Code, body)
+		require.Equal(t, codeInvalidBody, errorCode(t, rec))
+	}
+	require.Equal(t, 3, ingest.calls)
+}
+
+func TestIngestHandler_IdempotencyKey(t *testing.T) {
+	router, ingest := newIngestRouter()
+	const body = `{"order_uid":"o1","items":[{"chrt_id":1}]}`
+
+	first := postIngest(router, "/order", "application/json", "key-1", body)
+	require.Equal(t, http.StatusCreated, first.Code)
+
+	// повтор с тем же ключом получает тот же ответ, заказ не пишется ещё раз
+	replay := postIngest(router, "/order", "application/json", "key-1", body)
+	require.Equal(t, http.StatusCreated, replay.Code)
+	require.Equal(t, first.Body.String(), replay.Body.String())
+	require.Equal(t, "true", replay.Header().Get(headerIdempotentReplayed))
+	require.Equal(t, 1, ingest.calls)
+
+	rec := postIngest(router, "/order", "application/json", "key-1", `{"order_uid":"o2","items":[{"chrt_id":1}]}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Equal(t, codeIdempotencyKeyReused, errorCode(t, rec))
+
+	// тот же ключ на другой ручке или с другими параметрами - другой запрос
+	rec = postIngest(router, "/orders", "application/json", "key-1", "["+body+"]")
+	require.Equal(t, http.StatusConflict, rec.Code)
+	require.Equal(t, codeIdempotencyKeyReused, errorCode(t, rec))
+	rec = postIngest(router, "/order?replace=true", "application/json", "key-1", body)
+	require.Equal(t, codeIdempotencyKeyReused, errorCode(t, rec))
+
+	// ответ 422 тоже сохраняется
+	invalid := postIngest(router, "/order", "application/json", "key-2", `{"order_uid":"o3"}`)
+	require.Equal(t, http.StatusUnprocessableEntity, invalid.Code)
+	replay = postIngest(router, "/order", "application/json", "key-2", `{"order_uid":"o3"}`)
+	require.Equal(t, invalid.Body.String(), replay.Body.String())
+	require.Equal(t, 2, ingest.calls)
+
+	rec = postIngest(router, "/order", "application/json", strings.Repeat("k", maxIdempotencyKeyLength+1), body)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	require.Equal(t, codeInvalidIdempotencyKey, errorCode(t, rec))
+}
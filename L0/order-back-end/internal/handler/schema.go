@@ -0,0 +1,41 @@
+package order
+
+import (
+	"net/http"
+	"order-back-end/internal/jsonschema"
+
+	"github.com/gin-gonic/gin"
+)
+
+// orderSchema источник JSON Schema заказа; реализуется *validator.Validator
+type orderSchema interface {
+	Schema(closed bool) *jsonschema.Schema
+}
+
+// SchemaHandler ручки со схемами сообщений для продьюсеров
+type SchemaHandler struct {
+	router *gin.Engine
+	schema orderSchema
+	strict bool
+}
+
+// NewSchemaHandler создает экземпляр SchemaHandler; strict - при строгом разборе схема запрещает неизвестные поля
+func NewSchemaHandler(router *gin.Engine, schema orderSchema, strict bool) *SchemaHandler {
+	return &SchemaHandler{
+		router: router,
+		schema: schema,
+		strict: strict,
+	}
+}
+
+// OrderSchema handler который реализует ручку GET /schema/order. Схема строится по действующим правилам
+// валидации, поэтому после их перезагрузки меняется и она
+func (h *SchemaHandler) OrderSchema(c *gin.Context) {
+	c.Header("Content-Type", "application/schema+json")
+	c.JSON(http.StatusOK, h.schema.Schema(h.strict))
+}
+
+// RegisterRoutes регистрируем ручки схем
+func (h *SchemaHandler) RegisterRoutes() {
+	h.router.GET("/schema/order", h.OrderSchema)
+}
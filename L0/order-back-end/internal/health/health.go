@@ -0,0 +1,135 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Статусы компонентов и сервиса в целом
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// Check проверка готовности компонента, nil - компонент готов
+type Check func(ctx context.Context) error
+
+// ComponentStatus состояние одного компонента в ответе /readyz
+type ComponentStatus struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report итог проверки готовности
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+// namedCheck проверка вместе с именем компонента
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+// Checker набор проверок готовности сервиса
+type Checker struct {
+	mu      sync.RWMutex
+	checks  []namedCheck
+	timeout time.Duration
+}
+
+// NewChecker создаёт Checker, каждая проверка ограничена timeout
+func NewChecker(timeout time.Duration) *Checker {
+	return &Checker{timeout: timeout}
+}
+
+// Register добавляет проверку компонента name; повторная регистрация заменяет проверку,
+// так компонент можно заранее объявить через Flag, а настоящую проверку подставить после запуска
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.checks {
+		if c.checks[i].name == name {
+			c.checks[i].check = check
+			return
+		}
+	}
+	c.checks = append(c.checks, namedCheck{name: name, check: check})
+}
+
+// Run параллельно выполняет все проверки; сервис готов, только если готовы все компоненты
+func (c *Checker) Run(ctx context.Context) Report {
+	c.mu.RLock()
+	checks := make([]namedCheck, len(c.checks))
+	copy(checks, c.checks)
+	c.mu.RUnlock()
+
+	sort.Slice(checks, func(i, j int) bool { return checks[i].name < checks[j].name })
+
+	statuses := make([]ComponentStatus, len(checks))
+	var wg sync.WaitGroup
+	for i, nc := range checks {
+		wg.Add(1)
+		go func(i int, nc namedCheck) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := nc.check(checkCtx)
+			statuses[i] = ComponentStatus{Status: StatusUp, Duration: time.Since(start).String()}
+			if err != nil {
+				statuses[i].Status = StatusDown
+				statuses[i].Error = err.Error()
+			}
+		}(i, nc)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusUp, Components: make(map[string]ComponentStatus, len(checks))}
+	for i, nc := range checks {
+		report.Components[nc.name] = statuses[i]
+		if statuses[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	return report
+}
+
+// ErrPending компонент ещё не завершил инициализацию
+var ErrPending = errors.New("pending")
+
+// Flag готовность одноразового шага запуска: миграций, прогрева кэша и т.п.
+type Flag struct {
+	mu  sync.RWMutex
+	err error
+}
+
+// NewFlag создаёт флаг в состоянии ErrPending
+func NewFlag() *Flag {
+	return &Flag{err: ErrPending}
+}
+
+// Done отмечает шаг успешно завершённым
+func (f *Flag) Done() {
+	f.Set(nil)
+}
+
+// Set выставляет результат шага, ошибка попадёт в ответ /readyz
+func (f *Flag) Set(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.err = err
+}
+
+// Check реализует Check для регистрации в Checker
+func (f *Flag) Check(context.Context) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.err
+}
@@ -0,0 +1,55 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckerRun(t *testing.T) {
+	c := NewChecker(time.Second)
+
+	migrations := NewFlag()
+	c.Register("migrations", migrations.Check)
+	c.Register("postgres", func(context.Context) error { return nil })
+
+	report := c.Run(context.Background())
+	require.Equal(t, StatusDown, report.Status, "pending flag must keep service not ready")
+	require.Equal(t, StatusDown, report.Components["migrations"].Status)
+	require.Equal(t, ErrPending.Error(), report.Components["migrations"].Error)
+	require.Equal(t, StatusUp, report.Components["postgres"].Status)
+
+	migrations.Done()
+	report = c.Run(context.Background())
+	require.Equal(t, StatusUp, report.Status)
+}
+
+func TestCheckerRunTimeout(t *testing.T) {
+	c := NewChecker(10 * time.Millisecond)
+
+	c.Register("kafka", func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	c.Register("broken", func(context.Context) error { return errors.New("boom") })
+
+	report := c.Run(context.Background())
+	require.Equal(t, StatusDown, report.Status)
+	require.Contains(t, report.Components["kafka"].Error, "deadline exceeded")
+	require.Equal(t, "boom", report.Components["broken"].Error)
+}
+
+func TestCheckerRegisterReplaces(t *testing.T) {
+	c := NewChecker(time.Second)
+
+	c.Register("kafka_consumers", NewFlag().Check)
+	require.Equal(t, StatusDown, c.Run(context.Background()).Status)
+
+	c.Register("kafka_consumers", func(context.Context) error { return nil })
+	report := c.Run(context.Background())
+	require.Equal(t, StatusUp, report.Status)
+	require.Len(t, report.Components, 1)
+}
@@ -0,0 +1,159 @@
+package idempotency
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// State состояние ключа идемпотентности на момент запроса
+type State int
+
+const (
+	// StateNew ключ встретился впервые, запрос нужно выполнить
+	StateNew State = iota
+	// StateInProgress запрос с этим ключом ещё выполняется
+	StateInProgress
+	// StateMismatch ключ уже использован с другим запросом
+	StateMismatch
+	// StateCompleted запрос с этим ключом выполнен, нужно отдать сохранённый ответ
+	StateCompleted
+)
+
+// Response сохранённый ответ на запрос
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// Store ключи идемпотентности в памяти процесса: ответ на запрос хранится ttl с момента выполнения.
+// Ключи не переживают рестарт и не видны другим репликам. Сверх maxKeys вытесняются ключи,
+// выполненные раньше всех; ключи выполняющихся запросов не вытесняются
+type Store struct {
+	mu        sync.Mutex
+	entries   map[string]*entry
+	completed *list.List // ключи выполненных запросов в порядке выполнения
+	ttl       time.Duration
+	maxKeys   int
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// Ticket запись ключа, созданная Begin: Complete и Release действуют только на неё. Если запрос выполнялся
+// дольше ttl и ключ занял новый запрос, ответ старого запроса не попадёт в запись нового
+type Ticket struct {
+	key   string
+	entry *entry
+}
+
+type entry struct {
+	fingerprint string
+	done        bool
+	response    Response
+	expires     time.Time
+	elem        *list.Element
+}
+
+// NewStore создаёт хранилище ключей; maxKeys <= 0 - без ограничения числа ключей
+func NewStore(ttl time.Duration, maxKeys int) *Store {
+	return &Store{
+		entries:   make(map[string]*entry),
+		completed: list.New(),
+		ttl:       ttl,
+		maxKeys:   maxKeys,
+		now:       time.Now,
+	}
+}
+
+// Begin занимает ключ под запрос с отпечатком fingerprint. Ответ возвращается только для StateCompleted,
+// Ticket - только для StateNew
+func (s *Store) Begin(key, fingerprint string) (Ticket, Response, State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	e, ok := s.entries[key]
+	switch {
+	case !ok || now.After(e.expires):
+		if ok {
+			s.remove(key)
+		}
+		s.evict()
+		e = &entry{fingerprint: fingerprint, expires: now.Add(s.ttl)}
+		s.entries[key] = e
+		return Ticket{key: key, entry: e}, Response{}, StateNew
+	case e.fingerprint != fingerprint:
+		return Ticket{}, Response{}, StateMismatch
+	case !e.done:
+		return Ticket{}, Response{}, StateInProgress
+	default:
+		return Ticket{}, e.response, StateCompleted
+	}
+}
+
+// Complete сохраняет ответ на запрос, занявший ключ
+func (s *Store) Complete(t Ticket, response Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if e := s.owned(t); e != nil {
+		e.done, e.response, e.expires = true, response, s.now().Add(s.ttl)
+		e.elem = s.completed.PushBack(t.key)
+	}
+}
+
+// Release освобождает ключ без ответа: запрос не выполнен и его можно повторить с тем же ключом
+func (s *Store) Release(t Ticket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.owned(t) != nil {
+		s.remove(t.key)
+	}
+}
+
+// owned запись ключа, если она всё ещё принадлежит запросу t и он не выполнен
+func (s *Store) owned(t Ticket) *entry {
+	if e, ok := s.entries[t.key]; ok && e == t.entry && !e.done {
+		return e
+	}
+	return nil
+}
+
+// Len число хранимых ключей
+func (s *Store) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// sweep удаляет просроченные ключи не чаще раза в минуту
+func (s *Store) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for key, e := range s.entries {
+		if now.After(e.expires) {
+			s.remove(key)
+		}
+	}
+}
+
+// evict освобождает место под новый ключ, удаляя ключи, выполненные раньше всех
+func (s *Store) evict() {
+	for s.maxKeys > 0 && len(s.entries) >= s.maxKeys && s.completed.Len() > 0 {
+		s.remove(s.completed.Front().Value.(string))
+	}
+}
+
+// remove удаляет ключ вместе с его местом в очереди выполненных
+func (s *Store) remove(key string) {
+	if e, ok := s.entries[key]; ok && e.elem != nil {
+		s.completed.Remove(e.elem)
+	}
+	delete(s.entries, key)
+}
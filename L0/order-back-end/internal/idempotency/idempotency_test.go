@@ -0,0 +1,123 @@
+package idempotency
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewStore(time.Hour, 0)
+	s.now = func() time.Time { return now }
+
+	ticket, _, state := s.Begin("key", "a")
+	require.Equal(t, StateNew, state)
+
+	_, _, state = s.Begin("key", "a")
+	require.Equal(t, StateInProgress, state)
+	_, _, state = s.Begin("key", "b")
+	require.Equal(t, StateMismatch, state)
+
+	created := Response{Status: http.StatusCreated, ContentType: "application/json", Body: []byte(`{}`)}
+	s.Complete(ticket, created)
+	_, response, state := s.Begin("key", "a")
+	require.Equal(t, StateCompleted, state)
+	require.Equal(t, created, response)
+
+	// после ttl ключ можно использовать заново
+	now = now.Add(2 * time.Hour)
+	_, _, state = s.Begin("key", "b")
+	require.Equal(t, StateNew, state)
+}
+
+func TestStoreStaleTicket(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewStore(time.Hour, 0)
+	s.now = func() time.Time { return now }
+
+	stale, _, _ := s.Begin("key", "a")
+	// запрос выполняется дольше ttl, ключ занимает новый запрос
+	now = now.Add(2 * time.Hour)
+	fresh, _, state := s.Begin("key", "a")
+	require.Equal(t, StateNew, state)
+
+	// ответ и отмена старого запроса не касаются записи нового
+	s.Complete(stale, Response{Status: http.StatusCreated, Body: []byte(`stale`)})
+	s.Release(stale)
+	_, _, state = s.Begin("key", "a")
+	require.Equal(t, StateInProgress, state)
+
+	s.Complete(fresh, Response{Status: http.StatusCreated, Body: []byte(`fresh`)})
+	_, response, state := s.Begin("key", "a")
+	require.Equal(t, StateCompleted, state)
+	require.Equal(t, []byte(`fresh`), response.Body)
+}
+
+func TestStoreRelease(t *testing.T) {
+	s := NewStore(time.Hour, 0)
+
+	ticket, _, state := s.Begin("key", "a")
+	require.Equal(t, StateNew, state)
+	s.Release(ticket)
+	ticket, _, state = s.Begin("key", "a")
+	require.Equal(t, StateNew, state)
+
+	// выполненный запрос Release не отменяет
+	s.Complete(ticket, Response{Status: http.StatusOK})
+	s.Release(ticket)
+	_, _, state = s.Begin("key", "a")
+	require.Equal(t, StateCompleted, state)
+}
+
+func TestStoreSweepsExpiredKeys(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := NewStore(time.Minute, 0)
+	s.now = func() time.Time { return now }
+
+	for _, key := range []string{"a", "b", "c"} {
+		ticket, _, _ := s.Begin(key, key)
+		s.Complete(ticket, Response{Status: http.StatusCreated})
+	}
+	require.Equal(t, 3, s.Len())
+
+	now = now.Add(2 * time.Minute)
+	s.Begin("d", "d")
+	require.Equal(t, 1, s.Len())
+}
+
+func TestStoreEvictsOldestCompletedKeys(t *testing.T) {
+	s := NewStore(time.Hour, 3)
+
+	s.Begin("in-flight", "in-flight")
+	for _, key := range []string{"a", "b"} {
+		ticket, _, _ := s.Begin(key, key)
+		s.Complete(ticket, Response{Status: http.StatusCreated})
+	}
+
+	// место освобождает ключ, выполненный раньше всех, а не выполняющийся запрос
+	c, _, state := s.Begin("c", "c")
+	require.Equal(t, StateNew, state)
+	require.Equal(t, 3, s.Len())
+	_, _, state = s.Begin("in-flight", "in-flight")
+	require.Equal(t, StateInProgress, state)
+	_, _, state = s.Begin("b", "b")
+	require.Equal(t, StateCompleted, state)
+
+	s.Complete(c, Response{Status: http.StatusCreated})
+	_, _, state = s.Begin("a", "a")
+	require.Equal(t, StateNew, state, "evicted key starts over")
+	require.Equal(t, 3, s.Len())
+	_, _, state = s.Begin("c", "c")
+	require.Equal(t, StateCompleted, state)
+
+	// когда выполненных ключей не осталось, лимит временно превышается: выполняющиеся запросы не теряются
+	s.Begin("d", "d")
+	_, _, state = s.Begin("e", "e")
+	require.Equal(t, StateNew, state)
+	require.Equal(t, 4, s.Len())
+	_, _, state = s.Begin("in-flight", "in-flight")
+	require.Equal(t, StateInProgress, state)
+}
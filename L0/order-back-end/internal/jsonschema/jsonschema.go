@@ -0,0 +1,131 @@
+package jsonschema
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Draft версия спецификации JSON Schema
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema схема JSON Schema draft 2020-12 с ключевыми словами, которые нужны для заказа
+type Schema struct {
+	Schema      string `json:"$schema,omitempty"`
+	ID          string `json:"$id,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *bool              `json:"additionalProperties,omitempty"`
+
+	Items    *Schema `json:"items,omitempty"`
+	MinItems *int    `json:"minItems,omitempty"`
+	MaxItems *int    `json:"maxItems,omitempty"`
+
+	MinLength *int     `json:"minLength,omitempty"`
+	MaxLength *int     `json:"maxLength,omitempty"`
+	Pattern   string   `json:"pattern,omitempty"`
+	Enum      []string `json:"enum,omitempty"`
+	Const     *string  `json:"const,omitempty"`
+
+	AllOf []*Schema `json:"allOf,omitempty"`
+	AnyOf []*Schema `json:"anyOf,omitempty"`
+
+	Minimum          *int64 `json:"minimum,omitempty"`
+	Maximum          *int64 `json:"maximum,omitempty"`
+	ExclusiveMinimum *int64 `json:"exclusiveMinimum,omitempty"`
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Generate строит схему типа по json тегам полей. Поля без тега называются как в Go, поля с тегом "-" пропускаются.
+// closed запрещает в объектах свойства, которых нет в структуре
+func Generate(typ reflect.Type, closed bool) *Schema {
+	switch {
+	case typ == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case typ.Kind() == reflect.Pointer:
+		return Generate(typ.Elem(), closed)
+	}
+
+	switch typ.Kind() {
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &Schema{Type: "array", Items: Generate(typ.Elem(), closed)}
+	case reflect.Struct:
+		s := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+		for i := range typ.NumField() {
+			if name, ok := FieldName(typ.Field(i)); ok {
+				s.Properties[name] = Generate(typ.Field(i).Type, closed)
+			}
+		}
+		if closed {
+			s.AdditionalProperties = new(bool)
+		}
+		return s
+	default:
+		return &Schema{}
+	}
+}
+
+// FieldName имя поля структуры в JSON; false для неэкспортируемых полей и полей с тегом "-"
+func FieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	}
+	return name, true
+}
+
+// Property схема свойства по пути вида payment.amount; items[].price - свойство элемента массива items
+func (s *Schema) Property(path string) *Schema {
+	for _, part := range strings.Split(path, ".") {
+		name, each := strings.CutSuffix(part, "[]")
+		if s = s.Properties[name]; s == nil {
+			return nil
+		}
+		if each {
+			if s = s.Items; s == nil {
+				return nil
+			}
+		}
+	}
+	return s
+}
+
+// Require отмечает свойство path обязательным в объекте, которому оно принадлежит, вместе с объектами на пути к нему
+func (s *Schema) Require(path string) {
+	parent := s
+	if i := strings.LastIndexByte(path, '.'); i >= 0 {
+		s.Require(strings.TrimSuffix(path[:i], "[]"))
+		parent = s.Property(path[:i])
+		path = path[i+1:]
+	}
+	if parent == nil || parent.Properties[path] == nil {
+		return
+	}
+	for _, name := range parent.Required {
+		if name == path {
+			return
+		}
+	}
+	parent.Required = append(parent.Required, path)
+}
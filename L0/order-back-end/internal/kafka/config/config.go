@@ -1,8 +1,55 @@
 package kfk
 
+import (
+	"order-back-end/internal/retry"
+	"order-back-end/internal/schemaregistry"
+	"time"
+)
+
 // Config для kafka
 type Config struct {
-	Brokers []string `yaml:"brokers"`
-	Topic   string   `yaml:"topic"`
-	GroupID string   `yaml:"group_id"`
+	// Transport kafka - кластер из Brokers, memory - in-process брокер для локального запуска без Kafka
+	Transport string   `yaml:"transport" env-default:"kafka"`
+	Brokers   []string `yaml:"brokers"`
+	Topic     string   `yaml:"topic"`
+	GroupID   string   `yaml:"group_id"`
+	// DLQTopic топик для отклонённых сообщений, пустой - DLQ выключен
+	DLQTopic string `yaml:"dlq_topic"`
+	// Retry повторы сохранения при временных ошибках базы
+	Retry retry.Backoff `yaml:"retry"`
+	// Consumer настройки пула консьюмеров
+	Consumer ConsumerConfig `yaml:"consumer"`
+	// SchemaRegistry реестр схем для сообщений в Avro; без адреса принимаются только JSON и Protobuf
+	SchemaRegistry schemaregistry.Config `yaml:"schema_registry"`
+	// StrictDecoding отклонять сообщения с полями, которых нет в заказе, и значениями другого типа
+	StrictDecoding bool `yaml:"strict_decoding" env:"KAFKA_STRICT_DECODING" env-default:"false"`
+}
+
+// DefaultConsumerCount сколько консьюмеров запускать, если ключ consumer.count в конфиге не задан
+const DefaultConsumerCount = 3
+
+// ConsumerConfig настройки пула консьюмеров группы GroupID
+type ConsumerConfig struct {
+	// Count сколько консьюмеров запускать при старте; 0 - пул стартует пустым и масштабируется через
+	// admin ручку. Без ключа в конфиге - DefaultConsumerCount
+	Count int `yaml:"count"`
+	// MaxCount верхняя граница при масштабировании через admin ручку;
+	// консьюмеры сверх числа партиций топика простаивают
+	MaxCount int `yaml:"max_count" env-default:"12"`
+
+	SessionTimeout    time.Duration `yaml:"session_timeout" env-default:"45s"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval" env-default:"3s"`
+	MaxPollInterval   time.Duration `yaml:"max_poll_interval" env-default:"5m"`
+	// AutoOffsetReset откуда читать партицию без закоммиченного offset'а: earliest или latest
+	AutoOffsetReset string `yaml:"auto_offset_reset" env-default:"earliest"`
+
+	// CommitStrategy auto - периодический коммит сохранённых offset'ов раз в AutoCommitInterval,
+	// sync - синхронный коммит после каждого обработанного сообщения
+	CommitStrategy     string        `yaml:"commit_strategy" env-default:"auto"`
+	AutoCommitInterval time.Duration `yaml:"auto_commit_interval" env-default:"5s"`
+
+	// BatchSize сколько сообщений копить и записывать в базу одной транзакцией; 1 - по одному сообщению
+	BatchSize int `yaml:"batch_size" env-default:"1"`
+	// BatchMaxWait сколько ждать добора пачки с первого сообщения, прежде чем записать неполную
+	BatchMaxWait time.Duration `yaml:"batch_max_wait" env-default:"100ms"`
 }
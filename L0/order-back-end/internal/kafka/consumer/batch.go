@@ -0,0 +1,100 @@
+package kfk
+
+import (
+	"context"
+	"order-back-end/internal/logger"
+	"order-back-end/internal/metrics"
+	"order-back-end/internal/model"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"go.uber.org/zap"
+)
+
+// handleBatch валидирует пачку и записывает все корректные заказы одной транзакцией.
+// Если транзакция не удалась, пачка разбирается по одному сообщению: так временная ошибка уходит
+// в повторы с паузой партиции, а заказ с постоянной ошибкой - в DLQ, не утягивая за собой остальные
+func (c *Consumer) handleBatch(ctx context.Context, batch []*kafka.Message) {
+	metrics.ConsumerBatch(c.consumerNumber, len(batch))
+
+	orders := make([]*model.OrderInfo, len(batch))
+	invalid := make([]error, len(batch))
+	stages := make([]string, len(batch))
+	for i, kafkaMsg := range batch {
+		var order model.OrderInfo
+		stage, err := c.decode(ctx, kafkaMsg, &order)
+		if err != nil && isTransientDecode(err) {
+			// сообщение придётся отложить, а более поздние заказы его партиции не должны записаться раньше него
+			logger.GetOrCreateLoggerFromCtx(ctx).Warn(ctx, "schema registry unavailable, processing messages one by one",
+				zap.Int("consumer", c.consumerNumber), zap.Int("messages", len(batch)), zap.Error(err))
+			c.handleEach(ctx, batch)
+			return
+		}
+		if err != nil {
+			invalid[i], stages[i] = err, stage
+			continue
+		}
+		orders[i] = &order
+	}
+
+	latest := latestVersions(orders)
+	changed, err := c.persistBatch(ctx, latest)
+	if err != nil {
+		logger.GetOrCreateLoggerFromCtx(ctx).Warn(ctx, "batch write failed, processing messages one by one",
+			zap.Int("consumer", c.consumerNumber), zap.Int("messages", len(batch)), zap.Error(err))
+		c.handleEach(ctx, batch)
+		return
+	}
+
+	for i, kafkaMsg := range batch {
+		if _, paused := c.pending[kafkaMsg.TopicPartition.Partition]; paused {
+			// offset'ы после отложенного сообщения не сохраняем; записанные заказы при повторном
+			// чтении совпадут по хэшу и ничего не изменят
+			continue
+		}
+		if invalid[i] != nil {
+			metrics.ConsumerMessage(c.consumerNumber, metrics.StageRejected)
+			if err := c.deadLetter(ctx, kafkaMsg, stages[i], invalid[i]); err != nil {
+				c.postpone(ctx, kafkaMsg, 0, err)
+				continue
+			}
+			c.storeOffset(ctx, kafkaMsg)
+			continue
+		}
+
+		metrics.ConsumerMessage(c.consumerNumber, metrics.StageValidated)
+		uid := orders[i].OrderUID
+		// в кэш кладём записанную версию: если в пачке несколько версий заказа, это последняя.
+		// Незаписанная версия может быть старше сохранённой, её из кэша убираем
+		if changed[uid] {
+			c.cache.Set(uid, latest[uid])
+			metrics.ConsumerMessage(c.consumerNumber, metrics.StagePersisted)
+		} else {
+			c.cache.Delete(uid)
+			metrics.ConsumerMessage(c.consumerNumber, metrics.StageDuplicate)
+		}
+		c.storeOffset(ctx, kafkaMsg)
+	}
+}
+
+// handleEach обрабатывает сообщения пачки по одному
+func (c *Consumer) handleEach(ctx context.Context, batch []*kafka.Message) {
+	for _, kafkaMsg := range batch {
+		c.handleMessage(ctx, kafkaMsg)
+	}
+}
+
+// latestVersions последняя версия каждого заказа пачки; nil - сообщения, не прошедшие валидацию
+func latestVersions(orders []*model.OrderInfo) map[string]model.OrderInfo {
+	latest := make(map[string]model.OrderInfo, len(orders))
+	for _, order := range orders {
+		if order != nil {
+			latest[order.OrderUID] = *order
+		}
+	}
+	return latest
+}
+
+// persistBatch записывает заказы одной транзакцией; возвращает, какие заказы изменились
+func (c *Consumer) persistBatch(ctx context.Context, orders map[string]model.OrderInfo) (map[string]bool, error) {
+	return c.repo.UpsertOrders(ctx, orders)
+}
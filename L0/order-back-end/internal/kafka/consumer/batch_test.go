@@ -0,0 +1,68 @@
+package kfk
+
+import (
+	"context"
+	"testing"
+
+	"order-back-end/internal/model"
+	order "order-back-end/internal/repository"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatestVersions(t *testing.T) {
+	first, second, other := testOrder(100), testOrder(200), testOrder(300)
+	other.OrderUID = "other-order"
+
+	latest := latestVersions([]*model.OrderInfo{&first, nil, &second, &other})
+
+	require.Len(t, latest, 2)
+	require.Equal(t, 200, latest[first.OrderUID].Payment.Amount, "the last version in the batch wins")
+	require.Equal(t, 300, latest[other.OrderUID].Payment.Amount)
+}
+
+func TestPersistBatch(t *testing.T) {
+	db := newFakeDB()
+	c := newTestConsumer(db)
+	ctx := context.Background()
+
+	first, other := testOrder(100), testOrder(300)
+	other.OrderUID = "other-order"
+	orders := map[string]model.OrderInfo{first.OrderUID: first, other.OrderUID: other}
+
+	changed, err := c.persistBatch(ctx, orders)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{first.OrderUID: true, other.OrderUID: true}, changed)
+	require.Len(t, db.committed, 2)
+
+	// повтор пачки с одним изменённым заказом переписывает только его
+	updated := testOrder(150)
+	orders[first.OrderUID] = updated
+	changed, err = c.persistBatch(ctx, orders)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{first.OrderUID: true}, changed)
+
+	hash, err := order.ContentHash(updated)
+	require.NoError(t, err)
+	require.Equal(t, hash, db.committed[first.OrderUID])
+}
+
+func TestPersistBatchIsAtomic(t *testing.T) {
+	stages := []string{failBegin, failOrder, failDelivery, failPayment, failDelItems, failCopy, failCommit}
+
+	for _, stage := range stages {
+		t.Run(stage, func(t *testing.T) {
+			db := newFakeDB()
+			c := newTestConsumer(db)
+
+			first, other := testOrder(100), testOrder(300)
+			other.OrderUID = "other-order"
+
+			db.failAt, db.failErr = stage, &pgconn.PgError{Code: "40P01"}
+			_, err := c.persistBatch(context.Background(), map[string]model.OrderInfo{first.OrderUID: first, other.OrderUID: other})
+			require.Error(t, err)
+			require.Empty(t, db.committed, "a failed batch must not commit any order")
+		})
+	}
+}
@@ -1,227 +1,547 @@
 package kfk
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
+	"maps"
 	"order-back-end/internal/cache"
+	"order-back-end/internal/codec"
+	kfkcfg "order-back-end/internal/kafka/config"
+	"order-back-end/internal/kafka/dlq"
+	"order-back-end/internal/kafka/transport"
 	"order-back-end/internal/logger"
+	"order-back-end/internal/metrics"
 	"order-back-end/internal/model"
+	"order-back-end/internal/postgres"
+	order "order-back-end/internal/repository"
+	"order-back-end/internal/retry"
+	"order-back-end/internal/schemaregistry"
 	"order-back-end/internal/validator"
+	"slices"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
-	sq "github.com/Masterminds/squirrel"
 	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
-	"github.com/jackc/pgx/v5"
-	"github.com/jackc/pgx/v5/pgxpool"
 	"go.uber.org/zap"
 )
 
-// Consumer дополненая структура с db и cache
+// readTimeout сколько ждать сообщение, прежде чем снова проверить отмену контекста
+const readTimeout = 500 * time.Millisecond
+
+// Consumer дополненая структура с repo и cache
 type Consumer struct {
-	consumer       *kafka.Consumer
-	db             *pgxpool.Pool
+	consumer       transport.MessageSource
+	repo           order.Repo
 	cache          cache.Cache
-	stop           bool
+	dlq            deadLetterPublisher
+	decoders       *codec.Decoders
+	rules          *validator.Validator
 	consumerNumber int
+	assigned       atomic.Int32 // число партиций, назначенных консьюмеру при ребалансировке
+	commit         CommitStrategy
+	batchSize      int           // сколько сообщений копить для записи одной транзакцией, 1 - без пачек
+	batchWait      time.Duration // сколько ждать добора пачки с первого сообщения
+
+	backoff retry.Backoff
+	// pending сообщения, ожидающие повтора, по партициям; партиция стоит на паузе, пока её сообщение не обработано.
+	// Доступ только из горутины Start (rebalance вызывается из ReadMessage)
+	pending map[int32]*pendingMessage
+
+	// stuck партиции, сообщение которых исчерпало попытки и не ушло в DLQ: партиция остаётся на паузе,
+	// сообщение повторяется с максимальной задержкой. Читается из Status другой горутиной
+	stuckMu sync.Mutex
+	stuck   map[int32]StuckPartition
 }
 
-// NewConsumer создаем экземпляр Consumer куда прокидывыем db и cache
-func NewConsumer(brokers []string, topic, consumerGroup string, db *pgxpool.Pool, cache cache.Cache, consInt int) (*Consumer, error) {
-	cfg := &kafka.ConfigMap{
-		"bootstrap.servers":        strings.Join(brokers, ","),
-		"group.id":                 consumerGroup,
-		"enable.auto.offset.store": false,
-		"enable.auto.commit":       true,
-		"auto.commit.interval.ms":  5000,
-		"auto.offset.reset":        "earliest",
-	}
+// StuckPartition партиция, которая стоит на паузе из-за сообщения, исчерпавшего попытки
+type StuckPartition struct {
+	Partition int32  `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Attempts  int    `json:"attempts"`
+	Error     string `json:"error"`
+}
+
+// deadLetterPublisher публикует отклонённые сообщения; реализуется *dlq.DeadLetters, nil - DLQ выключен
+type deadLetterPublisher interface {
+	Publish(ctx context.Context, msg *kafka.Message, stage string, reason error) error
+}
 
-	c, err := kafka.NewConsumer(cfg)
+// pendingMessage сообщение, сохранение которого упало с временной ошибкой
+type pendingMessage struct {
+	msg     *kafka.Message
+	attempt int // номер последней попытки, с нуля
+	retryAt time.Time
+}
+
+// errRetry сообщение не обработано, но его стоит повторить позже
+var errRetry = errors.New("retry later")
+
+// NewConsumer создаем экземпляр Consumer куда прокидывыем repo и cache; сообщения читаются через транспорт tr
+// и разбираются декодером из decoders по заголовку content-type, заказы проверяются валидатором rules
+func NewConsumer(tr transport.Transport, cfg kfkcfg.Config, repo order.Repo, cache cache.Cache, deadLetters *dlq.DeadLetters, decoders *codec.Decoders, rules *validator.Validator, consInt int) (*Consumer, error) {
+	strategy, err := ParseCommitStrategy(cfg.Consumer.CommitStrategy)
 	if err != nil {
-		return nil, fmt.Errorf("error creating kafka consumer: %w", err)
+		return nil, err
 	}
 
-	err = c.Subscribe(topic, nil)
+	c, err := tr.NewSource(configMap(cfg, strategy))
 	if err != nil {
-		return nil, fmt.Errorf("failed to subscribe to topic %s: %w", topic, err)
+		return nil, fmt.Errorf("error creating kafka consumer: %w", err)
 	}
 
-	return &Consumer{
+	consumer := &Consumer{
 		consumer:       c,
-		db:             db,
+		repo:           repo,
 		cache:          cache,
-		stop:           false,
+		dlq:            deadLetters,
+		decoders:       decoders,
+		rules:          rules,
 		consumerNumber: consInt,
-	}, nil
+		commit:         strategy,
+		batchSize:      max(cfg.Consumer.BatchSize, 1),
+		batchWait:      cfg.Consumer.BatchMaxWait,
+		backoff:        cfg.Retry,
+		pending:        make(map[int32]*pendingMessage),
+	}
+
+	err = c.Subscribe(cfg.Topic, consumer.rebalance)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to subscribe to topic %s: %w", cfg.Topic, err)
+	}
+
+	return consumer, nil
+}
+
+// configMap настройки librdkafka для консьюмера группы cfg.GroupID.
+// Offset сохраняется вручную только после обработки сообщения при любой стратегии коммита
+func configMap(cfg kfkcfg.Config, strategy CommitStrategy) *kafka.ConfigMap {
+	cc := cfg.Consumer
+	m := &kafka.ConfigMap{
+		"bootstrap.servers":        strings.Join(cfg.Brokers, ","),
+		"group.id":                 cfg.GroupID,
+		"enable.auto.offset.store": false,
+		"enable.auto.commit":       strategy == CommitAuto,
+		"auto.offset.reset":        cc.AutoOffsetReset,
+		"session.timeout.ms":       int(cc.SessionTimeout.Milliseconds()),
+		"heartbeat.interval.ms":    int(cc.HeartbeatInterval.Milliseconds()),
+		"max.poll.interval.ms":     int(cc.MaxPollInterval.Milliseconds()),
+	}
+	if strategy == CommitAuto {
+		_ = m.SetKey("auto.commit.interval.ms", int(cc.AutoCommitInterval.Milliseconds()))
+	}
+	return m
+}
+
+// rebalance запоминает, сколько партиций сейчас назначено консьюмеру
+func (c *Consumer) rebalance(_ *kafka.Consumer, ev kafka.Event) error {
+	switch e := ev.(type) {
+	case kafka.AssignedPartitions:
+		c.assigned.Store(int32(len(e.Partitions)))
+	case kafka.RevokedPartitions:
+		c.assigned.Store(0)
+		// offset'ы отложенных сообщений не сохранены, новый владелец партиции прочитает их заново
+		for _, tp := range e.Partitions {
+			delete(c.pending, tp.Partition)
+			c.clearStuck(tp.Partition)
+		}
+	}
+	return nil
+}
+
+// Number порядковый номер консьюмера в пуле, им же помечены метрики
+func (c *Consumer) Number() int {
+	return c.consumerNumber
+}
+
+// Partitions число партиций, назначенных консьюмеру
+func (c *Consumer) Partitions() int {
+	return int(c.assigned.Load())
+}
+
+// Assigned true, если консьюмеру назначена хотя бы одна партиция
+func (c *Consumer) Assigned() bool {
+	return c.assigned.Load() > 0
 }
 
-func (c *Consumer) Start() {
-	ctx := context.Background()
+// Start читает сообщения до отмены ctx, после чего коммитит сохранённые offset'ы и закрывает консьюмера
+func (c *Consumer) Start(ctx context.Context) error {
 	log := logger.GetOrCreateLoggerFromCtx(ctx)
-	for {
-		if c.stop {
-			break
+	defer func() {
+		if err := c.close(); err != nil {
+			log.Error(ctx, "error closing consumer", zap.Int("consumer", c.consumerNumber), zap.Error(err))
+		}
+	}()
+
+	for ctx.Err() == nil {
+		c.retryPending(ctx)
+
+		batch := c.readBatch(ctx)
+		switch len(batch) {
+		case 0:
+		case 1:
+			c.handleMessage(ctx, batch[0])
+		default:
+			c.handleBatch(ctx, batch)
 		}
-		kafkaMsg, err := c.consumer.ReadMessage(-1)
+		c.commitStored(ctx)
+	}
+	return ctx.Err()
+}
+
+// readBatch читает до batchSize сообщений, но ждёт не дольше batchWait с первого сообщения пачки.
+// Без пакетного режима (batchSize 1) возвращает одно сообщение
+func (c *Consumer) readBatch(ctx context.Context) []*kafka.Message {
+	log := logger.GetOrCreateLoggerFromCtx(ctx)
+	var (
+		batch    []*kafka.Message
+		deadline time.Time
+	)
+	for len(batch) < max(c.batchSize, 1) && ctx.Err() == nil {
+		// ограничиваем ожидание, чтобы регулярно проверять отмену контекста и отложенные сообщения
+		timeout := c.pollTimeout()
+		if len(batch) > 0 {
+			left := time.Until(deadline)
+			if left <= 0 {
+				break
+			}
+			timeout = min(timeout, left)
+		}
+
+		kafkaMsg, err := c.consumer.ReadMessage(timeout)
 		if err != nil {
+			var kErr kafka.Error
+			if errors.As(err, &kErr) && kErr.Code() == kafka.ErrTimedOut {
+				if len(batch) == 0 {
+					return nil
+				}
+				continue
+			}
 			log.Error(ctx, fmt.Sprintf("Error reading message from consumer: %v", err))
 		}
 		if kafkaMsg == nil {
 			continue
 		}
-		if err = c.prepareMessage(kafkaMsg); err != nil {
-			log.Error(ctx, fmt.Sprintf("Error to transwer message to db from consumer: %v", err))
+		if _, paused := c.pending[kafkaMsg.TopicPartition.Partition]; paused {
+			// выбрано до паузы; прочитаем заново после seek при снятии паузы
 			continue
 		}
-		// сохраняем offset сообщения
-		if _, err := c.consumer.StoreMessage(kafkaMsg); err != nil {
-			log.Error(ctx, fmt.Sprintf("Error storing message in consumer: %v", err))
-			continue
+		metrics.ConsumerMessage(c.consumerNumber, metrics.StageConsumed)
+		c.reportLag(kafkaMsg.TopicPartition)
+		if len(batch) == 0 {
+			deadline = time.Now().Add(c.batchWait)
 		}
+		batch = append(batch, kafkaMsg)
 	}
+	return batch
 }
 
-func (c *Consumer) Stop() error {
-	c.stop = true
-	// вручную коммитим то что сами не успели закоммитить это делать сама kafka
-	if _, err := c.consumer.Commit(); err != nil {
-		return err
+// handleMessage обрабатывает одно сообщение: сохраняет offset после успеха или откладывает повтор
+func (c *Consumer) handleMessage(ctx context.Context, kafkaMsg *kafka.Message) {
+	if _, paused := c.pending[kafkaMsg.TopicPartition.Partition]; paused {
+		// партиция встала на паузу из-за более раннего сообщения; это прочитаем заново после её снятия
+		return
+	}
+	if err := c.prepareMessage(ctx, kafkaMsg, 0); err != nil {
+		c.postpone(ctx, kafkaMsg, 0, err)
+		return
 	}
-	return c.consumer.Close()
+	c.storeOffset(ctx, kafkaMsg)
 }
 
-func (c *Consumer) prepareMessage(kafkaMsg *kafka.Message) (err error) {
-	var msg model.OrderInfo
-	err = validator.ValidateOrderInfo(kafkaMsg.Value, &msg)
-	if err != nil {
-		fmt.Printf("Error validating message: %s\n", err)
-		return err
+// storeOffset сохраняет offset обработанного сообщения для следующего коммита
+func (c *Consumer) storeOffset(ctx context.Context, kafkaMsg *kafka.Message) {
+	if _, err := c.consumer.StoreMessage(kafkaMsg); err != nil {
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, fmt.Sprintf("Error storing message in consumer: %v", err))
 	}
+}
 
-	// Сохраняем в кэш
-	c.cache.Set(msg.OrderUID, msg)
-	fmt.Println(msg.OrderUID)
+// commitStored при стратегии sync синхронно коммитит сохранённые offset'ы: после каждого сообщения
+// или, в пакетном режиме, один раз за пачку. При стратегии auto это делает librdkafka
+func (c *Consumer) commitStored(ctx context.Context) {
+	if c.commit != CommitSync {
+		return
+	}
+	if _, err := c.consumer.Commit(); err != nil {
+		var kErr kafka.Error
+		// ErrNoOffset - новых обработанных сообщений нет
+		if errors.As(err, &kErr) && kErr.Code() == kafka.ErrNoOffset {
+			return
+		}
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, fmt.Sprintf("Error committing offsets in consumer: %v", err))
+	}
+}
 
-	ctx := context.Background()
+// postpone ставит партицию сообщения на паузу и планирует повтор с экспоненциальной задержкой.
+// Остальные партиции консьюмера продолжают читаться, offset сообщения не сохраняется
+func (c *Consumer) postpone(ctx context.Context, kafkaMsg *kafka.Message, attempt int, reason error) {
+	delay := c.backoff.Delay(attempt)
+	logger.GetOrCreateLoggerFromCtx(ctx).Warn(ctx, "message processing postponed",
+		zap.Int("consumer", c.consumerNumber),
+		zap.Int32("partition", kafkaMsg.TopicPartition.Partition),
+		zap.Int64("offset", int64(kafkaMsg.TopicPartition.Offset)),
+		zap.Int("attempt", attempt+1),
+		zap.Duration("retry_in", delay),
+		zap.Error(reason),
+	)
 
-	// начинаем транзакцию
-	tx, err := c.db.BeginTx(ctx, pgx.TxOptions{
-		IsoLevel:   pgx.ReadCommitted,
-		AccessMode: pgx.ReadWrite,
-	})
-	if err != nil {
-		fmt.Printf("Failed to start transaction: %s\n", err)
-		return nil
+	if !errors.Is(reason, errRetry) && c.backoff.Exhausted(attempt) {
+		c.markStuck(ctx, kafkaMsg, attempt, reason)
 	}
 
-	// подготавливаем транзакцию
-	if err := c.processMessage(ctx, tx, msg); err != nil {
-		fmt.Printf("Failed to process message: %s\n", err)
-		tx.Rollback(ctx)
-		return nil
+	partition := kafkaMsg.TopicPartition.Partition
+	if _, ok := c.pending[partition]; !ok {
+		if err := c.consumer.Pause([]kafka.TopicPartition{kafkaMsg.TopicPartition}); err != nil {
+			logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "error pausing partition", zap.Int32("partition", partition), zap.Error(err))
+		}
 	}
+	c.pending[partition] = &pendingMessage{msg: kafkaMsg, attempt: attempt, retryAt: time.Now().Add(delay)}
+}
 
-	// коммитим транзакцию
-	if err := tx.Commit(ctx); err != nil {
-		fmt.Printf("Failed to commit transaction: %s\n", err)
-		return nil
+// retryPending повторяет отложенные сообщения, время которых подошло. После успеха или отправки в DLQ
+// партиция возвращается на позицию за сообщением и снимается с паузы
+func (c *Consumer) retryPending(ctx context.Context) {
+	now := time.Now()
+	for partition, p := range c.pending {
+		if now.Before(p.retryAt) {
+			continue
+		}
+		metrics.ConsumerMessage(c.consumerNumber, metrics.StageRetried)
+		if err := c.prepareMessage(ctx, p.msg, p.attempt+1); err != nil {
+			c.postpone(ctx, p.msg, p.attempt+1, err)
+			continue
+		}
+		delete(c.pending, partition)
+		c.clearStuck(partition)
+		c.storeOffset(ctx, p.msg)
+		c.resume(ctx, p.msg.TopicPartition)
 	}
-	return nil
 }
 
-// processMessage подготавливаем msg для отправки в бд
-func (consumer *Consumer) processMessage(ctx context.Context, tx pgx.Tx, msg model.OrderInfo) error {
-	if err := insertOrder(ctx, tx, msg); err != nil {
-		return fmt.Errorf("insert order failed: %w", err)
-	}
-	if err := insertDelivery(ctx, tx, msg); err != nil {
-		return fmt.Errorf("insert delivery failed: %w", err)
+// markStuck отмечает партицию застрявшей: попытки исчерпаны, а сообщение не удалось отправить в DLQ.
+// Offset не сохраняется, чтобы не потерять сообщение; до восстановления DLQ партиция видна в статусе пула
+// и в метрике stuck_partitions
+func (c *Consumer) markStuck(ctx context.Context, kafkaMsg *kafka.Message, attempt int, reason error) {
+	partition := kafkaMsg.TopicPartition.Partition
+	c.stuckMu.Lock()
+	defer c.stuckMu.Unlock()
+
+	if _, ok := c.stuck[partition]; !ok {
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "partition stuck: message exhausted retries and cannot be dead-lettered",
+			zap.Int("consumer", c.consumerNumber),
+			zap.Int32("partition", partition),
+			zap.Int64("offset", int64(kafkaMsg.TopicPartition.Offset)),
+			zap.Int("attempts", attempt+1),
+			zap.Error(reason),
+		)
+		metrics.ConsumerMessage(c.consumerNumber, metrics.StageStuck)
 	}
-	if err := insertPayment(ctx, tx, msg); err != nil {
-		return fmt.Errorf("insert payment failed: %w", err)
+	if c.stuck == nil {
+		c.stuck = make(map[int32]StuckPartition)
 	}
-	if err := insertItems(ctx, tx, msg); err != nil {
-		return fmt.Errorf("insert items failed: %w", err)
+	c.stuck[partition] = StuckPartition{
+		Partition: partition,
+		Offset:    int64(kafkaMsg.TopicPartition.Offset),
+		Attempts:  attempt + 1,
+		Error:     reason.Error(),
 	}
-	return nil
+	metrics.ConsumerStuck(c.consumerNumber, len(c.stuck))
 }
 
-// insertOrder вставляем order
-func insertOrder(ctx context.Context, tx pgx.Tx, msg model.OrderInfo) error {
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-	orderBuilder := psql.Insert("orders").
-		Columns("order_uid", "track_number", "entry", "locale", "internal_signature",
-			"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard").
-		Values(msg.OrderUID, msg.TrackNumber, msg.Entry, msg.Locale, msg.InternalSignature,
-			msg.CustomerID, msg.DeliveryService, msg.ShardKey, msg.SmID, msg.DateCreated, msg.OofShard)
-
-	sqlStr, args, _ := orderBuilder.ToSql()
-	_, err := tx.Exec(ctx, sqlStr, args...)
-	return err
+func (c *Consumer) clearStuck(partition int32) {
+	c.stuckMu.Lock()
+	defer c.stuckMu.Unlock()
+	delete(c.stuck, partition)
+	metrics.ConsumerStuck(c.consumerNumber, len(c.stuck))
 }
 
-// insertDelivery вставляем delivery
-func insertDelivery(ctx context.Context, tx pgx.Tx, msg model.OrderInfo) error {
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-	deliveryBuilder := psql.Insert("deliveries").
-		Columns("order_uid", "name", "phone", "zip", "city", "address", "region", "email").
-		Values(msg.OrderUID, msg.Delivery.Name, msg.Delivery.Phone, msg.Delivery.Zip,
-			msg.Delivery.City, msg.Delivery.Address, msg.Delivery.Region, msg.Delivery.Email)
+// Stuck застрявшие партиции консьюмера по возрастанию номера
+func (c *Consumer) Stuck() []StuckPartition {
+	c.stuckMu.Lock()
+	defer c.stuckMu.Unlock()
 
-	sqlStr, args, _ := deliveryBuilder.ToSql()
-	_, err := tx.Exec(ctx, sqlStr, args...)
-	return err
+	stuck := slices.Collect(maps.Values(c.stuck))
+	slices.SortFunc(stuck, func(a, b StuckPartition) int { return cmp.Compare(a.Partition, b.Partition) })
+	return stuck
 }
 
-// insertPayment вставляем payment
-func insertPayment(ctx context.Context, tx pgx.Tx, msg model.OrderInfo) error {
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-	paymentBuilder := psql.Insert("payments").
-		Columns("transaction", "order_uid", "request_id", "currency", "provider",
-			"amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee").
-		Values(msg.Payment.Transaction, msg.OrderUID, msg.Payment.RequestID, msg.Payment.Currency,
-			msg.Payment.Provider, msg.Payment.Amount, msg.Payment.PaymentDT, msg.Payment.Bank,
-			msg.Payment.DeliveryCost, msg.Payment.GoodsTotal, msg.Payment.CustomFee)
-
-	sqlStr, args, _ := paymentBuilder.ToSql()
-	_, err := tx.Exec(ctx, sqlStr, args...)
-	return err
+// resume снимает партицию с паузы и продолжает чтение со следующего за tp сообщения:
+// уже выбранные librdkafka сообщения паузы сбрасываются
+func (c *Consumer) resume(ctx context.Context, tp kafka.TopicPartition) {
+	log := logger.GetOrCreateLoggerFromCtx(ctx)
+	if err := c.consumer.Resume([]kafka.TopicPartition{tp}); err != nil {
+		log.Error(ctx, "error resuming partition", zap.Int32("partition", tp.Partition), zap.Error(err))
+		return
+	}
+	next := tp
+	next.Offset = tp.Offset + 1
+	if err := c.consumer.Seek(next, 0); err != nil {
+		log.Error(ctx, "error seeking partition", zap.Int32("partition", tp.Partition), zap.Error(err))
+	}
 }
 
-// insertItems вставляем items
-func insertItems(ctx context.Context, tx pgx.Tx, msg model.OrderInfo) error {
-	psql := sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
-	for _, item := range msg.Items {
-		itemBuilder := psql.Insert("items").
-			Columns("order_uid", "chrt_id", "track_number", "price", "rid", "name",
-				"sale", "size", "total_price", "nm_id", "brand", "status").
-			Values(msg.OrderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name,
-				item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status)
+// pollTimeout сколько ждать сообщение: не дольше readTimeout и не дольше ближайшего повтора
+func (c *Consumer) pollTimeout() time.Duration {
+	timeout := readTimeout
+	for _, p := range c.pending {
+		if wait := time.Until(p.retryAt); wait < timeout {
+			timeout = wait
+		}
+	}
+	// отрицательный таймаут ReadMessage трактует как бесконечное ожидание
+	return max(timeout, time.Millisecond)
+}
 
-		sqlStr, args, _ := itemBuilder.ToSql()
-		if _, err := tx.Exec(ctx, sqlStr, args...); err != nil {
-			return err
+// close вручную коммитит то, что kafka не успела закоммитить автоматически, и закрывает консьюмера
+func (c *Consumer) close() error {
+	var commitErr error
+	if _, err := c.consumer.Commit(); err != nil {
+		var kErr kafka.Error
+		// ErrNoOffset - с последнего автокоммита новых offset'ов не было
+		if !errors.As(err, &kErr) || kErr.Code() != kafka.ErrNoOffset {
+			commitErr = fmt.Errorf("commit offsets: %w", err)
 		}
 	}
-	return nil
+	// партиции закрытого консьюмера достанутся другим, застрявшие сообщения они прочитают заново
+	c.stuckMu.Lock()
+	clear(c.stuck)
+	metrics.ConsumerStuck(c.consumerNumber, 0)
+	c.stuckMu.Unlock()
+	return errors.Join(commitErr, c.consumer.Close())
 }
 
-// StartConsuming начинаем прослушку
-func StartConsuming(ctx context.Context, brokers []string, groupID, topic string, db *pgxpool.Pool, cache cache.Cache) {
-	log := logger.GetOrCreateLoggerFromCtx(ctx)
-	c1, err := NewConsumer(brokers, topic, "my-consumer-group", db, cache, 1)
+// prepareMessage валидирует и сохраняет сообщение, attempt - номер попытки с нуля. Отклонённое сообщение
+// и заказ, упавший с постоянной ошибкой базы, уходят в dead-letter топик. Временные ошибки базы
+// повторяются без ограничения числа попыток: недоступность PostgreSQL не должна отправлять заказы в DLQ;
+// nil означает, что сообщение обработано и его offset можно сохранять, ошибка - что обработку нужно повторить
+func (c *Consumer) prepareMessage(ctx context.Context, kafkaMsg *kafka.Message, attempt int) (err error) {
+	var msg model.OrderInfo
+	stage, err := c.decode(ctx, kafkaMsg, &msg)
 	if err != nil {
-		log.Error(ctx, "error creating consumer", zap.Error(err))
+		if ctx.Err() != nil || isTransientDecode(err) && !c.backoff.Exhausted(attempt) {
+			return fmt.Errorf("%w: %w", errRetry, err)
+		}
+		if attempt == 0 {
+			metrics.ConsumerMessage(c.consumerNumber, metrics.StageRejected)
+		}
+		return c.deadLetter(ctx, kafkaMsg, stage, err)
+	}
+	if attempt == 0 {
+		metrics.ConsumerMessage(c.consumerNumber, metrics.StageValidated)
 	}
-	c2, err := NewConsumer(brokers, topic, "my-consumer-group", db, cache, 2)
+
+	changed, err := c.persist(ctx, msg)
 	if err != nil {
-		log.Error(ctx, "error creating consumer", zap.Error(err))
+		metrics.ConsumerMessage(c.consumerNumber, metrics.StageFailed)
+		switch {
+		case ctx.Err() != nil:
+			// остановка сервиса: offset не сохраняем, сообщение прочитается после перезапуска
+			return fmt.Errorf("%w: %w", errRetry, err)
+		case postgres.IsTransient(err):
+			return fmt.Errorf("%w: %w", errRetry, err)
+		}
+		return c.deadLetter(ctx, kafkaMsg, dlq.StagePersistence, err)
 	}
-	c3, err := NewConsumer(brokers, topic, "my-consumer-group", db, cache, 3)
+
+	if !changed {
+		// повторная доставка без изменений или старая версия заказа: подтверждаем, ничего не записав.
+		// В базе может лежать более новая версия, поэтому кэш не заполняем, а сбрасываем
+		c.cache.Delete(msg.OrderUID)
+		metrics.ConsumerMessage(c.consumerNumber, metrics.StageDuplicate)
+		return nil
+	}
+	// кэшируем только закоммиченный заказ, чтобы API не отдавал то, чего нет в базе
+	c.cache.Set(msg.OrderUID, msg)
+	metrics.ConsumerMessage(c.consumerNumber, metrics.StagePersisted)
+	return nil
+}
+
+// decode разбирает сообщение по заголовку content-type и валидирует заказ;
+// при ошибке возвращает этап, на котором сообщение отклонено
+func (c *Consumer) decode(ctx context.Context, kafkaMsg *kafka.Message, order *model.OrderInfo) (string, error) {
+	if err := c.decoders.Decode(ctx, contentType(kafkaMsg), kafkaMsg.Value, order); err != nil {
+		return dlq.StageDecode, err
+	}
+	warnings, err := c.rules.Validate(order)
 	if err != nil {
-		log.Error(ctx, "error creating consumer", zap.Error(err))
+		return dlq.StageValidation, err
+	}
+	if len(warnings) > 0 {
+		// мягкий режим: заказ принимается, расхождения видны в логах и метриках
+		metrics.ConsumerMessage(c.consumerNumber, metrics.StageWarned)
+		logger.GetOrCreateLoggerFromCtx(ctx).Warn(ctx, "order accepted with business rule violations",
+			zap.Int("consumer", c.consumerNumber),
+			zap.String("order_uid", order.OrderUID),
+			zap.Any("violations", warnings),
+		)
+	}
+	return "", nil
+}
+
+// isTransientDecode реестр схем недоступен: сообщение стоит разобрать позже, а не отклонять
+func isTransientDecode(err error) bool {
+	return errors.Is(err, schemaregistry.ErrUnavailable)
+}
+
+// contentType формат сообщения из заголовка; последний заголовок главнее
+func contentType(kafkaMsg *kafka.Message) string {
+	var value string
+	for _, h := range kafkaMsg.Headers {
+		if strings.EqualFold(h.Key, codec.HeaderContentType) {
+			value = string(h.Value)
+		}
+	}
+	return value
+}
+
+// persist сохраняет заказ в базу одной транзакцией; false означает, что такой заказ уже был сохранён
+func (c *Consumer) persist(ctx context.Context, msg model.OrderInfo) (bool, error) {
+	return c.repo.UpsertOrder(ctx, msg)
+}
+
+// deadLetter отправляет сообщение в dead-letter топик. Если DLQ выключен, сообщение, отклонённое на этапах
+// decode и validation, только логируется и отбрасывается, его offset можно сохранять; незаписанный заказ
+// не отбрасывается никогда. Если DLQ недоступен или выключен для незаписанного заказа, возвращает ошибку,
+// чтобы offset сообщения не был сохранён и обработка была повторена
+func (c *Consumer) deadLetter(ctx context.Context, kafkaMsg *kafka.Message, stage string, reason error) error {
+	fields := []zap.Field{
+		zap.Int("consumer", c.consumerNumber),
+		zap.String("stage", stage),
+		zap.Int32("partition", kafkaMsg.TopicPartition.Partition),
+		zap.Int64("offset", int64(kafkaMsg.TopicPartition.Offset)),
+		zap.Error(reason),
+	}
+	var verr *validator.ValidationError
+	if errors.As(reason, &verr) {
+		fields = append(fields, zap.Any("violations", verr.Violations))
+	}
+	logger.GetOrCreateLoggerFromCtx(ctx).Warn(ctx, "message rejected", fields...)
+
+	err := c.dlq.Publish(ctx, kafkaMsg, stage, reason)
+	switch {
+	case errors.Is(err, dlq.ErrDisabled) && stage != dlq.StagePersistence:
+		// отказ уже учтён в метриках как rejected
+		logger.GetOrCreateLoggerFromCtx(ctx).Error(ctx, "message dropped: dead-letter topic is not configured", fields...)
+		return nil
+	case err != nil:
+		return fmt.Errorf("%s failed: %w (dead-letter: %w)", stage, reason, err)
+	}
+	metrics.ConsumerMessage(c.consumerNumber, metrics.StageDeadLettered)
+	return nil
+}
+
+// reportLag обновляет отставание по партиции из закэшированных librdkafka watermark'ов, без запроса к брокеру
+func (c *Consumer) reportLag(tp kafka.TopicPartition) {
+	if tp.Topic == nil {
+		return
+	}
+	_, high, err := c.consumer.GetWatermarkOffsets(*tp.Topic, tp.Partition)
+	if err != nil || high < 0 {
+		return
 	}
-	go c1.Start()
-	go c2.Start()
-	go c3.Start()
+	metrics.ConsumerLag(c.consumerNumber, *tp.Topic, tp.Partition, high-int64(tp.Offset)-1)
 }
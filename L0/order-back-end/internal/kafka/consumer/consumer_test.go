@@ -0,0 +1,543 @@
+package kfk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"order-back-end/internal/cache"
+	"order-back-end/internal/codec"
+	kfkcfg "order-back-end/internal/kafka/config"
+	"order-back-end/internal/kafka/dlq"
+	"order-back-end/internal/kafka/transport/memory"
+	"order-back-end/internal/metrics"
+	"order-back-end/internal/model"
+	order "order-back-end/internal/repository"
+	"order-back-end/internal/repository/mocks"
+	"order-back-end/internal/retry"
+	"order-back-end/internal/schemaregistry"
+	"order-back-end/internal/validator"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+// Этапы записи заказа, на которых fakeDB внедряет сбой
+const (
+	failBegin    = "begin"
+	failOrder    = "INSERT INTO orders"
+	failDelivery = "INSERT INTO deliveries"
+	failPayment  = "INSERT INTO payments"
+	failDelItems = "DELETE FROM items"
+	failItems    = "INSERT INTO items"
+	failCopy     = "COPY items"
+	failCommit   = "commit"
+)
+
+// fakeDB хранит хэши закоммиченных заказов и падает на этапе failAt; запись идёт только через транзакции
+type fakeDB struct {
+	order.Querier
+
+	failAt  string
+	failErr error
+
+	mu        sync.Mutex
+	committed map[string]string // order_uid -> content_hash
+}
+
+// count число закоммиченных заказов; безопасно вызывать, пока консьюмеры пишут
+func (db *fakeDB) count() int {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return len(db.committed)
+}
+
+func newFakeDB() *fakeDB {
+	return &fakeDB{committed: make(map[string]string)}
+}
+
+func (db *fakeDB) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	if db.failAt == failBegin {
+		return nil, db.failErr
+	}
+	return &fakeTx{db: db, staged: make(map[string]string)}, nil
+}
+
+// fakeTx применяет записи к fakeDB только при коммите
+type fakeTx struct {
+	pgx.Tx
+	db     *fakeDB
+	staged map[string]string
+}
+
+func (tx *fakeTx) fail(sql string) error {
+	if tx.db.failAt != "" && strings.HasPrefix(sql, tx.db.failAt) {
+		return tx.db.failErr
+	}
+	return nil
+}
+
+func (tx *fakeTx) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	return pgconn.CommandTag{}, tx.fail(sql)
+}
+
+func (tx *fakeTx) QueryRow(_ context.Context, sql string, args ...any) pgx.Row {
+	if err := tx.fail(sql); err != nil {
+		return fakeRow{err: err}
+	}
+	if !strings.HasPrefix(sql, failOrder) {
+		return fakeRow{}
+	}
+	uid, hash := args[0].(string), args[len(args)-1].(string)
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	if tx.db.committed[uid] == hash {
+		return fakeRow{err: pgx.ErrNoRows}
+	}
+	tx.staged[uid] = hash
+	return fakeRow{uid: uid}
+}
+
+func (tx *fakeTx) SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults {
+	results := &fakeBatchResults{}
+	for _, q := range b.QueuedQueries {
+		results.rows = append(results.rows, tx.QueryRow(ctx, q.SQL, q.Arguments...).(fakeRow))
+	}
+	return results
+}
+
+func (tx *fakeTx) CopyFrom(_ context.Context, table pgx.Identifier, _ []string, rows pgx.CopyFromSource) (int64, error) {
+	if err := tx.fail("COPY " + strings.Join(table, ".")); err != nil {
+		return 0, err
+	}
+	var n int64
+	for rows.Next() {
+		n++
+	}
+	return n, nil
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	if tx.db.failAt == failCommit {
+		return tx.db.failErr
+	}
+	tx.db.mu.Lock()
+	defer tx.db.mu.Unlock()
+	maps.Copy(tx.db.committed, tx.staged)
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	return nil
+}
+
+// fakeBatchResults результаты запросов пачки по порядку
+type fakeBatchResults struct {
+	pgx.BatchResults
+	rows []fakeRow
+	next int
+}
+
+func (r *fakeBatchResults) QueryRow() pgx.Row {
+	row := r.rows[r.next]
+	r.next++
+	return row
+}
+
+func (r *fakeBatchResults) Close() error {
+	for ; r.next < len(r.rows); r.next++ {
+		if r.rows[r.next].err != nil {
+			return r.rows[r.next].err
+		}
+	}
+	return nil
+}
+
+type fakeRow struct {
+	uid string
+	err error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*string) = r.uid
+	return nil
+}
+
+// unavailableDLQ настроенный, но недоступный dead-letter топик
+type unavailableDLQ struct{}
+
+func (unavailableDLQ) Publish(context.Context, *kafka.Message, string, error) error {
+	return errors.New("dead-letter topic is unavailable")
+}
+
+// newTestConsumer консьюмер поверх fakeDB; DLQ недоступен, поэтому отклонённые сообщения возвращают ошибку
+func newTestConsumer(db *fakeDB) *Consumer {
+	return &Consumer{
+		repo:     order.NewRepository(db),
+		dlq:      unavailableDLQ{},
+		cache:    cache.NewCache(time.Minute, 10),
+		decoders: codec.NewDecoders(false),
+		rules:    &validator.Validator{},
+		backoff:  retry.Backoff{MaxAttempts: 3},
+		pending:  make(map[int32]*pendingMessage),
+	}
+}
+
+func testOrder(price int) model.OrderInfo {
+	return model.OrderInfo{
+		OrderUID:        "b563feb7b2b84b6test",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+972541234567",
+			Address: "Ploshad Mira 15",
+			City:    "Kiryat Mozkin",
+			Email:   "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction: "b563feb7b2b84b6test",
+			Currency:    "USD",
+			Provider:    "wbpay",
+			Amount:      price,
+			GoodsTotal:  price,
+			PaymentDT:   1637907727,
+		},
+		Items: []model.Item{{ChrtID: 9934930, TrackNumber: "WBILMTESTTRACK", Name: "Mascaras", Price: price, TotalPrice: price}},
+	}
+}
+
+func kafkaMessage(t *testing.T, order model.OrderInfo) *kafka.Message {
+	t.Helper()
+	data, err := json.Marshal(order)
+	require.NoError(t, err)
+	topic := "orders"
+	return &kafka.Message{TopicPartition: kafka.TopicPartition{Topic: &topic}, Value: data}
+}
+
+// requireConsistent проверяет инвариант: закэшированный заказ совпадает с закоммиченным в базе
+func requireConsistent(t *testing.T, c *Consumer, db *fakeDB, uid string) {
+	t.Helper()
+	cached, inCache := c.cache.Get(uid)
+	committed, inDB := db.committed[uid]
+	if !inCache {
+		return
+	}
+	require.True(t, inDB, "cache serves an order that is not in the database")
+	hash, err := order.ContentHash(cached)
+	require.NoError(t, err)
+	require.Equal(t, committed, hash, "cache and database hold different versions")
+}
+
+func TestPrepareMessageCacheFollowsCommit(t *testing.T) {
+	stages := []string{failBegin, failOrder, failDelivery, failPayment, failDelItems, failItems, failCommit}
+	failures := map[string]error{
+		"transient": &pgconn.PgError{Code: "08006"},
+		"permanent": &pgconn.PgError{Code: "23505"},
+	}
+
+	for _, stage := range stages {
+		for kind, failErr := range failures {
+			t.Run(kind+" "+stage, func(t *testing.T) {
+				db := newFakeDB()
+				c := newTestConsumer(db)
+				ctx := context.Background()
+				order := testOrder(453)
+
+				// первая версия сохраняется без сбоев
+				require.NoError(t, c.prepareMessage(ctx, kafkaMessage(t, order), 0))
+				requireConsistent(t, c, db, order.OrderUID)
+
+				// обновление падает: в кэше должна остаться закоммиченная версия
+				db.failAt, db.failErr = stage, failErr
+				require.Error(t, c.prepareMessage(ctx, kafkaMessage(t, testOrder(500)), 0))
+				requireConsistent(t, c, db, order.OrderUID)
+
+				// новый заказ при сбое не попадает ни в базу, ни в кэш
+				fresh := testOrder(100)
+				fresh.OrderUID = "fresh-order"
+				require.Error(t, c.prepareMessage(ctx, kafkaMessage(t, fresh), 0))
+				_, inCache := c.cache.Get(fresh.OrderUID)
+				require.False(t, inCache)
+				require.NotContains(t, db.committed, fresh.OrderUID)
+
+				// после восстановления базы повтор сходится
+				db.failAt = ""
+				require.NoError(t, c.prepareMessage(ctx, kafkaMessage(t, testOrder(500)), 1))
+				requireConsistent(t, c, db, order.OrderUID)
+				cached, ok := c.cache.Get(order.OrderUID)
+				require.True(t, ok)
+				require.Equal(t, 500, cached.Payment.Amount)
+			})
+		}
+	}
+}
+
+func TestPrepareMessageDuplicateIsAcknowledged(t *testing.T) {
+	db := newFakeDB()
+	c := newTestConsumer(db)
+	ctx := context.Background()
+	order := testOrder(453)
+
+	require.NoError(t, c.prepareMessage(ctx, kafkaMessage(t, order), 0))
+	c.cache.Delete(order.OrderUID)
+
+	// повтор того же заказа ничего не пишет, но подтверждается
+	db.failAt, db.failErr = failDelivery, errors.New("must not be called")
+	require.NoError(t, c.prepareMessage(ctx, kafkaMessage(t, order), 0))
+	requireConsistent(t, c, db, order.OrderUID)
+}
+
+func TestPrepareMessageWithRepoMock(t *testing.T) {
+	ctr := gomock.NewController(t)
+	repo := mock_order.NewMockRepo(ctr)
+	c := newTestConsumer(newFakeDB())
+	c.repo = repo
+	ctx := context.Background()
+	msg := testOrder(453)
+
+	// временная ошибка базы: сообщение откладывается и не кэшируется
+	repo.EXPECT().UpsertOrder(ctx, gomock.Any()).Return(false, &pgconn.PgError{Code: "40001"})
+	require.ErrorIs(t, c.prepareMessage(ctx, kafkaMessage(t, msg), 0), errRetry)
+	_, ok := c.cache.Get(msg.OrderUID)
+	require.False(t, ok)
+
+	// и после max_attempts: пока база недоступна, заказ не уходит в DLQ
+	repo.EXPECT().UpsertOrder(ctx, gomock.Any()).Return(false, &pgconn.PgError{Code: "08006"})
+	require.ErrorIs(t, c.prepareMessage(ctx, kafkaMessage(t, msg), 10), errRetry)
+
+	repo.EXPECT().UpsertOrder(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, saved model.OrderInfo) (bool, error) {
+		require.Equal(t, msg.OrderUID, saved.OrderUID)
+		return true, nil
+	})
+	require.NoError(t, c.prepareMessage(ctx, kafkaMessage(t, msg), 1))
+	_, ok = c.cache.Get(msg.OrderUID)
+	require.True(t, ok)
+
+	// старая версия заказа не записана: в кэше её быть не должно
+	stale := testOrder(400)
+	repo.EXPECT().UpsertOrder(ctx, gomock.Any()).Return(false, nil)
+	require.NoError(t, c.prepareMessage(ctx, kafkaMessage(t, stale), 0))
+	_, ok = c.cache.Get(msg.OrderUID)
+	require.False(t, ok)
+
+	// transaction чужого заказа повтор не исправит: сообщение уходит в DLQ, а не откладывается
+	deadLetters := &switchableDLQ{}
+	c.dlq = deadLetters
+	repo.EXPECT().UpsertOrder(ctx, gomock.Any()).Return(false,
+		fmt.Errorf("%w: %s", order.ErrTransactionExists, msg.Payment.Transaction))
+	require.NoError(t, c.prepareMessage(ctx, kafkaMessage(t, msg), 0))
+	require.EqualValues(t, 1, deadLetters.published.Load())
+}
+
+// registryFunc источник схем для декодера Avro
+type registryFunc func(id int) (*schemaregistry.Schema, error)
+
+func (f registryFunc) SchemaByID(_ context.Context, id int) (*schemaregistry.Schema, error) {
+	return f(id)
+}
+
+func TestPrepareMessageDecodeFailures(t *testing.T) {
+	db := newFakeDB()
+	c := newTestConsumer(db)
+	c.decoders.Register(codec.ContentTypeAvro, codec.NewAvro(registryFunc(func(int) (*schemaregistry.Schema, error) {
+		return nil, schemaregistry.ErrUnavailable
+	}), false))
+	ctx := context.Background()
+
+	msg := kafkaMessage(t, testOrder(453))
+	msg.Headers = []kafka.Header{{Key: "Content-Type", Value: []byte(codec.ContentTypeAvro)}}
+	msg.Value = schemaregistry.AppendWireFormat(nil, 1, []byte{2})
+
+	// реестр недоступен: сообщение откладывается, пока не кончатся попытки
+	require.ErrorIs(t, c.prepareMessage(ctx, msg, 0), errRetry)
+	err := c.prepareMessage(ctx, msg, 2)
+	require.NotErrorIs(t, err, errRetry)
+	require.ErrorContains(t, err, dlq.StageDecode+" failed")
+
+	// неизвестный формат сразу отклоняется
+	msg.Headers = []kafka.Header{{Key: codec.HeaderContentType, Value: []byte("application/xml")}}
+	err = c.prepareMessage(ctx, msg, 0)
+	require.NotErrorIs(t, err, errRetry)
+	require.ErrorIs(t, err, codec.ErrUnsupportedContentType)
+	require.Zero(t, db.count())
+}
+
+func TestConsumerWithoutDLQSkipsRejectedMessage(t *testing.T) {
+	const group = "no-dlq"
+	broker := memory.NewBroker(1)
+	db := newFakeDB()
+	cfg := kfkcfg.Config{
+		Topic:   "orders",
+		GroupID: group,
+		Retry:   retry.Backoff{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+		Consumer: kfkcfg.ConsumerConfig{
+			AutoOffsetReset: "earliest",
+			CommitStrategy:  "sync",
+		},
+	}
+
+	var deadLetters *dlq.DeadLetters // dlq_topic пустой
+	c, err := NewConsumer(broker, cfg, order.NewRepository(db), cache.NewCache(time.Minute, 10), deadLetters,
+		codec.NewDecoders(false), &validator.Validator{}, 1)
+	require.NoError(t, err)
+
+	sink, err := broker.NewSink(nil)
+	require.NoError(t, err)
+	invalid := kafkaMessage(t, testOrder(453))
+	invalid.Value = []byte("not an order")
+	valid := kafkaMessage(t, testOrder(453))
+	for _, msg := range []*kafka.Message{invalid, valid} {
+		msg.TopicPartition.Partition = 0
+		require.NoError(t, sink.Produce(msg, nil))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	// отклонённое сообщение отбрасывается и не держит партицию: следующий заказ записан, оба offset'а закоммичены
+	require.Eventually(t, func() bool {
+		return db.count() == 1 && broker.Committed(group, "orders", 0) == 2
+	}, 3*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+}
+
+func TestConsumerWithoutDLQKeepsUnsavedOrder(t *testing.T) {
+	const group = "no-dlq-persistence"
+	broker := memory.NewBroker(1)
+	db := newFakeDB()
+	db.failAt, db.failErr = failOrder, &pgconn.PgError{Code: "23514"}
+	cfg := kfkcfg.Config{
+		Topic:   "orders",
+		GroupID: group,
+		Retry:   retry.Backoff{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond},
+		Consumer: kfkcfg.ConsumerConfig{
+			AutoOffsetReset: "earliest",
+			CommitStrategy:  "sync",
+		},
+	}
+	var deadLetters *dlq.DeadLetters // dlq_topic пустой
+	c, err := NewConsumer(broker, cfg, order.NewRepository(db), cache.NewCache(time.Minute, 10), deadLetters,
+		codec.NewDecoders(false), &validator.Validator{}, 1)
+	require.NoError(t, err)
+
+	sink, err := broker.NewSink(nil)
+	require.NoError(t, err)
+	require.NoError(t, sink.Produce(kafkaMessage(t, testOrder(453)), nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	// незаписанный заказ не отбрасывается: offset не сохраняется, партиция ждёт исправления
+	require.Eventually(t, func() bool { return len(c.Stuck()) == 1 }, 3*time.Second, 5*time.Millisecond)
+	require.Less(t, int64(broker.Committed(group, "orders", 0)), int64(1))
+	require.Zero(t, db.count())
+	cancel()
+	<-done
+}
+
+// switchableDLQ dead-letter топик, который можно уронить и поднять во время работы консьюмера
+type switchableDLQ struct {
+	down      atomic.Bool
+	published atomic.Int32
+}
+
+func (d *switchableDLQ) Publish(context.Context, *kafka.Message, string, error) error {
+	if d.down.Load() {
+		return errors.New("dead-letter topic is unavailable")
+	}
+	d.published.Add(1)
+	return nil
+}
+
+func TestConsumerReportsStuckPartition(t *testing.T) {
+	const group = "stuck"
+	broker := memory.NewBroker(1)
+	cfg := kfkcfg.Config{
+		Topic:   "orders",
+		GroupID: group,
+		Retry:   retry.Backoff{MaxAttempts: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond},
+		Consumer: kfkcfg.ConsumerConfig{
+			AutoOffsetReset: "earliest",
+			CommitStrategy:  "sync",
+		},
+	}
+	c, err := NewConsumer(broker, cfg, order.NewRepository(newFakeDB()), cache.NewCache(time.Minute, 10), nil,
+		codec.NewDecoders(false), &validator.Validator{}, 1)
+	require.NoError(t, err)
+	deadLetters := &switchableDLQ{}
+	deadLetters.down.Store(true)
+	c.dlq = deadLetters
+
+	sink, err := broker.NewSink(nil)
+	require.NoError(t, err)
+	invalid := kafkaMessage(t, testOrder(453))
+	invalid.Value = []byte("not an order")
+	require.NoError(t, sink.Produce(invalid, nil))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- c.Start(ctx) }()
+
+	// попытки исчерпаны, DLQ недоступен: сообщение не теряется, партиция видна как застрявшая
+	require.Eventually(t, func() bool { return len(c.Stuck()) == 1 }, 3*time.Second, 5*time.Millisecond)
+	stuck := c.Stuck()[0]
+	require.Equal(t, int32(0), stuck.Partition)
+	require.Equal(t, int64(0), stuck.Offset)
+	require.GreaterOrEqual(t, stuck.Attempts, 2)
+	require.Contains(t, stuck.Error, "unavailable")
+	require.Less(t, int64(broker.Committed(group, "orders", 0)), int64(1))
+	require.Equal(t, 1.0, stuckPartitionsMetric(t, c.Number()))
+
+	// DLQ восстановился: сообщение уходит в него, партиция снимается с паузы
+	deadLetters.down.Store(false)
+	require.Eventually(t, func() bool {
+		return len(c.Stuck()) == 0 && broker.Committed(group, "orders", 0) == 1
+	}, 3*time.Second, 5*time.Millisecond)
+	require.Equal(t, int32(1), deadLetters.published.Load())
+	require.Zero(t, stuckPartitionsMetric(t, c.Number()))
+	cancel()
+	<-done
+}
+
+// stuckPartitionsMetric значение метрики stuck_partitions консьюмера
+func stuckPartitionsMetric(t *testing.T, consumerNumber int) float64 {
+	t.Helper()
+	families, err := metrics.Registry.Gather()
+	require.NoError(t, err)
+	for _, mf := range families {
+		if mf.GetName() != "order_service_consumer_stuck_partitions" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			if m.GetLabel()[0].GetValue() == strconv.Itoa(consumerNumber) {
+				return m.GetGauge().GetValue()
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,221 @@
+package kfk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-back-end/internal/cache"
+	"order-back-end/internal/codec"
+	kfkcfg "order-back-end/internal/kafka/config"
+	"order-back-end/internal/kafka/dlq"
+	"order-back-end/internal/kafka/transport"
+	"order-back-end/internal/lifecycle"
+	"order-back-end/internal/logger"
+	order "order-back-end/internal/repository"
+	"order-back-end/internal/validator"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// CommitStrategy способ коммита обработанных offset'ов
+type CommitStrategy string
+
+const (
+	// CommitAuto librdkafka периодически коммитит сохранённые offset'ы
+	CommitAuto CommitStrategy = "auto"
+	// CommitSync offset коммитится синхронно после каждого обработанного сообщения
+	CommitSync CommitStrategy = "sync"
+)
+
+// ParseCommitStrategy разбирает стратегию из конфига, пустая строка - auto
+func ParseCommitStrategy(s string) (CommitStrategy, error) {
+	switch CommitStrategy(s) {
+	case "", CommitAuto:
+		return CommitAuto, nil
+	case CommitSync:
+		return CommitSync, nil
+	default:
+		return "", fmt.Errorf("unknown commit strategy %q", s)
+	}
+}
+
+// ErrInvalidPoolSize запрошенный размер пула вне допустимых границ
+var ErrInvalidPoolSize = errors.New("invalid consumer pool size")
+
+// ErrPoolStopped пул нельзя масштабировать после начала остановки сервиса
+var ErrPoolStopped = errors.New("consumer pool is stopped")
+
+// PoolStatus состояние пула консьюмеров
+type PoolStatus struct {
+	GroupID        string           `json:"group_id"`
+	CommitStrategy CommitStrategy   `json:"commit_strategy"`
+	Size           int              `json:"size"`
+	MaxSize        int              `json:"max_size"`
+	Consumers      []ConsumerStatus `json:"consumers"`
+}
+
+// ConsumerStatus состояние одного консьюмера пула
+type ConsumerStatus struct {
+	Number     int `json:"number"`
+	Partitions int `json:"partitions"`
+	// Stuck партиции на паузе из-за сообщения, которое исчерпало попытки и не ушло в DLQ
+	Stuck []StuckPartition `json:"stuck,omitempty"`
+}
+
+// member запущенный консьюмер пула
+type member struct {
+	consumer *Consumer
+	cancel   context.CancelFunc
+	done     chan struct{}
+}
+
+// Pool консьюмеры одной группы, работающие под контекстом lc; размер меняется на ходу через Scale
+type Pool struct {
+	lc    *lifecycle.Manager
+	cfg   kfkcfg.Config
+	tr    transport.Transport
+	repo  order.Repo
+	cache cache.Cache
+	dlq   *dlq.DeadLetters
+	decs  *codec.Decoders
+	rules *validator.Validator
+
+	mu      sync.Mutex
+	members []*member
+	next    int // номер следующего консьюмера; номера не переиспользуются, чтобы не смешивать метрики
+}
+
+// NewPool создаёт пустой пул, консьюмеры запускаются через Scale, читают сообщения через транспорт tr
+// и разбирают их декодерами из decoders, заказы проверяются валидатором rules
+func NewPool(lc *lifecycle.Manager, cfg kfkcfg.Config, tr transport.Transport, repo order.Repo, cache cache.Cache, deadLetters *dlq.DeadLetters, decoders *codec.Decoders, rules *validator.Validator) (*Pool, error) {
+	if _, err := ParseCommitStrategy(cfg.Consumer.CommitStrategy); err != nil {
+		return nil, err
+	}
+	if cfg.Consumer.Count < 0 || cfg.Consumer.Count > cfg.Consumer.MaxCount {
+		return nil, fmt.Errorf("%w: count %d must be between 0 and max_count %d",
+			ErrInvalidPoolSize, cfg.Consumer.Count, cfg.Consumer.MaxCount)
+	}
+	return &Pool{
+		lc:    lc,
+		cfg:   cfg,
+		tr:    tr,
+		repo:  repo,
+		cache: cache,
+		dlq:   deadLetters,
+		decs:  decoders,
+		rules: rules,
+	}, nil
+}
+
+// Start запускает консьюмеров в количестве из конфига
+func (p *Pool) Start(ctx context.Context) error {
+	return p.Scale(ctx, p.cfg.Consumer.Count)
+}
+
+// Scale доводит число консьюмеров до size. Лишние консьюмеры останавливаются с последнего запущенного:
+// коммитят offset'ы и покидают группу, их партиции переходят остальным при ребалансировке.
+// Ожидание остановки ограничено ctx
+func (p *Pool) Scale(ctx context.Context, size int) error {
+	if size < 0 || size > p.cfg.Consumer.MaxCount {
+		return fmt.Errorf("%w: must be between 0 and %d", ErrInvalidPoolSize, p.cfg.Consumer.MaxCount)
+	}
+
+	p.mu.Lock()
+	if p.lc.Context().Err() != nil {
+		p.mu.Unlock()
+		return ErrPoolStopped
+	}
+	var startErr error
+	for len(p.members) < size {
+		if startErr = p.startLocked(); startErr != nil {
+			break
+		}
+	}
+	var stopping []*member
+	for len(p.members) > size {
+		last := p.members[len(p.members)-1]
+		p.members = p.members[:len(p.members)-1]
+		last.cancel()
+		stopping = append(stopping, last)
+	}
+	p.mu.Unlock()
+
+	if startErr != nil {
+		return startErr
+	}
+	for _, m := range stopping {
+		select {
+		case <-m.done:
+		case <-ctx.Done():
+			return fmt.Errorf("consumer %d did not stop in time: %w", m.consumer.Number(), ctx.Err())
+		}
+	}
+
+	logger.GetOrCreateLoggerFromCtx(ctx).Info(ctx, "consumer pool scaled", zap.Int("size", size))
+	return nil
+}
+
+// startLocked создаёт и запускает ещё одного консьюмера; вызывается под p.mu
+func (p *Pool) startLocked() error {
+	number := p.next + 1
+	c, err := NewConsumer(p.tr, p.cfg, p.repo, p.cache, p.dlq, p.decs, p.rules, number)
+	if err != nil {
+		return fmt.Errorf("start consumer %d: %w", number, err)
+	}
+	p.next = number
+
+	// свой контекст, чтобы останавливать консьюмера при уменьшении пула, не дожидаясь остановки сервиса
+	ctx, cancel := context.WithCancel(p.lc.Context())
+	m := &member{consumer: c, cancel: cancel, done: make(chan struct{})}
+	err = p.lc.Go(fmt.Sprintf("consumer-%d", number), func(context.Context) error {
+		defer close(m.done)
+		return c.Start(ctx)
+	})
+	if err != nil {
+		// остановка сервиса началась между проверкой в Scale и запуском; консьюмер уже подписан на топик
+		cancel()
+		return errors.Join(fmt.Errorf("%w: %w", ErrPoolStopped, err), c.close())
+	}
+	p.members = append(p.members, m)
+	return nil
+}
+
+// Status текущий размер пула и назначенные консьюмерам партиции
+func (p *Pool) Status() PoolStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	strategy, _ := ParseCommitStrategy(p.cfg.Consumer.CommitStrategy)
+	status := PoolStatus{
+		GroupID:        p.cfg.GroupID,
+		CommitStrategy: strategy,
+		Size:           len(p.members),
+		MaxSize:        p.cfg.Consumer.MaxCount,
+		Consumers:      make([]ConsumerStatus, 0, len(p.members)),
+	}
+	for _, m := range p.members {
+		status.Consumers = append(status.Consumers, ConsumerStatus{
+			Number:     m.consumer.Number(),
+			Partitions: m.consumer.Partitions(),
+			Stuck:      m.consumer.Stuck(),
+		})
+	}
+	return status
+}
+
+// Check проверка готовности: хотя бы одному консьюмеру пула назначены партиции
+func (p *Pool) Check(context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.members) == 0 {
+		return fmt.Errorf("no consumers running")
+	}
+	for _, m := range p.members {
+		if m.consumer.Assigned() {
+			return nil
+		}
+	}
+	return fmt.Errorf("no partitions assigned yet")
+}
@@ -0,0 +1,181 @@
+package kfk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"order-back-end/internal/cache"
+	"order-back-end/internal/codec"
+	kfkcfg "order-back-end/internal/kafka/config"
+	"order-back-end/internal/kafka/transport"
+	"order-back-end/internal/kafka/transport/memory"
+	"order-back-end/internal/lifecycle"
+	order "order-back-end/internal/repository"
+	"order-back-end/internal/retry"
+	"order-back-end/internal/validator"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommitStrategy(t *testing.T) {
+	s, err := ParseCommitStrategy("")
+	require.NoError(t, err)
+	require.Equal(t, CommitAuto, s)
+
+	s, err = ParseCommitStrategy("sync")
+	require.NoError(t, err)
+	require.Equal(t, CommitSync, s)
+
+	_, err = ParseCommitStrategy("manual")
+	require.Error(t, err)
+}
+
+func TestNewPoolRejectsInvalidConfig(t *testing.T) {
+	lc := lifecycle.New(context.Background())
+
+	_, err := NewPool(lc, kfkcfg.Config{Consumer: kfkcfg.ConsumerConfig{Count: 5, MaxCount: 3}}, transport.Kafka{}, nil, nil, nil, nil, nil)
+	require.ErrorIs(t, err, ErrInvalidPoolSize)
+
+	_, err = NewPool(lc, kfkcfg.Config{Consumer: kfkcfg.ConsumerConfig{Count: 1, MaxCount: 3, CommitStrategy: "manual"}}, transport.Kafka{}, nil, nil, nil, nil, nil)
+	require.Error(t, err)
+}
+
+func TestPoolScale(t *testing.T) {
+	if testing.Short() {
+		t.Skip("uses librdkafka mock cluster")
+	}
+
+	cluster, err := kafka.NewMockCluster(1)
+	require.NoError(t, err)
+	defer cluster.Close()
+	require.NoError(t, cluster.CreateTopic("orders", 4, 1))
+
+	cfg := kfkcfg.Config{
+		Brokers: strings.Split(cluster.BootstrapServers(), ","),
+		Topic:   "orders",
+		GroupID: "pool-test",
+		Consumer: kfkcfg.ConsumerConfig{
+			Count:              2,
+			MaxCount:           4,
+			SessionTimeout:     6 * time.Second,
+			HeartbeatInterval:  time.Second,
+			MaxPollInterval:    time.Minute,
+			AutoOffsetReset:    "earliest",
+			CommitStrategy:     "sync",
+			AutoCommitInterval: time.Second,
+		},
+	}
+
+	lc := lifecycle.New(context.Background())
+	pool, err := NewPool(lc, cfg, transport.Kafka{}, nil, cache.NewCache(time.Minute, 10), nil, codec.NewDecoders(false), &validator.Validator{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	require.NoError(t, pool.Start(ctx))
+	require.Equal(t, 2, pool.Status().Size)
+	require.Eventually(t, func() bool { return pool.Check(ctx) == nil }, 20*time.Second, 100*time.Millisecond)
+
+	require.ErrorIs(t, pool.Scale(ctx, 5), ErrInvalidPoolSize)
+
+	require.NoError(t, pool.Scale(ctx, 1))
+	require.NoError(t, pool.Scale(ctx, 3))
+
+	status := pool.Status()
+	require.Equal(t, 3, status.Size)
+	require.Equal(t, CommitSync, status.CommitStrategy)
+	numbers := make([]int, 0, len(status.Consumers))
+	for _, c := range status.Consumers {
+		numbers = append(numbers, c.Number)
+	}
+	require.Equal(t, []int{1, 3, 4}, numbers, "consumer numbers must not be reused")
+
+	require.NoError(t, lc.Shutdown(ctx))
+	require.True(t, errors.Is(pool.Scale(ctx, 2), ErrPoolStopped))
+}
+
+func TestPoolPipelineInMemory(t *testing.T) {
+	const (
+		group  = "pipeline"
+		orders = 10
+	)
+
+	broker := memory.NewBroker(4)
+	db := newFakeDB()
+	cacheIn := cache.NewCache(time.Minute, 100)
+	cfg := kfkcfg.Config{
+		Topic:   "orders",
+		GroupID: group,
+		Retry:   retry.Backoff{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+		Consumer: kfkcfg.ConsumerConfig{
+			Count:           2,
+			MaxCount:        4,
+			AutoOffsetReset: "earliest",
+			CommitStrategy:  "sync",
+			BatchSize:       3,
+			BatchMaxWait:    20 * time.Millisecond,
+		},
+	}
+
+	lc := lifecycle.New(context.Background())
+	pool, err := NewPool(lc, cfg, broker, order.NewRepository(db), cacheIn, nil, codec.NewDecoders(false), &validator.Validator{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	require.NoError(t, pool.Start(ctx))
+
+	sink, err := broker.NewSink(nil)
+	require.NoError(t, err)
+	for i := 0; i < orders; i++ {
+		order := testOrder(100 + i)
+		order.OrderUID = fmt.Sprintf("order-%d", i)
+		msg := kafkaMessage(t, order)
+		msg.TopicPartition.Partition = kafka.PartitionAny
+		msg.Key = []byte(order.OrderUID)
+		require.NoError(t, sink.Produce(msg, nil))
+	}
+
+	require.Eventually(t, func() bool { return db.count() == orders }, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool {
+		var committed int64
+		for p := int32(0); p < 4; p++ {
+			if offset := broker.Committed(group, "orders", p); offset >= 0 {
+				committed += int64(offset)
+			}
+		}
+		return committed == orders
+	}, 5*time.Second, 10*time.Millisecond, "every message must be committed")
+
+	for i := 0; i < orders; i++ {
+		_, ok := cacheIn.Get(fmt.Sprintf("order-%d", i))
+		require.True(t, ok)
+	}
+	require.NoError(t, pool.Check(ctx))
+	require.NoError(t, lc.Shutdown(ctx))
+}
+
+func TestPoolDoesNotStartConsumersDuringShutdown(t *testing.T) {
+	cfg := kfkcfg.Config{
+		Topic:    "orders",
+		GroupID:  "shutdown",
+		Consumer: kfkcfg.ConsumerConfig{Count: 1, MaxCount: 2, AutoOffsetReset: "earliest"},
+	}
+	lc := lifecycle.New(context.Background())
+	pool, err := NewPool(lc, cfg, memory.NewBroker(1), nil, cache.NewCache(time.Minute, 10), nil, codec.NewDecoders(false), &validator.Validator{})
+	require.NoError(t, err)
+	require.NoError(t, lc.Shutdown(context.Background()))
+
+	// Scale проверил контекст до начала остановки, а консьюмер запускается уже во время неё
+	pool.mu.Lock()
+	err = pool.startLocked()
+	pool.mu.Unlock()
+	require.ErrorIs(t, err, ErrPoolStopped)
+	require.Zero(t, pool.Status().Size)
+}
@@ -0,0 +1,331 @@
+package dlq
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"order-back-end/internal/validator"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Заголовки, которыми помечается сообщение в dead-letter топике
+const (
+	HeaderReason            = "dlq-reason"
+	HeaderStage             = "dlq-stage"
+	HeaderOriginalTopic     = "dlq-original-topic"
+	HeaderOriginalPartition = "dlq-original-partition"
+	HeaderOriginalOffset    = "dlq-original-offset"
+	HeaderFailedAt          = "dlq-failed-at"
+	HeaderRedriveCount      = "dlq-redrive-count"
+	// HeaderViolations JSON массив нарушений валидации: path, rule, message
+	HeaderViolations = "dlq-violations"
+)
+
+// Этапы, на которых сообщение было отклонено
+const (
+	StageDecode      = "decode"
+	StageValidation  = "validation"
+	StagePersistence = "persistence"
+)
+
+const (
+	headerPrefix = "dlq-"
+	flushTimeout = 5000
+	readTimeout  = 5 * time.Second
+)
+
+// ErrDisabled dead-letter топик не настроен
+var ErrDisabled = errors.New("dead-letter topic is not configured")
+
+// ErrNotFound сообщения с указанными партицией и offset'ом в dead-letter топике нет
+var ErrNotFound = errors.New("dead-letter message not found")
+
+// Message сообщение из dead-letter топика вместе с причиной отказа
+type Message struct {
+	Partition         int32                 `json:"partition"`
+	Offset            int64                 `json:"offset"`
+	Key               string                `json:"key,omitempty"`
+	Value             string                `json:"value"`
+	Reason            string                `json:"reason"`
+	Stage             string                `json:"stage"`
+	OriginalTopic     string                `json:"original_topic"`
+	OriginalPartition int32                 `json:"original_partition"`
+	OriginalOffset    int64                 `json:"original_offset"`
+	FailedAt          time.Time             `json:"failed_at"`
+	RedriveCount      int                   `json:"redrive_count"`
+	Violations        []validator.Violation `json:"violations,omitempty"`
+	Headers           map[string]string     `json:"headers,omitempty"`
+}
+
+// DeadLetters публикует отклонённые сообщения в dead-letter топик, читает и переотправляет их
+type DeadLetters struct {
+	brokers  []string
+	topic    string
+	producer *kafka.Producer
+}
+
+// New создаёт DeadLetters для топика topic; пустой topic означает, что DLQ выключен, и возвращается nil
+func New(brokers []string, topic string) (*DeadLetters, error) {
+	if topic == "" {
+		return nil, nil
+	}
+	p, err := kafka.NewProducer(&kafka.ConfigMap{
+		"bootstrap.servers":  strings.Join(brokers, ","),
+		"enable.idempotence": true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating dlq producer: %w", err)
+	}
+	return &DeadLetters{brokers: brokers, topic: topic, producer: p}, nil
+}
+
+// Publish отправляет исходное сообщение в dead-letter топик с заголовками о причине и этапе отказа
+// и дожидается подтверждения брокера: offset исходного сообщения можно сохранять только после него
+func (d *DeadLetters) Publish(ctx context.Context, msg *kafka.Message, stage string, reason error) error {
+	if d == nil {
+		return ErrDisabled
+	}
+
+	headers := withoutDLQHeaders(msg.Headers)
+	headers = append(headers,
+		header(HeaderReason, reason.Error()),
+		header(HeaderStage, stage),
+		header(HeaderOriginalPartition, strconv.Itoa(int(msg.TopicPartition.Partition))),
+		header(HeaderOriginalOffset, strconv.FormatInt(int64(msg.TopicPartition.Offset), 10)),
+		header(HeaderFailedAt, time.Now().UTC().Format(time.RFC3339Nano)),
+		header(HeaderRedriveCount, strconv.Itoa(redriveCount(msg.Headers))),
+	)
+	if msg.TopicPartition.Topic != nil {
+		headers = append(headers, header(HeaderOriginalTopic, *msg.TopicPartition.Topic))
+	}
+	var verr *validator.ValidationError
+	if errors.As(reason, &verr) {
+		violations, err := json.Marshal(verr.Violations)
+		if err != nil {
+			return err
+		}
+		headers = append(headers, header(HeaderViolations, string(violations)))
+	}
+
+	return d.produce(ctx, &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &d.topic, Partition: kafka.PartitionAny},
+		Key:            msg.Key,
+		Value:          msg.Value,
+		Headers:        headers,
+	})
+}
+
+// List возвращает до limit последних сообщений каждой партиции dead-letter топика
+func (d *DeadLetters) List(ctx context.Context, limit int) ([]Message, error) {
+	if d == nil {
+		return nil, ErrDisabled
+	}
+
+	c, err := d.newReader()
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	meta, err := c.GetMetadata(&d.topic, false, int(readTimeout.Milliseconds()))
+	if err != nil {
+		return nil, fmt.Errorf("dlq metadata: %w", err)
+	}
+
+	// для каждой партиции читаем хвост [high-limit, high)
+	var assignment []kafka.TopicPartition
+	remaining := 0
+	for _, p := range meta.Topics[d.topic].Partitions {
+		low, high, err := c.QueryWatermarkOffsets(d.topic, p.ID, int(readTimeout.Milliseconds()))
+		if err != nil {
+			return nil, fmt.Errorf("dlq watermarks: %w", err)
+		}
+		from := high - int64(limit)
+		if from < low {
+			from = low
+		}
+		if from >= high {
+			continue
+		}
+		remaining += int(high - from)
+		assignment = append(assignment, kafka.TopicPartition{Topic: &d.topic, Partition: p.ID, Offset: kafka.Offset(from)})
+	}
+	if len(assignment) == 0 {
+		return []Message{}, nil
+	}
+	if err := c.Assign(assignment); err != nil {
+		return nil, fmt.Errorf("dlq assign: %w", err)
+	}
+
+	messages := make([]Message, 0, remaining)
+	for remaining > 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		kafkaMsg, err := c.ReadMessage(readTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("dlq read: %w", err)
+		}
+		messages = append(messages, toMessage(kafkaMsg))
+		remaining--
+	}
+	return messages, nil
+}
+
+// Redrive переотправляет сообщение partition/offset из dead-letter топика в исходный топик
+func (d *DeadLetters) Redrive(ctx context.Context, partition int32, offset int64) error {
+	if d == nil {
+		return ErrDisabled
+	}
+
+	c, err := d.newReader()
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	low, high, err := c.QueryWatermarkOffsets(d.topic, partition, int(readTimeout.Milliseconds()))
+	if err != nil {
+		return fmt.Errorf("dlq watermarks: %w", err)
+	}
+	if offset < low || offset >= high {
+		return ErrNotFound
+	}
+
+	if err := c.Assign([]kafka.TopicPartition{{Topic: &d.topic, Partition: partition, Offset: kafka.Offset(offset)}}); err != nil {
+		return fmt.Errorf("dlq assign: %w", err)
+	}
+	kafkaMsg, err := c.ReadMessage(readTimeout)
+	if err != nil {
+		return fmt.Errorf("dlq read: %w", err)
+	}
+
+	msg := toMessage(kafkaMsg)
+	if msg.OriginalTopic == "" {
+		return fmt.Errorf("dlq message %d/%d has no %s header", partition, offset, HeaderOriginalTopic)
+	}
+
+	headers := withoutDLQHeaders(kafkaMsg.Headers)
+	headers = append(headers, header(HeaderRedriveCount, strconv.Itoa(msg.RedriveCount+1)))
+
+	return d.produce(ctx, &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &msg.OriginalTopic, Partition: kafka.PartitionAny},
+		Key:            kafkaMsg.Key,
+		Value:          kafkaMsg.Value,
+		Headers:        headers,
+	})
+}
+
+// Close дожидается доставки буфера и закрывает продьюсера
+func (d *DeadLetters) Close() {
+	if d == nil {
+		return
+	}
+	d.producer.Flush(flushTimeout)
+	d.producer.Close()
+}
+
+// produce синхронно отправляет сообщение и ждёт отчёт о доставке
+func (d *DeadLetters) produce(ctx context.Context, msg *kafka.Message) error {
+	deliveryCh := make(chan kafka.Event, 1)
+	if err := d.producer.Produce(msg, deliveryCh); err != nil {
+		return fmt.Errorf("dlq produce: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case e := <-deliveryCh:
+		m, ok := e.(*kafka.Message)
+		if !ok {
+			return fmt.Errorf("dlq produce: unexpected event %v", e)
+		}
+		if m.TopicPartition.Error != nil {
+			return fmt.Errorf("dlq delivery: %w", m.TopicPartition.Error)
+		}
+		return nil
+	}
+}
+
+// newReader консьюмер без группы и коммитов для просмотра dead-letter топика
+func (d *DeadLetters) newReader() (*kafka.Consumer, error) {
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers":  strings.Join(d.brokers, ","),
+		"group.id":           "dlq-reader",
+		"enable.auto.commit": false,
+		"auto.offset.reset":  "earliest",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating dlq reader: %w", err)
+	}
+	return c, nil
+}
+
+// toMessage разбирает заголовки сообщения из dead-letter топика
+func toMessage(m *kafka.Message) Message {
+	msg := Message{
+		Partition: m.TopicPartition.Partition,
+		Offset:    int64(m.TopicPartition.Offset),
+		Key:       string(m.Key),
+		Value:     string(m.Value),
+		Headers:   make(map[string]string),
+	}
+	for _, h := range m.Headers {
+		v := string(h.Value)
+		switch h.Key {
+		case HeaderReason:
+			msg.Reason = v
+		case HeaderStage:
+			msg.Stage = v
+		case HeaderOriginalTopic:
+			msg.OriginalTopic = v
+		case HeaderOriginalPartition:
+			p, _ := strconv.ParseInt(v, 10, 32)
+			msg.OriginalPartition = int32(p)
+		case HeaderOriginalOffset:
+			msg.OriginalOffset, _ = strconv.ParseInt(v, 10, 64)
+		case HeaderFailedAt:
+			msg.FailedAt, _ = time.Parse(time.RFC3339Nano, v)
+		case HeaderRedriveCount:
+			msg.RedriveCount, _ = strconv.Atoi(v)
+		case HeaderViolations:
+			_ = json.Unmarshal(h.Value, &msg.Violations)
+		default:
+			msg.Headers[h.Key] = v
+		}
+	}
+	return msg
+}
+
+// withoutDLQHeaders исходные заголовки без служебных dlq-*
+func withoutDLQHeaders(headers []kafka.Header) []kafka.Header {
+	result := make([]kafka.Header, 0, len(headers)+8)
+	for _, h := range headers {
+		if !strings.HasPrefix(h.Key, headerPrefix) {
+			result = append(result, h)
+		}
+	}
+	return result
+}
+
+// redriveCount сколько раз сообщение уже переотправлялось из DLQ
+func redriveCount(headers []kafka.Header) int {
+	for _, h := range headers {
+		if h.Key == HeaderRedriveCount {
+			n, _ := strconv.Atoi(string(h.Value))
+			return n
+		}
+	}
+	return 0
+}
+
+// header создаёт строковый заголовок
+func header(key, value string) kafka.Header {
+	return kafka.Header{Key: key, Value: []byte(value)}
+}
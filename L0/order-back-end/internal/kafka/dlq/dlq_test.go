@@ -0,0 +1,90 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"order-back-end/internal/validator"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeadLettersPublishListRedrive(t *testing.T) {
+	if testing.Short() {
+		t.Skip("uses librdkafka mock cluster")
+	}
+
+	cluster, err := kafka.NewMockCluster(1)
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	brokers := strings.Split(cluster.BootstrapServers(), ",")
+	require.NoError(t, cluster.CreateTopic("orders", 1, 1))
+	require.NoError(t, cluster.CreateTopic("orders-dlq", 1, 1))
+
+	d, err := New(brokers, "orders-dlq")
+	require.NoError(t, err)
+	defer d.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	topic := "orders"
+	original := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: 0, Offset: 42},
+		Value:          []byte(`{"order_uid":""}`),
+		Headers:        []kafka.Header{{Key: "content-type", Value: []byte("application/json")}},
+	}
+	violation := validator.Violation{Path: "order_uid", Rule: validator.RuleRequired, Message: "order_uid is required"}
+	reason := &validator.ValidationError{Violations: []validator.Violation{violation}}
+	require.NoError(t, d.Publish(ctx, original, StageValidation, reason))
+
+	messages, err := d.List(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+
+	msg := messages[0]
+	require.Equal(t, "order_uid is required", msg.Reason)
+	require.Equal(t, StageValidation, msg.Stage)
+	require.Equal(t, []validator.Violation{violation}, msg.Violations)
+	require.Equal(t, "orders", msg.OriginalTopic)
+	require.Equal(t, int64(42), msg.OriginalOffset)
+	require.Equal(t, `{"order_uid":""}`, msg.Value)
+	require.Equal(t, "application/json", msg.Headers["content-type"])
+	require.False(t, msg.FailedAt.IsZero())
+
+	require.NoError(t, d.Redrive(ctx, msg.Partition, msg.Offset))
+	require.ErrorIs(t, d.Redrive(ctx, msg.Partition, msg.Offset+100), ErrNotFound)
+
+	// переотправленное сообщение попадает в исходный топик без dlq-* заголовков, кроме счётчика
+	c, err := kafka.NewConsumer(&kafka.ConfigMap{
+		"bootstrap.servers": cluster.BootstrapServers(),
+		"group.id":          "test",
+	})
+	require.NoError(t, err)
+	defer c.Close()
+	require.NoError(t, c.Assign([]kafka.TopicPartition{{Topic: &topic, Partition: 0, Offset: kafka.OffsetBeginning}}))
+
+	redriven, err := c.ReadMessage(10 * time.Second)
+	require.NoError(t, err)
+	require.Equal(t, original.Value, redriven.Value)
+	headers := map[string]string{}
+	for _, h := range redriven.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	require.Equal(t, map[string]string{"content-type": "application/json", HeaderRedriveCount: "1"}, headers)
+}
+
+func TestDeadLettersDisabled(t *testing.T) {
+	d, err := New(nil, "")
+	require.NoError(t, err)
+	require.Nil(t, d)
+
+	_, err = d.List(context.Background(), 10)
+	require.ErrorIs(t, err, ErrDisabled)
+	require.ErrorIs(t, d.Publish(context.Background(), &kafka.Message{}, StageValidation, errors.New("x")), ErrDisabled)
+}
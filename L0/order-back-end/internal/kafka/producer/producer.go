@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"order-back-end/internal/codec"
+	"order-back-end/internal/kafka/transport"
 	"order-back-end/internal/logger"
 	"order-back-end/internal/model"
 	"strings"
@@ -20,18 +22,20 @@ const charset = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
 var errUnknownType = errors.New("unknown type")
 
 const (
-	flushTimeout = 5000
+	flushTimeout    = 5000
+	produceInterval = 10 * time.Second
 )
 
 type Producer struct {
-	producer *kafka.Producer
+	producer transport.MessageSink
 }
 
-func NewProducer(brokers []string) (*Producer, error) {
+// NewProducer создаём продьюсера, который пишет через транспорт tr
+func NewProducer(tr transport.Transport, brokers []string) (*Producer, error) {
 	conf := &kafka.ConfigMap{
 		"bootstrap.servers": strings.Join(brokers, ","),
 	}
-	p, err := kafka.NewProducer(conf)
+	p, err := tr.NewSink(conf)
 	if err != nil {
 		return nil, fmt.Errorf("error creating kafka producer: %w", err)
 	}
@@ -51,8 +55,9 @@ func (p *Producer) Produce(topic string) error {
 			Topic:     &topic,
 			Partition: kafka.PartitionAny,
 		},
-		Value: orderJson,
-		Key:   nil,
+		Value:   orderJson,
+		Key:     nil,
+		Headers: []kafka.Header{{Key: codec.HeaderContentType, Value: []byte(codec.ContentTypeJSON)}},
 	}
 	kafkaChan := make(chan kafka.Event)
 	if err = p.producer.Produce(kafkaMsg, kafkaChan); err != nil {
@@ -94,17 +99,27 @@ func generateOrder() model.OrderInfo {
 	gofakeit.Seed(time.Now().UnixNano())
 
 	orderID, _ := uuid.GenerateUUID()
-	RID, _ := uuid.GenerateUUID()
 	trackNum := randomString(10, charset)
 
+	// суммы согласованы так же, как их проверяет валидатор: total_price - цена со скидкой,
+	// goods_total - сумма товаров, amount - товары, доставка и пошлина
+	items := make([]model.Item, gofakeit.Number(1, 3))
+	goodsTotal := 0
+	for i := range items {
+		items[i] = generateItem(trackNum)
+		goodsTotal += items[i].TotalPrice
+	}
+	deliveryCost := gofakeit.Number(100, 1000)
+	customFee := 0
+
 	return model.OrderInfo{
 		OrderUID:    orderID,
 		TrackNumber: trackNum,
 		Entry:       randomString(4, charset),
 		Delivery: model.Delivery{
 			Name:    gofakeit.Name(),
-			Phone:   gofakeit.Phone(),
-			Zip:     gofakeit.Zip(),
+			Phone:   "+79" + randomString(9, "0123456789"), // российский мобильный и индекс, как их проверяет валидатор
+			Zip:     randomString(6, "0123456789"),
 			City:    gofakeit.City(),
 			Address: gofakeit.Street(),
 			Region:  gofakeit.State(),
@@ -113,30 +128,16 @@ func generateOrder() model.OrderInfo {
 		Payment: model.Payment{
 			Transaction:  orderID,
 			RequestID:    "",
-			Currency:     gofakeit.CurrencyShort(),
+			Currency:     gofakeit.RandString([]string{"RUB", "USD", "EUR", "KZT"}),
 			Provider:     "wbpay",
-			Amount:       gofakeit.Number(100, 5000),
+			Amount:       goodsTotal + deliveryCost + customFee,
 			PaymentDT:    time.Now().Unix(),
 			Bank:         gofakeit.Company(),
-			DeliveryCost: gofakeit.Number(100, 1000),
-			GoodsTotal:   gofakeit.Number(50, 2000),
-			CustomFee:    0,
-		},
-		Items: []model.Item{
-			{
-				ChrtID:      gofakeit.Number(1000000, 9999999),
-				TrackNumber: trackNum,
-				Price:       gofakeit.Number(100, 1000),
-				RID:         RID,
-				Name:        gofakeit.Name(),
-				Sale:        gofakeit.Number(0, 50),
-				Size:        randomString(1, charset),
-				TotalPrice:  gofakeit.Number(50, 1000),
-				NmID:        gofakeit.Number(1000000, 9999999),
-				Brand:       gofakeit.Company(),
-				Status:      gofakeit.Number(100, 300),
-			},
+			DeliveryCost: deliveryCost,
+			GoodsTotal:   goodsTotal,
+			CustomFee:    customFee,
 		},
+		Items:             items,
 		Locale:            randomLocale(),
 		InternalSignature: "",
 		CustomerID:        gofakeit.Username(),
@@ -148,18 +149,50 @@ func generateOrder() model.OrderInfo {
 	}
 }
 
-// StartProducer начинаем отправку сообщений
-func StartProducer(ctx context.Context, brokers []string, topic string) {
-	p, err := NewProducer(brokers)
+// generateItem товар отправления trackNum
+func generateItem(trackNum string) model.Item {
+	RID, _ := uuid.GenerateUUID()
+	price := gofakeit.Number(100, 1000)
+	sale := gofakeit.Number(0, 50)
+	return model.Item{
+		ChrtID:      gofakeit.Number(1000000, 9999999),
+		TrackNumber: trackNum,
+		Price:       price,
+		RID:         RID,
+		Name:        gofakeit.Name(),
+		Sale:        sale,
+		Size:        randomString(1, charset),
+		TotalPrice:  price * (100 - sale) / 100,
+		NmID:        gofakeit.Number(1000000, 9999999),
+		Brand:       gofakeit.Company(),
+		Status:      gofakeit.Number(100, 300),
+	}
+}
+
+// StartProducer начинаем отправку сообщений, при отмене ctx дожидаемся доставки буфера и закрываем продьюсера
+func StartProducer(ctx context.Context, tr transport.Transport, brokers []string, topic string) error {
+	p, err := NewProducer(tr, brokers)
 	log := logger.GetOrCreateLoggerFromCtx(ctx)
 	if err != nil {
 		log.Info(ctx, "error creating kafka producer")
+		return err
 	}
+	defer p.Close()
+
+	ticker := time.NewTicker(produceInterval)
+	defer ticker.Stop()
+
 	for {
 		if err := p.Produce(topic); err != nil {
 			log.Info(ctx, "error producing message")
+		} else {
+			log.Info(ctx, "message produced")
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
 		}
-		log.Info(ctx, "message produced")
-		time.Sleep(10 * time.Second)
 	}
 }
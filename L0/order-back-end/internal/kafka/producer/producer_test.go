@@ -0,0 +1,21 @@
+package kfk
+
+import (
+	"testing"
+
+	"order-back-end/internal/validator"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateOrderPassesStrictValidation(t *testing.T) {
+	rules, err := validator.New(validator.Config{Mode: validator.ModeStrict})
+	require.NoError(t, err)
+
+	for range 50 {
+		order := generateOrder()
+		warnings, err := rules.Validate(&order)
+		require.NoError(t, err)
+		require.Empty(t, warnings)
+	}
+}
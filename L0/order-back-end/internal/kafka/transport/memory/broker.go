@@ -0,0 +1,218 @@
+package memory
+
+import (
+	"fmt"
+	"hash/fnv"
+	"order-back-end/internal/kafka/transport"
+	"slices"
+	"sync"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Broker in-process брокер для тестов и локального запуска без Kafka: топики с партициями,
+// группы консьюмеров с ребалансировкой и закоммиченными offset'ами. Сообщения живут в памяти процесса
+type Broker struct {
+	partitions int // число партиций топика, создаваемого при первом обращении
+
+	mu     sync.Mutex
+	topics map[string]*topic
+	groups map[string]*group
+	wakeup chan struct{} // закрывается при каждом изменении, будит ждущих ReadMessage
+}
+
+// topic лог сообщений по партициям
+type topic struct {
+	name       string
+	partitions [][]*kafka.Message
+	next       int // партиция для следующего сообщения без ключа
+}
+
+// group члены группы и её закоммиченные offset'ы по партициям
+type group struct {
+	members   []*Consumer
+	committed map[partitionKey]int64
+}
+
+// partitionKey партиция топика
+type partitionKey struct {
+	topic     string
+	partition int32
+}
+
+// NewBroker создаёт брокер; топики без явного CreateTopic получают partitions партиций
+func NewBroker(partitions int) *Broker {
+	return &Broker{
+		partitions: max(partitions, 1),
+		topics:     make(map[string]*topic),
+		groups:     make(map[string]*group),
+		wakeup:     make(chan struct{}),
+	}
+}
+
+// CreateTopic создаёт топик с заданным числом партиций; существующий топик не меняется
+func (b *Broker) CreateTopic(name string, partitions int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.topics[name]; !ok {
+		b.topics[name] = &topic{name: name, partitions: make([][]*kafka.Message, max(partitions, 1))}
+	}
+}
+
+// Committed закоммиченный группой offset партиции, kafka.OffsetInvalid если коммита не было
+func (b *Broker) Committed(groupID, topic string, partition int32) kafka.Offset {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	g, ok := b.groups[groupID]
+	if !ok {
+		return kafka.OffsetInvalid
+	}
+	offset, ok := g.committed[partitionKey{topic: topic, partition: partition}]
+	if !ok {
+		return kafka.OffsetInvalid
+	}
+	return kafka.Offset(offset)
+}
+
+// NewSource создаёт консьюмера; из cfg берутся group.id, auto.offset.reset и enable.auto.commit.
+// Offset'ы всегда сохраняются вручную через StoreMessage, как при enable.auto.offset.store=false
+func (b *Broker) NewSource(cfg *kafka.ConfigMap) (transport.MessageSource, error) {
+	groupID, err := configString(cfg, "group.id", "")
+	if err != nil {
+		return nil, err
+	}
+	if groupID == "" {
+		return nil, fmt.Errorf("memory broker: group.id is required")
+	}
+	reset, err := configString(cfg, "auto.offset.reset", "latest")
+	if err != nil {
+		return nil, err
+	}
+	autoCommit, err := cfg.Get("enable.auto.commit", true)
+	if err != nil {
+		return nil, err
+	}
+	enabled, ok := autoCommit.(bool)
+	if !ok {
+		return nil, fmt.Errorf("memory broker: enable.auto.commit must be bool, got %T", autoCommit)
+	}
+
+	return &Consumer{
+		broker:     b,
+		groupID:    groupID,
+		fromStart:  reset == "earliest" || reset == "smallest" || reset == "beginning",
+		autoCommit: enabled,
+		positions:  make(map[int32]int64),
+		stored:     make(map[int32]int64),
+		paused:     make(map[int32]bool),
+	}, nil
+}
+
+// NewSink создаёт продьюсера
+func (b *Broker) NewSink(*kafka.ConfigMap) (transport.MessageSink, error) {
+	return &Producer{broker: b}, nil
+}
+
+// topicLocked возвращает топик, создавая его при первом обращении; вызывается под b.mu
+func (b *Broker) topicLocked(name string) *topic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &topic{name: name, partitions: make([][]*kafka.Message, b.partitions)}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// groupLocked возвращает группу, создавая её при первом обращении; вызывается под b.mu
+func (b *Broker) groupLocked(id string) *group {
+	g, ok := b.groups[id]
+	if !ok {
+		g = &group{committed: make(map[partitionKey]int64)}
+		b.groups[id] = g
+	}
+	return g
+}
+
+// notifyLocked будит всех, кто ждёт сообщений; вызывается под b.mu
+func (b *Broker) notifyLocked() {
+	close(b.wakeup)
+	b.wakeup = make(chan struct{})
+}
+
+// produce дописывает сообщение в партицию: явно заданную, по хэшу ключа или по кругу
+func (b *Broker) produce(msg *kafka.Message) (*kafka.Message, error) {
+	if msg.TopicPartition.Topic == nil {
+		return nil, kafka.NewError(kafka.ErrUnknownTopic, "topic is required", false)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topicLocked(*msg.TopicPartition.Topic)
+	partition := msg.TopicPartition.Partition
+	switch {
+	case partition == kafka.PartitionAny && len(msg.Key) > 0:
+		h := fnv.New32a()
+		h.Write(msg.Key)
+		partition = int32(h.Sum32() % uint32(len(t.partitions)))
+	case partition == kafka.PartitionAny:
+		partition = int32(t.next % len(t.partitions))
+		t.next++
+	case partition < 0 || int(partition) >= len(t.partitions):
+		return nil, kafka.NewError(kafka.ErrUnknownPartition, fmt.Sprintf("partition %d does not exist", partition), false)
+	}
+
+	stored := *msg
+	name := t.name
+	stored.TopicPartition = kafka.TopicPartition{
+		Topic:     &name,
+		Partition: partition,
+		Offset:    kafka.Offset(len(t.partitions[partition])),
+	}
+	t.partitions[partition] = append(t.partitions[partition], &stored)
+	b.notifyLocked()
+
+	delivered := stored
+	return &delivered, nil
+}
+
+// rebalanceLocked раздаёт партиции топика членам группы по кругу и ставит им события ребалансировки.
+// Как в eager протоколе, у всех членов сначала отзываются старые партиции, затем назначаются новые
+func (b *Broker) rebalanceLocked(g *group, topicName string) {
+	defer b.notifyLocked()
+	if len(g.members) == 0 {
+		return
+	}
+
+	t := b.topicLocked(topicName)
+	assignments := make([][]int32, len(g.members))
+	for p := range t.partitions {
+		i := p % len(g.members)
+		assignments[i] = append(assignments[i], int32(p))
+	}
+
+	// сначала все отзывают партиции (с автокоммитом), чтобы новые владельцы начали с закоммиченных offset'ов
+	for i, m := range g.members {
+		if !slices.Equal(m.assigned, assignments[i]) {
+			m.revokeLocked(g)
+		}
+	}
+	for i, m := range g.members {
+		if !slices.Equal(m.assigned, assignments[i]) {
+			m.assignLocked(g, assignments[i])
+		}
+	}
+}
+
+// configString строковая настройка из ConfigMap
+func configString(cfg *kafka.ConfigMap, key, def string) (string, error) {
+	v, err := cfg.Get(key, def)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("memory broker: %s must be string, got %T", key, v)
+	}
+	return s, nil
+}
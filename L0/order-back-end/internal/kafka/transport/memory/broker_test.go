@@ -0,0 +1,152 @@
+package memory
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"order-back-end/internal/kafka/transport"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+	"github.com/stretchr/testify/require"
+)
+
+const testTopic = "orders"
+
+func produce(t *testing.T, b *Broker, n int) {
+	t.Helper()
+	sink, err := b.NewSink(nil)
+	require.NoError(t, err)
+	topic := testTopic
+	for i := 0; i < n; i++ {
+		deliveries := make(chan kafka.Event)
+		require.NoError(t, sink.Produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Value:          []byte(fmt.Sprintf("message-%d", i)),
+		}, deliveries))
+		ev := <-deliveries
+		require.IsType(t, &kafka.Message{}, ev)
+	}
+}
+
+func newSource(t *testing.T, b *Broker, group string) (transport.MessageSource, *[]kafka.Event) {
+	t.Helper()
+	src, err := b.NewSource(&kafka.ConfigMap{
+		"group.id":           group,
+		"auto.offset.reset":  "earliest",
+		"enable.auto.commit": false,
+	})
+	require.NoError(t, err)
+
+	var events []kafka.Event
+	require.NoError(t, src.Subscribe(testTopic, func(_ *kafka.Consumer, ev kafka.Event) error {
+		events = append(events, ev)
+		return nil
+	}))
+	return src, &events
+}
+
+func readAll(t *testing.T, src transport.MessageSource) []*kafka.Message {
+	t.Helper()
+	var messages []*kafka.Message
+	for {
+		msg, err := src.ReadMessage(20 * time.Millisecond)
+		var kErr kafka.Error
+		if errors.As(err, &kErr) && kErr.Code() == kafka.ErrTimedOut {
+			return messages
+		}
+		require.NoError(t, err)
+		messages = append(messages, msg)
+	}
+}
+
+func TestBrokerGroupSplitsPartitions(t *testing.T) {
+	b := NewBroker(4)
+	produce(t, b, 8)
+
+	first, firstEvents := newSource(t, b, "group")
+	second, _ := newSource(t, b, "group")
+
+	a, c := readAll(t, first), readAll(t, second)
+	require.Len(t, a, 4)
+	require.Len(t, c, 4)
+
+	seen := make(map[int32]bool)
+	for _, m := range a {
+		seen[m.TopicPartition.Partition] = true
+	}
+	for _, m := range c {
+		require.False(t, seen[m.TopicPartition.Partition], "a partition must belong to one member")
+	}
+
+	// первый получил все партиции, затем отдал половину второму
+	require.Len(t, *firstEvents, 3)
+	require.IsType(t, kafka.AssignedPartitions{}, (*firstEvents)[0])
+	require.IsType(t, kafka.RevokedPartitions{}, (*firstEvents)[1])
+	require.IsType(t, kafka.AssignedPartitions{}, (*firstEvents)[2])
+}
+
+func TestBrokerResumesFromCommittedOffset(t *testing.T) {
+	b := NewBroker(1)
+	produce(t, b, 5)
+
+	src, _ := newSource(t, b, "group")
+	messages := readAll(t, src)
+	require.Len(t, messages, 5)
+
+	_, err := src.Commit()
+	require.Error(t, err, "nothing stored yet")
+
+	_, err = src.StoreMessage(messages[2])
+	require.NoError(t, err)
+	_, err = src.Commit()
+	require.NoError(t, err)
+	require.Equal(t, kafka.Offset(3), b.Committed("group", testTopic, 0))
+	require.NoError(t, src.Close())
+
+	// новый член группы продолжает с закоммиченного offset'а
+	next, _ := newSource(t, b, "group")
+	rest := readAll(t, next)
+	require.Len(t, rest, 2)
+	require.Equal(t, kafka.Offset(3), rest[0].TopicPartition.Offset)
+}
+
+func TestBrokerPauseAndSeek(t *testing.T) {
+	b := NewBroker(1)
+	produce(t, b, 3)
+
+	src, _ := newSource(t, b, "group")
+	first, err := src.ReadMessage(time.Second)
+	require.NoError(t, err)
+
+	require.NoError(t, src.Pause([]kafka.TopicPartition{first.TopicPartition}))
+	require.Empty(t, readAll(t, src), "paused partition must not deliver messages")
+
+	require.NoError(t, src.Resume([]kafka.TopicPartition{first.TopicPartition}))
+	require.NoError(t, src.Seek(first.TopicPartition, 0))
+	again := readAll(t, src)
+	require.Len(t, again, 3, "seek rewinds to the given offset")
+
+	_, high, err := src.GetWatermarkOffsets(testTopic, 0)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), high)
+}
+
+func TestBrokerReadWakesOnProduce(t *testing.T) {
+	b := NewBroker(1)
+	src, _ := newSource(t, b, "group")
+
+	topic := testTopic
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_, _ = b.produce(&kafka.Message{
+			TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+			Value:          []byte("message-0"),
+		})
+	}()
+
+	msg, err := src.ReadMessage(5 * time.Second)
+	require.NoError(t, err)
+	require.Equal(t, "message-0", string(msg.Value))
+}
@@ -0,0 +1,284 @@
+package memory
+
+import (
+	"slices"
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// errClosed консьюмер уже закрыт
+var errClosed = kafka.NewError(kafka.ErrState, "consumer is closed", false)
+
+// Consumer член группы in-memory брокера, подписанный на один топик
+type Consumer struct {
+	broker     *Broker
+	groupID    string
+	fromStart  bool // auto.offset.reset=earliest: без коммита читать партицию с начала
+	autoCommit bool // enable.auto.commit: сохранённый offset сразу коммитится
+
+	// поля ниже под broker.mu
+	topic       string
+	rebalanceCb kafka.RebalanceCb
+	assigned    []int32
+	positions   map[int32]int64 // offset следующего сообщения для чтения
+	stored      map[int32]int64 // сохранённые, но ещё не закоммиченные offset'ы
+	paused      map[int32]bool
+	events      []kafka.Event // события ребалансировки, которые получит rebalanceCb при следующем чтении
+	next        int           // с какой из назначенных партиций начинать поиск сообщения
+	closed      bool
+}
+
+// Subscribe вступает в группу; партиции назначаются при ребалансировке всей группы
+func (c *Consumer) Subscribe(topic string, rebalanceCb kafka.RebalanceCb) error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c.closed {
+		return errClosed
+	}
+	g := b.groupLocked(c.groupID)
+	c.topic = topic
+	c.rebalanceCb = rebalanceCb
+	if !slices.Contains(g.members, c) {
+		g.members = append(g.members, c)
+	}
+	b.rebalanceLocked(g, topic)
+	return nil
+}
+
+// ReadMessage возвращает следующее сообщение из назначенных непоставленных на паузу партиций.
+// Перед этим, как и librdkafka, вызывает rebalanceCb для накопившихся событий ребалансировки.
+// По истечении timeout возвращает ошибку kafka.ErrTimedOut, отрицательный timeout - ждать без ограничения
+func (c *Consumer) ReadMessage(timeout time.Duration) (*kafka.Message, error) {
+	var deadline <-chan time.Time
+	if timeout >= 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		c.deliverEvents()
+
+		b := c.broker
+		b.mu.Lock()
+		if c.closed {
+			b.mu.Unlock()
+			return nil, errClosed
+		}
+		msg := c.nextLocked()
+		wakeup := b.wakeup
+		b.mu.Unlock()
+
+		if msg != nil {
+			return msg, nil
+		}
+		select {
+		case <-wakeup:
+		case <-deadline:
+			return nil, kafka.NewError(kafka.ErrTimedOut, "timed out waiting for message", false)
+		}
+	}
+}
+
+// deliverEvents передаёт накопившиеся события ребалансировки в rebalanceCb вне блокировки брокера
+func (c *Consumer) deliverEvents() {
+	b := c.broker
+	b.mu.Lock()
+	events, cb := c.events, c.rebalanceCb
+	c.events = nil
+	b.mu.Unlock()
+
+	if cb == nil {
+		return
+	}
+	for _, ev := range events {
+		_ = cb(nil, ev)
+	}
+}
+
+// nextLocked следующее сообщение по кругу назначенных партиций; вызывается под broker.mu
+func (c *Consumer) nextLocked() *kafka.Message {
+	t := c.broker.topicLocked(c.topic)
+	for i := range c.assigned {
+		idx := (c.next + i) % len(c.assigned)
+		p := c.assigned[idx]
+		if c.paused[p] || c.positions[p] >= int64(len(t.partitions[p])) {
+			continue
+		}
+		stored := t.partitions[p][c.positions[p]]
+		c.positions[p]++
+		c.next = idx + 1
+
+		msg := *stored
+		return &msg
+	}
+	return nil
+}
+
+// StoreMessage сохраняет offset за сообщением m для коммита
+func (c *Consumer) StoreMessage(m *kafka.Message) ([]kafka.TopicPartition, error) {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	p := m.TopicPartition.Partition
+	if !slices.Contains(c.assigned, p) {
+		return nil, kafka.NewError(kafka.ErrState, "partition is not assigned", false)
+	}
+	offset := int64(m.TopicPartition.Offset) + 1
+	c.stored[p] = offset
+	if c.autoCommit {
+		c.commitLocked(b.groupLocked(c.groupID))
+	}
+	return []kafka.TopicPartition{c.partition(p, offset)}, nil
+}
+
+// Commit коммитит сохранённые offset'ы; если коммитить нечего - ошибка kafka.ErrNoOffset
+func (c *Consumer) Commit() ([]kafka.TopicPartition, error) {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	committed := c.commitLocked(b.groupLocked(c.groupID))
+	if len(committed) == 0 {
+		return nil, kafka.NewError(kafka.ErrNoOffset, "no offset to commit", false)
+	}
+	return committed, nil
+}
+
+// commitLocked переносит сохранённые offset'ы в группу; вызывается под broker.mu
+func (c *Consumer) commitLocked(g *group) []kafka.TopicPartition {
+	var committed []kafka.TopicPartition
+	for p, offset := range c.stored {
+		g.committed[partitionKey{topic: c.topic, partition: p}] = offset
+		committed = append(committed, c.partition(p, offset))
+	}
+	clear(c.stored)
+	return committed
+}
+
+// Pause перестаёт отдавать сообщения из партиций
+func (c *Consumer) Pause(partitions []kafka.TopicPartition) error {
+	return c.setPaused(partitions, true)
+}
+
+// Resume снова отдаёт сообщения из партиций
+func (c *Consumer) Resume(partitions []kafka.TopicPartition) error {
+	return c.setPaused(partitions, false)
+}
+
+func (c *Consumer) setPaused(partitions []kafka.TopicPartition, paused bool) error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, tp := range partitions {
+		if !slices.Contains(c.assigned, tp.Partition) {
+			return kafka.NewError(kafka.ErrState, "partition is not assigned", false)
+		}
+		c.paused[tp.Partition] = paused
+	}
+	b.notifyLocked()
+	return nil
+}
+
+// Seek переводит позицию чтения назначенной партиции на partition.Offset
+func (c *Consumer) Seek(partition kafka.TopicPartition, _ int) error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !slices.Contains(c.assigned, partition.Partition) {
+		return kafka.NewError(kafka.ErrState, "partition is not assigned", false)
+	}
+	c.positions[partition.Partition] = int64(partition.Offset)
+	b.notifyLocked()
+	return nil
+}
+
+// GetWatermarkOffsets границы партиции: первый offset и offset следующего сообщения
+func (c *Consumer) GetWatermarkOffsets(topic string, partition int32) (low, high int64, err error) {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t := b.topicLocked(topic)
+	if partition < 0 || int(partition) >= len(t.partitions) {
+		return 0, 0, kafka.NewError(kafka.ErrUnknownPartition, "partition does not exist", false)
+	}
+	return 0, int64(len(t.partitions[partition])), nil
+}
+
+// Close при автокоммите коммитит сохранённые offset'ы и покидает группу, её партиции переходят остальным
+func (c *Consumer) Close() error {
+	b := c.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if c.closed {
+		return errClosed
+	}
+	c.closed = true
+
+	g := b.groupLocked(c.groupID)
+	if c.autoCommit {
+		c.commitLocked(g)
+	}
+	g.members = slices.DeleteFunc(g.members, func(m *Consumer) bool { return m == c })
+	if c.topic != "" {
+		b.rebalanceLocked(g, c.topic)
+	}
+	return nil
+}
+
+// revokeLocked отзывает назначенные партиции, при автокоммите сначала коммитит сохранённые offset'ы;
+// вызывается под broker.mu при ребалансировке группы
+func (c *Consumer) revokeLocked(g *group) {
+	if len(c.assigned) > 0 {
+		if c.autoCommit {
+			c.commitLocked(g)
+		}
+		c.events = append(c.events, kafka.RevokedPartitions{Partitions: c.partitions(c.assigned)})
+	}
+	c.assigned = nil
+	clear(c.positions)
+	clear(c.stored)
+	clear(c.paused)
+}
+
+// assignLocked назначает партиции, чтение начинается с закоммиченного группой offset'а
+// или по auto.offset.reset; вызывается под broker.mu после revokeLocked
+func (c *Consumer) assignLocked(g *group, partitions []int32) {
+	t := c.broker.topicLocked(c.topic)
+	for _, p := range partitions {
+		switch offset, ok := g.committed[partitionKey{topic: c.topic, partition: p}]; {
+		case ok:
+			c.positions[p] = offset
+		case c.fromStart:
+			c.positions[p] = 0
+		default:
+			c.positions[p] = int64(len(t.partitions[p]))
+		}
+	}
+	c.assigned = partitions
+	c.next = 0
+	c.events = append(c.events, kafka.AssignedPartitions{Partitions: c.partitions(partitions)})
+}
+
+// partition TopicPartition подписанного топика
+func (c *Consumer) partition(p int32, offset int64) kafka.TopicPartition {
+	topic := c.topic
+	return kafka.TopicPartition{Topic: &topic, Partition: p, Offset: kafka.Offset(offset)}
+}
+
+// partitions TopicPartition'ы подписанного топика без offset'а
+func (c *Consumer) partitions(ps []int32) []kafka.TopicPartition {
+	result := make([]kafka.TopicPartition, len(ps))
+	for i, p := range ps {
+		result[i] = c.partition(p, int64(kafka.OffsetInvalid))
+	}
+	return result
+}
@@ -0,0 +1,31 @@
+package memory
+
+import (
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// Producer пишет сообщения в топики in-memory брокера
+type Producer struct {
+	broker *Broker
+}
+
+// Produce сразу записывает сообщение; отчёт о доставке, если передан deliveryChan, отправляется в него
+// асинхронно, как у librdkafka, поэтому канал может быть небуферизованным
+func (p *Producer) Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error {
+	delivered, err := p.broker.produce(msg)
+	if err != nil {
+		return err
+	}
+	if deliveryChan != nil {
+		go func() { deliveryChan <- delivered }()
+	}
+	return nil
+}
+
+// Flush ждать нечего: сообщения записываются в Produce
+func (p *Producer) Flush(int) int {
+	return 0
+}
+
+// Close ничего не освобождает
+func (p *Producer) Close() {}
@@ -0,0 +1,60 @@
+package transport
+
+import (
+	"time"
+
+	"github.com/confluentinc/confluent-kafka-go/v2/kafka"
+)
+
+// MessageSource источник сообщений для консьюмера; методы совпадают с *kafka.Consumer
+type MessageSource interface {
+	Subscribe(topic string, rebalanceCb kafka.RebalanceCb) error
+	ReadMessage(timeout time.Duration) (*kafka.Message, error)
+	StoreMessage(m *kafka.Message) ([]kafka.TopicPartition, error)
+	Commit() ([]kafka.TopicPartition, error)
+	Pause(partitions []kafka.TopicPartition) error
+	Resume(partitions []kafka.TopicPartition) error
+	Seek(partition kafka.TopicPartition, ignoredTimeoutMs int) error
+	GetWatermarkOffsets(topic string, partition int32) (low, high int64, err error)
+	Close() error
+}
+
+// MessageSink приёмник сообщений для продьюсера; методы совпадают с *kafka.Producer
+type MessageSink interface {
+	Produce(msg *kafka.Message, deliveryChan chan kafka.Event) error
+	Flush(timeoutMs int) int
+	Close()
+}
+
+// Transport создаёт источники и приёмники сообщений по настройкам librdkafka
+type Transport interface {
+	NewSource(cfg *kafka.ConfigMap) (MessageSource, error)
+	NewSink(cfg *kafka.ConfigMap) (MessageSink, error)
+}
+
+// Транспорты, которые можно выбрать в конфиге
+const (
+	NameKafka  = "kafka"
+	NameMemory = "memory"
+)
+
+// Kafka транспорт поверх confluent-kafka-go
+type Kafka struct{}
+
+// NewSource создаёт консьюмера librdkafka
+func (Kafka) NewSource(cfg *kafka.ConfigMap) (MessageSource, error) {
+	c, err := kafka.NewConsumer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// NewSink создаёт продьюсера librdkafka
+func (Kafka) NewSink(cfg *kafka.ConfigMap) (MessageSink, error) {
+	p, err := kafka.NewProducer(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
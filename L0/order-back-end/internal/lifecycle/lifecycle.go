@@ -0,0 +1,123 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"order-back-end/internal/logger"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// ErrStopped задачу нельзя запустить после начала Shutdown
+var ErrStopped = errors.New("lifecycle is shutting down")
+
+// hook функция остановки компонента
+type hook struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// Manager управляет фоновыми компонентами сервиса: запускает их под общим контекстом
+// и останавливает в заданном порядке с общим дедлайном.
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu       sync.Mutex
+	stopping bool // Shutdown начался, новые задачи не запускаются
+	hooks    []hook
+	errs     []error
+}
+
+// New создаёт Manager, контекст фоновых задач наследуется от parent (в нём логгер)
+func New(parent context.Context) *Manager {
+	ctx, cancel := context.WithCancel(parent)
+	return &Manager{ctx: ctx, cancel: cancel}
+}
+
+// Context контекст фоновых задач, отменяется в начале Shutdown
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// Go запускает фоновую задачу; run должен вернуть управление после отмены ctx,
+// освободив свои ресурсы (закоммитив offset'ы, сбросив буферы и т.п.).
+// После начала Shutdown задача не запускается и возвращается ErrStopped
+func (m *Manager) Go(name string, run func(ctx context.Context) error) error {
+	m.mu.Lock()
+	if m.stopping {
+		m.mu.Unlock()
+		return ErrStopped
+	}
+	// под mu, чтобы Add не пересёкся с Wait в Shutdown
+	m.wg.Add(1)
+	m.mu.Unlock()
+
+	go func() {
+		defer m.wg.Done()
+		if err := run(m.ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logger.GetOrCreateLoggerFromCtx(m.ctx).Error(m.ctx, "background component failed",
+				zap.String("component", name), zap.Error(err))
+			m.addErr(fmt.Errorf("%s: %w", name, err))
+		}
+	}()
+	return nil
+}
+
+// OnStop регистрирует остановку компонента; хуки вызываются после завершения всех задач Go
+// в обратном порядке регистрации: то, что создано первым (пул соединений), закрывается последним
+func (m *Manager) OnStop(name string, stop func(ctx context.Context) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, hook{name: name, stop: stop})
+}
+
+// Shutdown отменяет контекст задач, ждёт их завершения и вызывает хуки остановки.
+// Всё должно уложиться в дедлайн ctx, иначе возвращается ошибка с незавершёнными шагами.
+func (m *Manager) Shutdown(ctx context.Context) error {
+	log := logger.GetOrCreateLoggerFromCtx(m.ctx)
+	m.mu.Lock()
+	m.stopping = true
+	m.mu.Unlock()
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Info(ctx, "background components stopped")
+	case <-ctx.Done():
+		m.addErr(fmt.Errorf("background components did not stop in time: %w", ctx.Err()))
+	}
+
+	m.mu.Lock()
+	hooks := m.hooks
+	m.mu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if err := h.stop(ctx); err != nil {
+			m.addErr(fmt.Errorf("%s: %w", h.name, err))
+			continue
+		}
+		log.Info(ctx, "component stopped", zap.String("component", h.name))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return errors.Join(m.errs...)
+}
+
+// addErr копит ошибки компонентов для итога Shutdown
+func (m *Manager) addErr(err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.errs = append(m.errs, err)
+}
@@ -0,0 +1,81 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestManagerShutdownOrder(t *testing.T) {
+	m := New(context.Background())
+
+	var mu sync.Mutex
+	var events []string
+	record := func(e string) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, e)
+	}
+
+	m.Go("consumer", func(ctx context.Context) error {
+		<-ctx.Done()
+		record("consumer stopped")
+		return ctx.Err()
+	})
+	m.OnStop("pool", func(context.Context) error {
+		record("pool closed")
+		return nil
+	})
+	m.OnStop("http", func(context.Context) error {
+		record("http stopped")
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, m.Shutdown(ctx))
+	require.Equal(t, []string{"consumer stopped", "http stopped", "pool closed"}, events)
+}
+
+func TestManagerShutdownDeadline(t *testing.T) {
+	m := New(context.Background())
+
+	block := make(chan struct{})
+	defer close(block)
+	m.Go("stuck", func(context.Context) error {
+		<-block
+		return nil
+	})
+
+	hookCalled := false
+	m.OnStop("pool", func(context.Context) error {
+		hookCalled = true
+		return errors.New("close failed")
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err := m.Shutdown(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.ErrorContains(t, err, "pool: close failed")
+	require.True(t, hookCalled, "hooks must run even if a component is stuck")
+}
+
+func TestManagerGoAfterShutdown(t *testing.T) {
+	m := New(context.Background())
+	require.NoError(t, m.Shutdown(context.Background()))
+
+	started := false
+	err := m.Go("late", func(context.Context) error {
+		started = true
+		return nil
+	})
+	require.ErrorIs(t, err, ErrStopped)
+	require.False(t, started)
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"order-back-end/internal/cache"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// cacheCollector читает cache.Stats() в момент scrape, счётчики живут в самом кэше
+type cacheCollector struct {
+	cache       cache.Cache
+	hits        *prometheus.Desc
+	misses      *prometheus.Desc
+	expirations *prometheus.Desc
+	evictions   *prometheus.Desc
+	size        *prometheus.Desc
+	capacity    *prometheus.Desc
+}
+
+// NewCacheCollector создаёт коллектор метрик кэша заказов
+func NewCacheCollector(c cache.Cache) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "cache", name), help, nil, nil)
+	}
+	return &cacheCollector{
+		cache:       c,
+		hits:        desc("hits_total", "Number of cache lookups that returned an order."),
+		misses:      desc("misses_total", "Number of cache lookups that found nothing."),
+		expirations: desc("expirations_total", "Number of orders removed after their TTL expired."),
+		evictions:   desc("evictions_total", "Number of orders evicted because the cache was full."),
+		size:        desc("size", "Current number of orders in the cache."),
+		capacity:    desc("capacity", "Maximum number of orders in the cache, 0 means unlimited."),
+	}
+}
+
+// Describe реализует prometheus.Collector
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+	ch <- c.expirations
+	ch <- c.evictions
+	ch <- c.size
+	ch <- c.capacity
+}
+
+// Collect реализует prometheus.Collector
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	st := c.cache.Stats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(st.Hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(st.Misses))
+	ch <- prometheus.MustNewConstMetric(c.expirations, prometheus.CounterValue, float64(st.Expirations))
+	ch <- prometheus.MustNewConstMetric(c.evictions, prometheus.CounterValue, float64(st.Evictions))
+	ch <- prometheus.MustNewConstMetric(c.size, prometheus.GaugeValue, float64(st.Size))
+	ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(st.Capacity))
+}
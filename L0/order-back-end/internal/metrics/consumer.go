@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Этапы обработки сообщения консьюмером
+const (
+	StageConsumed  = "consumed"
+	StageValidated = "validated"
+	StageRejected  = "rejected"
+	StagePersisted = "persisted"
+	StageFailed    = "failed"
+	// StageDeadLettered сообщение отклонено и отправлено в dead-letter топик
+	StageDeadLettered = "dead_lettered"
+	// StageRetried повторная попытка обработки после временной ошибки базы
+	StageRetried = "retried"
+	// StageDuplicate повторная доставка заказа с тем же содержимым, в базу ничего не записано
+	StageDuplicate = "duplicate"
+	// StageStuck сообщение исчерпало попытки и не ушло в DLQ, его партиция остаётся на паузе
+	StageStuck = "stuck"
+	// StageWarned заказ принят в мягком режиме валидации с нарушениями бизнес-правил
+	StageWarned = "warned"
+)
+
+var (
+	consumerMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "messages_total",
+		Help:      "Kafka messages by consumer number and processing stage.",
+	}, []string{"consumer", "stage"})
+
+	consumerLag = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "lag",
+		Help:      "Difference between the partition high watermark and the last consumed offset.",
+	}, []string{"consumer", "topic", "partition"})
+
+	consumerStuck = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "stuck_partitions",
+		Help:      "Partitions paused on a message that exhausted retries and cannot be dead-lettered.",
+	}, []string{"consumer"})
+
+	consumerBatchSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "consumer",
+		Name:      "batch_size",
+		Help:      "Number of Kafka messages written to Postgres in one transaction in batch mode.",
+		Buckets:   prometheus.ExponentialBuckets(2, 2, 10),
+	}, []string{"consumer"})
+)
+
+func init() {
+	Registry.MustRegister(consumerMessages, consumerLag, consumerStuck, consumerBatchSize)
+}
+
+// ConsumerMessage увеличивает счётчик сообщений консьюмера на этапе stage
+func ConsumerMessage(consumerNumber int, stage string) {
+	consumerMessages.WithLabelValues(strconv.Itoa(consumerNumber), stage).Inc()
+}
+
+// ConsumerLag выставляет отставание консьюмера по партиции
+func ConsumerLag(consumerNumber int, topic string, partition int32, lag int64) {
+	if lag < 0 {
+		lag = 0
+	}
+	consumerLag.
+		WithLabelValues(strconv.Itoa(consumerNumber), topic, strconv.Itoa(int(partition))).
+		Set(float64(lag))
+}
+
+// ConsumerStuck выставляет число застрявших партиций консьюмера
+func ConsumerStuck(consumerNumber int, partitions int) {
+	consumerStuck.WithLabelValues(strconv.Itoa(consumerNumber)).Set(float64(partitions))
+}
+
+// ConsumerBatch учитывает размер пачки, записанной консьюмером в пакетном режиме
+func ConsumerBatch(consumerNumber int, size int) {
+	consumerBatchSize.WithLabelValues(strconv.Itoa(consumerNumber)).Observe(float64(size))
+}
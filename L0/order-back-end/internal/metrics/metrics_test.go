@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGinMiddlewareUsesRouteTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(GinMiddleware())
+	router.GET("/order/:id", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	for _, id := range []string{"a", "b"} {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/order/"+id, nil))
+	}
+
+	families, err := Registry.Gather()
+	require.NoError(t, err)
+
+	var samples uint64
+	for _, mf := range families {
+		if mf.GetName() != "order_service_http_request_duration_seconds" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			labels := map[string]string{}
+			for _, lp := range m.GetLabel() {
+				labels[lp.GetName()] = lp.GetValue()
+			}
+			require.NotEqual(t, "/order/a", labels["route"], "raw path must not be used as a label")
+			if labels["route"] == "/order/:id" && labels["status"] == "404" {
+				samples += m.GetHistogram().GetSampleCount()
+			}
+		}
+	}
+	require.Equal(t, uint64(2), samples)
+}
+
+func TestConsumerMessage(t *testing.T) {
+	ConsumerMessage(7, StagePersisted)
+	ConsumerMessage(7, StagePersisted)
+
+	require.Equal(t, 2.0, testutil.ToFloat64(consumerMessages.WithLabelValues("7", StagePersisted)))
+}
@@ -0,0 +1,21 @@
+package model
+
+import "time"
+
+// OrderFilter параметры фильтрации и keyset-пагинации списка заказов
+type OrderFilter struct {
+	CustomerID      string
+	DeliveryService string
+	Locale          string
+	Currency        string
+	DateFrom        time.Time
+	DateTo          time.Time
+	Limit           int
+	Cursor          string
+}
+
+// OrderPage страница заказов и курсор на следующую страницу
+type OrderPage struct {
+	Orders     []OrderInfo `json:"orders"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
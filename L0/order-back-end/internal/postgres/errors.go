@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// IsTransient true, если ошибка временная и запрос имеет смысл повторить:
+// нет соединения, таймаут, сервер перегружен или перезапускается, конфликт сериализации или deadlock.
+// Нарушения ограничений, ошибки данных и синтаксиса считаются постоянными.
+func IsTransient(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case "40001", // serialization_failure
+			"40P01", // deadlock_detected
+			"55P03": // lock_not_available
+			return true
+		}
+		if len(pgErr.Code) >= 2 {
+			switch pgErr.Code[:2] {
+			case "08", // connection exception
+				"53", // insufficient resources
+				"57": // operator intervention (admin shutdown, cannot connect now)
+				return true
+			}
+		}
+		return false
+	}
+
+	var connErr *pgconn.ConnectError
+	if errors.As(err, &connErr) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// запрос гарантированно не дошёл до сервера
+	return pgconn.SafeToRetry(err)
+}
+
+// IsUniqueViolation нарушение уникальности (23505): запись конфликтует с уже сохранённой
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// IsInvalidValue значение не помещается в колонку: слишком длинная строка (22001) или число вне диапазона (22003)
+func IsInvalidValue(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "22001" || pgErr.Code == "22003")
+}
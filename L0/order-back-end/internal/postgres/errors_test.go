@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "nil", err: nil, want: false},
+		{name: "deadline", err: fmt.Errorf("begin: %w", context.DeadlineExceeded), want: true},
+		{name: "canceled", err: context.Canceled, want: false},
+		{name: "connection failure", err: &pgconn.PgError{Code: "08006"}, want: true},
+		{name: "serialization failure", err: &pgconn.PgError{Code: "40001"}, want: true},
+		{name: "deadlock", err: &pgconn.PgError{Code: "40P01"}, want: true},
+		{name: "too many connections", err: &pgconn.PgError{Code: "53300"}, want: true},
+		{name: "cannot connect now", err: &pgconn.PgError{Code: "57P03"}, want: true},
+		{name: "unique violation", err: &pgconn.PgError{Code: "23505"}, want: false},
+		{name: "value too long", err: &pgconn.PgError{Code: "22001"}, want: false},
+		{name: "no rows", err: pgx.ErrNoRows, want: false},
+		{name: "plain error", err: errors.New("boom"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.want, IsTransient(tt.err))
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	require.True(t, IsUniqueViolation(fmt.Errorf("insert: %w", &pgconn.PgError{Code: "23505"})))
+	require.False(t, IsUniqueViolation(&pgconn.PgError{Code: "23503"}))
+	require.True(t, IsInvalidValue(&pgconn.PgError{Code: "22001"}))
+	require.True(t, IsInvalidValue(&pgconn.PgError{Code: "22003"}))
+	require.False(t, IsInvalidValue(&pgconn.PgError{Code: "08006"}))
+	require.False(t, IsInvalidValue(errors.New("boom")))
+}
@@ -2,202 +2,196 @@ package order
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"order-back-end/internal/model"
+	"time"
 
 	sq "github.com/Masterminds/squirrel"
-	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 type Repo interface {
 	GetAllOrders(ctx context.Context) ([]model.OrderInfo, error)
 	GetOrderFromDB(ctx context.Context, orderID string) (*model.OrderInfo, error)
+	ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error)
+	GetOrdersByTrack(ctx context.Context, trackNumber string) ([]model.OrderInfo, error)
+
+	SaveOrder(ctx context.Context, order model.OrderInfo) error
+	UpsertOrder(ctx context.Context, order model.OrderInfo) (bool, error)
+	UpsertOrders(ctx context.Context, orders map[string]model.OrderInfo) (map[string]bool, error)
+	DeleteOrder(ctx context.Context, orderID string) error
+	WithTx(ctx context.Context, fn func(ctx context.Context, repo Repo) error) error
+}
+
+// Querier выполняет запросы; реализуется *pgxpool.Pool и pgx.Tx
+type Querier interface {
+	Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+	CopyFrom(ctx context.Context, tableName pgx.Identifier, columnNames []string, rowSrc pgx.CopyFromSource) (int64, error)
+}
+
+// DB пул соединений, открывающий транзакции; реализуется *pgxpool.Pool, в тестах подменяется для внедрения сбоев
+type DB interface {
+	Querier
+	BeginTx(ctx context.Context, txOptions pgx.TxOptions) (pgx.Tx, error)
+}
+
+const (
+	// DefaultListLimit размер страницы, если limit не передан
+	DefaultListLimit = 20
+	// MaxListLimit максимальный размер страницы
+	MaxListLimit = 100
+)
+
+// ErrInvalidCursor курсор пагинации не удалось разобрать
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// listCursor позиция последнего заказа на странице
+type listCursor struct {
+	DateCreated time.Time `json:"d"`
+	OrderUID    string    `json:"id"`
 }
 
 // OrderRepo репозиторий, часть слоистой архитектуры
 type OrderRepo struct {
-	db   *pgxpool.Pool
+	db   Querier
+	pool DB // nil у репозитория внутри транзакции
 	psql sq.StatementBuilderType
 }
 
 var _ Repo = (*OrderRepo)(nil)
 
 // NewRepository создаем экземпляр репозитория
-func NewRepository(db *pgxpool.Pool) *OrderRepo {
+func NewRepository(db DB) *OrderRepo {
 	return &OrderRepo{
 		db:   db,
+		pool: db,
 		psql: sq.StatementBuilder.PlaceholderFormat(sq.Dollar),
 	}
 }
 
 // GetAllOrders загружает все заказы с подгрузкой связанных данных
 func (r *OrderRepo) GetAllOrders(ctx context.Context) ([]model.OrderInfo, error) {
-	query, args, err := r.psql.
-		Select("order_uid", "track_number", "entry", "locale", "internal_signature",
-			"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard").
-		From("orders").
+	return r.queryOrders(ctx, r.selectOrders())
+}
+
+// GetOrderFromDB один заказ по ID
+func (r *OrderRepo) GetOrderFromDB(ctx context.Context, orderID string) (*model.OrderInfo, error) {
+	query, args, err := r.selectOrders().
+		Where(sq.Eq{"o.order_uid": orderID}).
 		ToSql()
 	if err != nil {
 		return nil, err
 	}
 
-	rows, err := r.db.Query(ctx, query, args...)
-	if err != nil {
+	var o model.OrderInfo
+	if err := scanOrder(r.db.QueryRow(ctx, query, args...), &o); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	var orders []model.OrderInfo
 
-	for rows.Next() {
-		var o model.OrderInfo
-		if err := rows.Scan(
-			&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
-			&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard,
-		); err != nil {
-			return nil, err
-		}
-
-		// Delivery
-		dq, dargs, _ := r.psql.
-			Select("name", "phone", "zip", "city", "address", "region", "email").
-			From("deliveries").
-			Where(sq.Eq{"order_uid": o.OrderUID}).
-			ToSql()
-
-		err = r.db.QueryRow(ctx, dq, dargs...).Scan(
-			&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip, &o.Delivery.City,
-			&o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email,
-		)
-		if err != nil {
-			return nil, err
-		}
+	orders := []model.OrderInfo{o}
+	if err := r.loadItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	return &orders[0], nil
+}
 
-		// Payment
-		pq, pargs, _ := r.psql.
-			Select("transaction", "request_id", "currency", "provider", "amount",
-				"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee").
-			From("payments").
-			Where(sq.Eq{"order_uid": o.OrderUID}).
-			ToSql()
-
-		err = r.db.QueryRow(ctx, pq, pargs...).Scan(
-			&o.Payment.Transaction, &o.Payment.RequestID, &o.Payment.Currency,
-			&o.Payment.Provider, &o.Payment.Amount, &o.Payment.PaymentDT,
-			&o.Payment.Bank, &o.Payment.DeliveryCost, &o.Payment.GoodsTotal, &o.Payment.CustomFee,
-		)
-		if err != nil {
-			return nil, err
-		}
+// ListOrders возвращает страницу заказов, отсортированных по date_created и order_uid по убыванию
+func (r *OrderRepo) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
 
-		// Items
-		iq, iargs, _ := r.psql.
-			Select("chrt_id", "track_number", "price", "rid", "name",
-				"sale", "size", "total_price", "nm_id", "brand", "status").
-			From("items").
-			Where(sq.Eq{"order_uid": o.OrderUID}).
-			ToSql()
+	builder := r.selectOrders().
+		OrderBy("o.date_created DESC", "o.order_uid DESC").
+		Limit(uint64(limit + 1)) // берём на один больше, чтобы понять есть ли следующая страница
 
-		itemRows, err := r.db.Query(ctx, iq, iargs...)
+	if filter.CustomerID != "" {
+		builder = builder.Where(sq.Eq{"o.customer_id": filter.CustomerID})
+	}
+	if filter.DeliveryService != "" {
+		builder = builder.Where(sq.Eq{"o.delivery_service": filter.DeliveryService})
+	}
+	if filter.Locale != "" {
+		builder = builder.Where(sq.Eq{"o.locale": filter.Locale})
+	}
+	if filter.Currency != "" {
+		builder = builder.Where(sq.Eq{"p.currency": filter.Currency})
+	}
+	if !filter.DateFrom.IsZero() {
+		builder = builder.Where(sq.GtOrEq{"o.date_created": filter.DateFrom})
+	}
+	if !filter.DateTo.IsZero() {
+		builder = builder.Where(sq.Lt{"o.date_created": filter.DateTo})
+	}
+	if filter.Cursor != "" {
+		cur, err := decodeCursor(filter.Cursor)
 		if err != nil {
 			return nil, err
 		}
-
-		for itemRows.Next() {
-			var it model.Item
-			if err := itemRows.Scan(
-				&it.ChrtID, &it.TrackNumber, &it.Price, &it.RID, &it.Name,
-				&it.Sale, &it.Size, &it.TotalPrice, &it.NmID, &it.Brand, &it.Status,
-			); err != nil {
-				itemRows.Close()
-				return nil, err
-			}
-			o.Items = append(o.Items, it)
-		}
-		itemRows.Close()
-
-		orders = append(orders, o)
+		builder = builder.Where(sq.Expr("(o.date_created, o.order_uid) < (?, ?)", cur.DateCreated, cur.OrderUID))
 	}
 
-	return orders, nil
-}
-
-// GetOrderFromDB один заказ по ID
-func (r *OrderRepo) GetOrderFromDB(ctx context.Context, orderID string) (*model.OrderInfo, error) {
-	var o model.OrderInfo
-
-	// Order
-	oq, oargs, _ := r.psql.
-		Select("order_uid", "track_number", "entry", "locale", "internal_signature",
-			"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard").
-		From("orders").
-		Where(sq.Eq{"order_uid": orderID}).
-		ToSql()
-
-	err := r.db.QueryRow(ctx, oq, oargs...).Scan(
-		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
-		&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard,
-	)
+	orders, err := r.queryOrders(ctx, builder)
 	if err != nil {
 		return nil, err
 	}
 
-	// Delivery
-	dq, dargs, _ := r.psql.
-		Select("name", "phone", "zip", "city", "address", "region", "email").
-		From("deliveries").
-		Where(sq.Eq{"order_uid": o.OrderUID}).
-		ToSql()
-
-	err = r.db.QueryRow(ctx, dq, dargs...).Scan(
-		&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip, &o.Delivery.City,
-		&o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email,
-	)
-	if err != nil {
-		return nil, err
+	page := &model.OrderPage{Orders: orders}
+	if page.Orders == nil {
+		page.Orders = []model.OrderInfo{}
 	}
-
-	// Payment
-	pq, pargs, _ := r.psql.
-		Select("transaction", "request_id", "currency", "provider", "amount",
-			"payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee").
-		From("payments").
-		Where(sq.Eq{"order_uid": o.OrderUID}).
-		ToSql()
-
-	err = r.db.QueryRow(ctx, pq, pargs...).Scan(
-		&o.Payment.Transaction, &o.Payment.RequestID, &o.Payment.Currency,
-		&o.Payment.Provider, &o.Payment.Amount, &o.Payment.PaymentDT,
-		&o.Payment.Bank, &o.Payment.DeliveryCost, &o.Payment.GoodsTotal, &o.Payment.CustomFee,
-	)
-	if err != nil {
-		return nil, err
+	if len(orders) > limit {
+		page.Orders = orders[:limit]
+		last := page.Orders[limit-1]
+		page.NextCursor = encodeCursor(listCursor{DateCreated: last.DateCreated, OrderUID: last.OrderUID})
 	}
 
-	// Items
-	iq, iargs, _ := r.psql.
-		Select("chrt_id", "track_number", "price", "rid", "name",
-			"sale", "size", "total_price", "nm_id", "brand", "status").
+	return page, nil
+}
+
+// GetOrdersByTrack заказы, у которых track_number заказа или одного из товаров совпадает с trackNumber
+func (r *OrderRepo) GetOrdersByTrack(ctx context.Context, trackNumber string) ([]model.OrderInfo, error) {
+	// вложенный запрос собираем без Dollar-плейсхолдеров, их пронумерует внешний builder
+	itemsQuery := sq.
+		Select("order_uid").
 		From("items").
-		Where(sq.Eq{"order_uid": o.OrderUID}).
-		ToSql()
+		Where(sq.Eq{"track_number": trackNumber})
 
-	itemRows, err := r.db.Query(ctx, iq, iargs...)
+	builder := r.selectOrders().
+		Where(sq.Or{
+			sq.Eq{"o.track_number": trackNumber},
+			sq.Expr("o.order_uid IN (?)", itemsQuery),
+		}).
+		OrderBy("o.date_created DESC")
+
+	return r.queryOrders(ctx, builder)
+}
+
+// encodeCursor кодирует позицию в непрозрачную строку
+func encodeCursor(c listCursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// decodeCursor разбирает курсор, полученный от клиента
+func decodeCursor(s string) (listCursor, error) {
+	var c listCursor
+	raw, err := base64.RawURLEncoding.DecodeString(s)
 	if err != nil {
-		return nil, err
+		return c, ErrInvalidCursor
 	}
-
-	for itemRows.Next() {
-		var it model.Item
-		if err := itemRows.Scan(
-			&it.ChrtID, &it.TrackNumber, &it.Price, &it.RID, &it.Name,
-			&it.Sale, &it.Size, &it.TotalPrice, &it.NmID, &it.Brand, &it.Status,
-		); err != nil {
-			itemRows.Close()
-			return nil, err
-		}
-		o.Items = append(o.Items, it)
+	if err := json.Unmarshal(raw, &c); err != nil || c.OrderUID == "" || c.DateCreated.IsZero() {
+		return c, ErrInvalidCursor
 	}
-	itemRows.Close()
-
-	return &o, nil
+	return c, nil
 }
@@ -0,0 +1,161 @@
+package order
+
+import (
+	"context"
+	"order-back-end/internal/model"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+)
+
+// orderColumns колонки заказа вместе с доставкой и оплатой, порядок совпадает со scanOrder
+var orderColumns = []string{
+	"o.order_uid", "o.track_number", "o.entry", "o.locale", "o.internal_signature",
+	"o.customer_id", "o.delivery_service", "o.shardkey", "o.sm_id", "o.date_created", "o.oof_shard",
+	"d.name", "d.phone", "d.zip", "d.city", "d.address", "d.region", "d.email",
+	"p.transaction", "p.request_id", "p.currency", "p.provider", "p.amount",
+	"p.payment_dt", "p.bank", "p.delivery_cost", "p.goods_total", "p.custom_fee",
+}
+
+// itemColumns колонки товара, порядок совпадает со scanItem и itemValues
+var itemColumns = []string{
+	"order_uid", "chrt_id", "track_number", "price", "rid", "name",
+	"sale", "size", "total_price", "nm_id", "brand", "status",
+}
+
+// selectOrders заказы с доставкой и оплатой одним запросом, товары догружаются отдельно через loadItems.
+// Заказ без строки deliveries или payments тоже попадает в выборку, его колонки доставки и оплаты - NULL
+func (r *OrderRepo) selectOrders() sq.SelectBuilder {
+	return r.psql.
+		Select(orderColumns...).
+		From("orders o").
+		LeftJoin("deliveries d ON d.order_uid = o.order_uid").
+		LeftJoin("payments p ON p.order_uid = o.order_uid")
+}
+
+// scanOrder читает строку selectOrders в model.OrderInfo; NULL в колонках доставки и оплаты
+// оставляет поля пустыми
+func scanOrder(row pgx.Row, o *model.OrderInfo) error {
+	var null nullFields
+	err := row.Scan(
+		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
+		&o.CustomerID, &o.DeliveryService, &o.ShardKey, &o.SmID, &o.DateCreated, &o.OofShard,
+		nullable(&null, &o.Delivery.Name), nullable(&null, &o.Delivery.Phone), nullable(&null, &o.Delivery.Zip),
+		nullable(&null, &o.Delivery.City), nullable(&null, &o.Delivery.Address), nullable(&null, &o.Delivery.Region),
+		nullable(&null, &o.Delivery.Email),
+		nullable(&null, &o.Payment.Transaction), nullable(&null, &o.Payment.RequestID),
+		nullable(&null, &o.Payment.Currency), nullable(&null, &o.Payment.Provider), nullable(&null, &o.Payment.Amount),
+		nullable(&null, &o.Payment.PaymentDT), nullable(&null, &o.Payment.Bank),
+		nullable(&null, &o.Payment.DeliveryCost), nullable(&null, &o.Payment.GoodsTotal),
+		nullable(&null, &o.Payment.CustomFee),
+	)
+	if err != nil {
+		return err
+	}
+	null.fill()
+	return nil
+}
+
+// nullFields переносит прочитанные значения nullable-колонок в поля после Scan
+type nullFields []func()
+
+// nullable приёмник для Scan, который принимает NULL; значение попадает в dst при fill
+func nullable[T any](n *nullFields, dst *T) any {
+	var v *T
+	*n = append(*n, func() {
+		if v != nil {
+			*dst = *v
+		}
+	})
+	return &v
+}
+
+func (n nullFields) fill() {
+	for _, set := range n {
+		set()
+	}
+}
+
+// scanItem читает строку items, возвращает order_uid, к которому относится товар
+func scanItem(row pgx.Row, it *model.Item) (string, error) {
+	var orderUID string
+	err := row.Scan(
+		&orderUID, &it.ChrtID, &it.TrackNumber, &it.Price, &it.RID, &it.Name,
+		&it.Sale, &it.Size, &it.TotalPrice, &it.NmID, &it.Brand, &it.Status,
+	)
+	return orderUID, err
+}
+
+// queryOrders выполняет запрос заказов и догружает их товары, всего два запроса в базу
+func (r *OrderRepo) queryOrders(ctx context.Context, builder sq.SelectBuilder) ([]model.OrderInfo, error) {
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var orders []model.OrderInfo
+	for rows.Next() {
+		var o model.OrderInfo
+		if err := scanOrder(rows, &o); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadItems(ctx, orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
+
+// loadItems одним запросом загружает товары всех переданных заказов
+func (r *OrderRepo) loadItems(ctx context.Context, orders []model.OrderInfo) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(orders))
+	index := make(map[string]int, len(orders))
+	for i, o := range orders {
+		ids[i] = o.OrderUID
+		index[o.OrderUID] = i
+	}
+
+	query, args, err := r.psql.
+		Select(itemColumns...).
+		From("items").
+		Where("order_uid = ANY(?)", ids).
+		OrderBy("id").
+		ToSql()
+	if err != nil {
+		return err
+	}
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var it model.Item
+		orderUID, err := scanItem(rows, &it)
+		if err != nil {
+			return err
+		}
+		if i, ok := index[orderUID]; ok {
+			orders[i].Items = append(orders[i].Items, it)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,66 @@
+package order
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"order-back-end/internal/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+// valuesRow строка результата запроса; nil - NULL
+type valuesRow []any
+
+func (r valuesRow) Scan(dest ...any) error {
+	for i, d := range dest {
+		target := reflect.ValueOf(d).Elem()
+		switch {
+		case r[i] == nil:
+			target.SetZero()
+		case target.Kind() == reflect.Pointer:
+			v := reflect.New(target.Type().Elem())
+			v.Elem().Set(reflect.ValueOf(r[i]))
+			target.Set(v)
+		default:
+			target.Set(reflect.ValueOf(r[i]))
+		}
+	}
+	return nil
+}
+
+func TestSelectOrdersKeepsOrdersWithoutDetails(t *testing.T) {
+	sqlStr, _, err := NewRepository(&fakePool{}).selectOrders().ToSql()
+	require.NoError(t, err)
+	require.Contains(t, sqlStr, "LEFT JOIN deliveries d ON d.order_uid = o.order_uid")
+	require.Contains(t, sqlStr, "LEFT JOIN payments p ON p.order_uid = o.order_uid")
+	require.NotContains(t, strings.ReplaceAll(sqlStr, "LEFT JOIN", ""), "JOIN")
+}
+
+func TestScanOrderNullDetails(t *testing.T) {
+	created := time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC)
+	row := valuesRow{"o1", "TRACK", "WBIL", "en", "", "test", "meest", "9", 99, created, "1"}
+
+	// у заказа нет ни доставки, ни оплаты
+	null := slices.Concat(row, make(valuesRow, len(orderColumns)-len(row)))
+	var o model.OrderInfo
+	require.NoError(t, scanOrder(null, &o))
+	require.Equal(t, "o1", o.OrderUID)
+	require.Equal(t, created, o.DateCreated)
+	require.Zero(t, o.Delivery)
+	require.Zero(t, o.Payment)
+
+	full := slices.Concat(row, valuesRow{
+		"Test", "+79001234567", "123456", "Moscow", "Lenina 1", nil, "test@test.com",
+		"tr-1", "", "RUB", "wbpay", 1500, int64(1637907727), "alpha", 500, 1000, 0,
+	})
+	o = model.OrderInfo{}
+	require.NoError(t, scanOrder(full, &o))
+	require.Equal(t, model.Delivery{Name: "Test", Phone: "+79001234567", Zip: "123456", City: "Moscow",
+		Address: "Lenina 1", Email: "test@test.com"}, o.Delivery)
+	require.Equal(t, model.Payment{Transaction: "tr-1", Currency: "RUB", Provider: "wbpay", Amount: 1500,
+		PaymentDT: 1637907727, Bank: "alpha", DeliveryCost: 500, GoodsTotal: 1000}, o.Payment)
+}
@@ -0,0 +1,335 @@
+package order
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"order-back-end/internal/model"
+	"slices"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var (
+	// ErrOrderExists заказ с таким order_uid уже сохранён
+	ErrOrderExists = errors.New("order already exists")
+	// ErrTransactionExists payment.transaction уже принадлежит другому заказу
+	ErrTransactionExists = errors.New("payment transaction belongs to another order")
+)
+
+// uniqueViolation код ошибки PostgreSQL при нарушении уникальности
+const uniqueViolation = "23505"
+
+// WithTx выполняет fn в одной транзакции: все вызовы repo внутри fn видят и пишут одни и те же данные,
+// при ошибке fn изменения откатываются. Вызов внутри транзакции выполняет fn в ней же
+func (r *OrderRepo) WithTx(ctx context.Context, fn func(ctx context.Context, repo Repo) error) error {
+	return r.inTx(ctx, func(tx *OrderRepo) error {
+		return fn(ctx, tx)
+	})
+}
+
+// inTx выполняет fn с репозиторием поверх транзакции
+func (r *OrderRepo) inTx(ctx context.Context, fn func(tx *OrderRepo) error) error {
+	if r.pool == nil {
+		return fn(r)
+	}
+
+	tx, err := r.pool.BeginTx(ctx, pgx.TxOptions{
+		IsoLevel:   pgx.ReadCommitted,
+		AccessMode: pgx.ReadWrite,
+	})
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if err := fn(&OrderRepo{db: tx, psql: r.psql}); err != nil {
+		return err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	return nil
+}
+
+// SaveOrder записывает новый заказ; если заказ с таким order_uid уже есть, возвращает ErrOrderExists
+func (r *OrderRepo) SaveOrder(ctx context.Context, order model.OrderInfo) error {
+	hash, err := ContentHash(order)
+	if err != nil {
+		return fmt.Errorf("content hash failed: %w", err)
+	}
+	return r.inTx(ctx, func(tx *OrderRepo) error {
+		sqlStr, args, _ := tx.insertOrderQuery(order, hash).ToSql()
+		if _, err := tx.db.Exec(ctx, sqlStr, args...); err != nil {
+			var pgErr *pgconn.PgError
+			if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.TableName == "orders" {
+				return fmt.Errorf("%w: %s", ErrOrderExists, order.OrderUID)
+			}
+			return fmt.Errorf("insert order failed: %w", err)
+		}
+		return tx.writeDetails(ctx, order)
+	})
+}
+
+// UpsertOrder записывает заказ; заказ с тем же order_uid заменяется целиком, повтор с тем же содержимым
+// и версия старше сохранённой (по date_created) ничего не пишут. false означает, что заказ не записан:
+// он уже сохранён в таком или более новом виде. Если payment.transaction записан за другим заказом -
+// ErrTransactionExists, повтор его не исправит
+func (r *OrderRepo) UpsertOrder(ctx context.Context, order model.OrderInfo) (bool, error) {
+	hash, err := ContentHash(order)
+	if err != nil {
+		return false, fmt.Errorf("content hash failed: %w", err)
+	}
+
+	var changed bool
+	err = r.inTx(ctx, func(tx *OrderRepo) error {
+		sqlStr, args, _ := tx.upsertOrderQuery(order, hash).ToSql()
+		var uid string
+		err := tx.db.QueryRow(ctx, sqlStr, args...).Scan(&uid)
+		if errors.Is(err, pgx.ErrNoRows) {
+			// конфликт есть, но WHERE отсёк обновление: такой или более новый заказ уже сохранён
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("upsert order failed: %w", err)
+		}
+		changed = true
+		return tx.writeDetails(ctx, order)
+	})
+	if err != nil {
+		return false, err
+	}
+	return changed, nil
+}
+
+// UpsertOrders записывает заказы одной транзакцией за три обращения к базе: upsert'ы orders пачкой,
+// затем deliveries, payments и удаление старых items пачкой для изменившихся заказов, затем items через COPY.
+// Возвращает, какие заказы изменились; повтор с тем же содержимым и старая версия заказа ничего не пишут
+func (r *OrderRepo) UpsertOrders(ctx context.Context, orders map[string]model.OrderInfo) (map[string]bool, error) {
+	changed := make(map[string]bool, len(orders))
+	if len(orders) == 0 {
+		return changed, nil
+	}
+
+	// одинаковый порядок блокировок строк у всех консьюмеров, чтобы пачки не ловили deadlock друг с другом
+	uids := slices.Sorted(maps.Keys(orders))
+	upserts := &pgx.Batch{}
+	for _, uid := range uids {
+		hash, err := ContentHash(orders[uid])
+		if err != nil {
+			return nil, fmt.Errorf("content hash failed: %w", err)
+		}
+		queue(upserts, r.upsertOrderQuery(orders[uid], hash))
+	}
+
+	err := r.inTx(ctx, func(tx *OrderRepo) error {
+		if err := sendUpserts(ctx, tx.db, upserts, uids, changed); err != nil {
+			return fmt.Errorf("upsert orders failed: %w", err)
+		}
+
+		details := &pgx.Batch{}
+		var items [][]any
+		for _, uid := range uids {
+			if !changed[uid] {
+				continue
+			}
+			order := orders[uid]
+			queue(details, tx.upsertDeliveryQuery(order))
+			queue(details, tx.upsertPaymentQuery(order))
+			queue(details, tx.deleteItemsQuery(uid))
+			for _, item := range order.Items {
+				items = append(items, itemValues(uid, item))
+			}
+		}
+		if details.Len() > 0 {
+			if err := tx.db.SendBatch(ctx, details).Close(); err != nil {
+				return fmt.Errorf("upsert order details failed: %w", paymentError(err, ""))
+			}
+		}
+		if len(items) > 0 {
+			if _, err := tx.db.CopyFrom(ctx, pgx.Identifier{"items"}, itemColumns, pgx.CopyFromRows(items)); err != nil {
+				return fmt.Errorf("copy items failed: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// DeleteOrder удаляет заказ вместе с доставкой, оплатой и товарами; pgx.ErrNoRows, если заказа нет
+func (r *OrderRepo) DeleteOrder(ctx context.Context, orderID string) error {
+	// deliveries, payments и items удаляются каскадно
+	sqlStr, args, _ := r.psql.Delete("orders").Where(sq.Eq{"order_uid": orderID}).ToSql()
+	tag, err := r.db.Exec(ctx, sqlStr, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return pgx.ErrNoRows
+	}
+	return nil
+}
+
+// paymentError ErrTransactionExists, если transaction оплаты уже записан за другим заказом:
+// оплата заказа обновляется по order_uid, а первичный ключ payments - transaction
+func paymentError(err error, transaction string) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "payments_pkey" {
+		if transaction == "" {
+			return fmt.Errorf("%w: %w", ErrTransactionExists, err)
+		}
+		return fmt.Errorf("%w: %s: %w", ErrTransactionExists, transaction, err)
+	}
+	return err
+}
+
+// writeDetails записывает delivery, payment и заменяет items только что записанного заказа
+func (r *OrderRepo) writeDetails(ctx context.Context, order model.OrderInfo) error {
+	sqlStr, args, _ := r.upsertDeliveryQuery(order).ToSql()
+	if _, err := r.db.Exec(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("upsert delivery failed: %w", err)
+	}
+	sqlStr, args, _ = r.upsertPaymentQuery(order).ToSql()
+	if _, err := r.db.Exec(ctx, sqlStr, args...); err != nil {
+		return fmt.Errorf("upsert payment failed: %w", paymentError(err, order.Payment.Transaction))
+	}
+	if err := r.replaceItems(ctx, order); err != nil {
+		return fmt.Errorf("replace items failed: %w", err)
+	}
+	return nil
+}
+
+// replaceItems заменяем items заказа новым набором в той же транзакции
+func (r *OrderRepo) replaceItems(ctx context.Context, order model.OrderInfo) error {
+	sqlStr, args, _ := r.deleteItemsQuery(order.OrderUID).ToSql()
+	if _, err := r.db.Exec(ctx, sqlStr, args...); err != nil {
+		return err
+	}
+	if len(order.Items) == 0 {
+		return nil
+	}
+
+	itemBuilder := r.psql.Insert("items").Columns(itemColumns...)
+	for _, item := range order.Items {
+		itemBuilder = itemBuilder.Values(itemValues(order.OrderUID, item)...)
+	}
+
+	sqlStr, args, _ = itemBuilder.ToSql()
+	_, err := r.db.Exec(ctx, sqlStr, args...)
+	return err
+}
+
+// ContentHash sha256 от JSON заказа: одинаковые по содержимому заказы дают одинаковый хэш
+// независимо от форматирования исходного JSON
+func ContentHash(order model.OrderInfo) (string, error) {
+	data, err := json.Marshal(order)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// onConflictUpdate суффикс upsert'а, перезаписывающий columns значениями из вставляемой строки
+func onConflictUpdate(conflict string, columns ...string) string {
+	set := make([]string, len(columns))
+	for i, col := range columns {
+		set[i] = col + " = EXCLUDED." + col
+	}
+	return "ON CONFLICT (" + conflict + ") DO UPDATE SET " + strings.Join(set, ", ")
+}
+
+// orderRowColumns колонки таблицы orders в порядке значений orderValues
+var orderRowColumns = []string{"order_uid", "track_number", "entry", "locale", "internal_signature",
+	"customer_id", "delivery_service", "shardkey", "sm_id", "date_created", "oof_shard", "content_hash"}
+
+// orderValues значения строки orders для колонок orderRowColumns. date_created - TIMESTAMP без часового
+// пояса, pgx отбрасывает смещение, поэтому время записывается в UTC
+func orderValues(order model.OrderInfo, hash string) []any {
+	return []any{order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+		order.CustomerID, order.DeliveryService, order.ShardKey, order.SmID, order.DateCreated.UTC(), order.OofShard, hash}
+}
+
+// itemValues значения строки items для колонок itemColumns
+func itemValues(orderUID string, item model.Item) []any {
+	return []any{orderUID, item.ChrtID, item.TrackNumber, item.Price, item.RID, item.Name,
+		item.Sale, item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status}
+}
+
+// insertOrderQuery вставка нового order
+func (r *OrderRepo) insertOrderQuery(order model.OrderInfo, hash string) sq.InsertBuilder {
+	return r.psql.Insert("orders").Columns(orderRowColumns...).Values(orderValues(order, hash)...)
+}
+
+// upsertOrderQuery upsert order, который не трогает строку, если хэш содержимого не изменился или
+// сохранённый заказ новее: запоздавшая повторная доставка старой версии не затирает новую.
+// Возвращает order_uid только для вставленной или обновлённой строки
+func (r *OrderRepo) upsertOrderQuery(order model.OrderInfo, hash string) sq.InsertBuilder {
+	return r.insertOrderQuery(order, hash).
+		Suffix(onConflictUpdate("order_uid", orderRowColumns[1:]...) +
+			" WHERE orders.content_hash IS DISTINCT FROM EXCLUDED.content_hash" +
+			" AND EXCLUDED.date_created >= orders.date_created RETURNING order_uid")
+}
+
+// upsertDeliveryQuery upsert delivery
+func (r *OrderRepo) upsertDeliveryQuery(order model.OrderInfo) sq.InsertBuilder {
+	return r.psql.Insert("deliveries").
+		Columns("order_uid", "name", "phone", "zip", "city", "address", "region", "email").
+		Values(order.OrderUID, order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip,
+			order.Delivery.City, order.Delivery.Address, order.Delivery.Region, order.Delivery.Email).
+		Suffix(onConflictUpdate("order_uid", "name", "phone", "zip", "city", "address", "region", "email"))
+}
+
+// upsertPaymentQuery upsert payment; у заказа одна оплата
+func (r *OrderRepo) upsertPaymentQuery(order model.OrderInfo) sq.InsertBuilder {
+	return r.psql.Insert("payments").
+		Columns("transaction", "order_uid", "request_id", "currency", "provider",
+			"amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee").
+		Values(order.Payment.Transaction, order.OrderUID, order.Payment.RequestID, order.Payment.Currency,
+			order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDT, order.Payment.Bank,
+			order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee).
+		Suffix(onConflictUpdate("order_uid", "transaction", "request_id", "currency", "provider",
+			"amount", "payment_dt", "bank", "delivery_cost", "goods_total", "custom_fee"))
+}
+
+// deleteItemsQuery удаление items заказа перед записью нового набора
+func (r *OrderRepo) deleteItemsQuery(orderUID string) sq.DeleteBuilder {
+	return r.psql.Delete("items").Where(sq.Eq{"order_uid": orderUID})
+}
+
+// sendUpserts отправляет upsert'ы orders и отмечает в changed заказы, строки которых изменились
+func sendUpserts(ctx context.Context, db Querier, upserts *pgx.Batch, uids []string, changed map[string]bool) (err error) {
+	results := db.SendBatch(ctx, upserts)
+	defer func() {
+		err = errors.Join(err, results.Close())
+	}()
+
+	for _, uid := range uids {
+		var returned string
+		err := results.QueryRow().Scan(&returned)
+		if errors.Is(err, pgx.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		changed[uid] = true
+	}
+	return nil
+}
+
+// queue добавляет запрос squirrel в пачку pgx
+func queue(b *pgx.Batch, query sq.Sqlizer) {
+	sqlStr, args, _ := query.ToSql()
+	b.Queue(sqlStr, args...)
+}
@@ -0,0 +1,217 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"order-back-end/internal/model"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContentHash(t *testing.T) {
+	order := model.OrderInfo{
+		OrderUID:    "b563feb7b2b84b6test",
+		TrackNumber: "WBILMTESTTRACK",
+		Items:       []model.Item{{ChrtID: 9934930, Price: 453}},
+	}
+
+	first, err := ContentHash(order)
+	require.NoError(t, err)
+	again, err := ContentHash(order)
+	require.NoError(t, err)
+	require.Equal(t, first, again)
+	require.Len(t, first, 64, "hash must fit orders.content_hash CHAR(64)")
+
+	order.Items[0].Price = 454
+	changed, err := ContentHash(order)
+	require.NoError(t, err)
+	require.NotEqual(t, first, changed)
+}
+
+func TestOnConflictUpdate(t *testing.T) {
+	require.Equal(t,
+		"ON CONFLICT (order_uid) DO UPDATE SET name = EXCLUDED.name, phone = EXCLUDED.phone",
+		onConflictUpdate("order_uid", "name", "phone"))
+}
+
+// fakePool открывает fakeTx и запоминает, чем закончилась каждая транзакция
+type fakePool struct {
+	Querier
+
+	execErr  error // ошибка запросов, начинающихся с execFail
+	execFail string
+	affected int64 // число строк, затронутых каждым запросом
+	queryErr error // ошибка QueryRow; pgx.ErrNoRows - upsert ничего не записал
+
+	begun, committed, rolledBack int
+	statements                   []string
+}
+
+func (p *fakePool) BeginTx(context.Context, pgx.TxOptions) (pgx.Tx, error) {
+	p.begun++
+	return &fakeTx{pool: p}, nil
+}
+
+func (p *fakePool) Exec(_ context.Context, sql string, _ ...any) (pgconn.CommandTag, error) {
+	p.statements = append(p.statements, sql)
+	if p.execFail != "" && strings.HasPrefix(sql, p.execFail) {
+		return pgconn.CommandTag{}, p.execErr
+	}
+	return pgconn.NewCommandTag("DELETE " + strconv.FormatInt(p.affected, 10)), nil
+}
+
+type fakeTx struct {
+	pgx.Tx
+	pool *fakePool
+	done bool
+}
+
+func (tx *fakeTx) Exec(ctx context.Context, sql string, args ...any) (pgconn.CommandTag, error) {
+	return tx.pool.Exec(ctx, sql, args...)
+}
+
+func (tx *fakeTx) QueryRow(_ context.Context, sql string, _ ...any) pgx.Row {
+	tx.pool.statements = append(tx.pool.statements, sql)
+	return fakeRow{err: tx.pool.queryErr}
+}
+
+type fakeRow struct {
+	err error
+}
+
+func (r fakeRow) Scan(dest ...any) error {
+	if r.err != nil {
+		return r.err
+	}
+	*dest[0].(*string) = "o1"
+	return nil
+}
+
+func (tx *fakeTx) Commit(context.Context) error {
+	tx.done = true
+	tx.pool.committed++
+	return nil
+}
+
+func (tx *fakeTx) Rollback(context.Context) error {
+	if !tx.done {
+		tx.done = true
+		tx.pool.rolledBack++
+	}
+	return nil
+}
+
+func TestWithTx(t *testing.T) {
+	pool := &fakePool{}
+	r := NewRepository(pool)
+	ctx := context.Background()
+
+	// вложенные вызовы выполняются в той же транзакции
+	err := r.WithTx(ctx, func(ctx context.Context, repo Repo) error {
+		require.NoError(t, repo.SaveOrder(ctx, model.OrderInfo{OrderUID: "o1", Items: []model.Item{{ChrtID: 1}}}))
+		return repo.DeleteOrder(ctx, "o2")
+	})
+	require.ErrorIs(t, err, pgx.ErrNoRows, "there is no order o2 to delete")
+	require.Equal(t, 1, pool.begun)
+	require.Equal(t, 0, pool.committed)
+	require.Equal(t, 1, pool.rolledBack)
+
+	pool.affected = 1
+	err = r.WithTx(ctx, func(ctx context.Context, repo Repo) error {
+		return repo.DeleteOrder(ctx, "o1")
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, pool.begun)
+	require.Equal(t, 1, pool.committed)
+}
+
+func TestSaveOrder(t *testing.T) {
+	pool := &fakePool{}
+	r := NewRepository(pool)
+	ctx := context.Background()
+	order := model.OrderInfo{OrderUID: "o1", Items: []model.Item{{ChrtID: 1}, {ChrtID: 2}}}
+
+	require.NoError(t, r.SaveOrder(ctx, order))
+	require.Equal(t, 1, pool.committed)
+	require.Len(t, pool.statements, 5)
+	require.True(t, strings.HasPrefix(pool.statements[0], "INSERT INTO orders"))
+	require.NotContains(t, pool.statements[0], "ON CONFLICT", "SaveOrder must not overwrite an existing order")
+	require.True(t, strings.HasPrefix(pool.statements[4], "INSERT INTO items"))
+
+	pool.execFail, pool.execErr = "INSERT INTO orders", &pgconn.PgError{Code: uniqueViolation, TableName: "orders"}
+	require.ErrorIs(t, r.SaveOrder(ctx, order), ErrOrderExists)
+
+	// transaction уже записан за другим заказом
+	pool.execFail, pool.execErr = "INSERT INTO payments", &pgconn.PgError{Code: uniqueViolation, TableName: "payments", ConstraintName: "payments_pkey"}
+	err := r.SaveOrder(ctx, order)
+	require.ErrorIs(t, err, ErrTransactionExists)
+	require.NotErrorIs(t, err, ErrOrderExists)
+
+	pool.execFail, pool.execErr = "INSERT INTO items", errors.New("boom")
+	err = r.SaveOrder(ctx, order)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrOrderExists)
+	require.Equal(t, 3, pool.rolledBack)
+}
+
+func TestUpsertOrderQuery(t *testing.T) {
+	r := NewRepository(&fakePool{})
+	sqlStr, _, err := r.upsertOrderQuery(model.OrderInfo{OrderUID: "o1"}, "hash").ToSql()
+	require.NoError(t, err)
+	require.True(t, strings.HasSuffix(sqlStr, " WHERE orders.content_hash IS DISTINCT FROM EXCLUDED.content_hash"+
+		" AND EXCLUDED.date_created >= orders.date_created RETURNING order_uid"),
+		"an older version of the order must not overwrite a newer one")
+}
+
+func TestUpsertOrder(t *testing.T) {
+	pool := &fakePool{}
+	r := NewRepository(pool)
+	ctx := context.Background()
+	order := model.OrderInfo{OrderUID: "o1", Payment: model.Payment{Transaction: "tr-1"}, Items: []model.Item{{ChrtID: 1}}}
+
+	changed, err := r.UpsertOrder(ctx, order)
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Len(t, pool.statements, 5)
+
+	// тот же или более новый заказ уже сохранён: delivery, payment и items не трогаем
+	pool.statements, pool.queryErr = nil, pgx.ErrNoRows
+	changed, err = r.UpsertOrder(ctx, order)
+	require.NoError(t, err)
+	require.False(t, changed)
+	require.Len(t, pool.statements, 1)
+	require.Equal(t, 2, pool.committed)
+
+	// transaction уже записан за другим заказом: ошибка постоянная, а не временная
+	pool.queryErr = nil
+	pool.execFail, pool.execErr = "INSERT INTO payments", &pgconn.PgError{Code: uniqueViolation, TableName: "payments", ConstraintName: "payments_pkey"}
+	_, err = r.UpsertOrder(ctx, order)
+	require.ErrorIs(t, err, ErrTransactionExists)
+	require.ErrorContains(t, err, "tr-1")
+	require.Equal(t, 1, pool.rolledBack)
+}
+
+func TestDeleteOrder(t *testing.T) {
+	pool := &fakePool{}
+	r := NewRepository(pool)
+
+	require.ErrorIs(t, r.DeleteOrder(context.Background(), "missing"), pgx.ErrNoRows)
+	pool.affected = 1
+	require.NoError(t, r.DeleteOrder(context.Background(), "o1"))
+	require.Equal(t, []string{"DELETE FROM orders WHERE order_uid = $1", "DELETE FROM orders WHERE order_uid = $1"}, pool.statements)
+	require.Zero(t, pool.begun, "a single statement needs no explicit transaction")
+}
+
+func TestOrderValuesStoreUTC(t *testing.T) {
+	msk := time.FixedZone("MSK", 3*60*60)
+	values := orderValues(model.OrderInfo{DateCreated: time.Date(2021, 11, 26, 9, 0, 0, 0, msk)}, "hash")
+	require.Equal(t, time.Date(2021, 11, 26, 6, 0, 0, 0, time.UTC), values[slices.Index(orderRowColumns, "date_created")])
+}
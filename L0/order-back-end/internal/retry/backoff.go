@@ -0,0 +1,46 @@
+package retry
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff экспоненциальная задержка между попытками с полным джиттером
+type Backoff struct {
+	MaxAttempts int           `yaml:"max_attempts" env-default:"5"`
+	BaseDelay   time.Duration `yaml:"base_delay" env-default:"200ms"`
+	MaxDelay    time.Duration `yaml:"max_delay" env-default:"30s"`
+}
+
+// Delay задержка перед попыткой attempt (с нуля): случайное значение в [0, min(MaxDelay, BaseDelay*2^attempt)].
+// Джиттер разводит во времени повторы разных консьюмеров, чтобы они не били в базу одновременно
+func (b Backoff) Delay(attempt int) time.Duration {
+	ceiling := b.Ceiling(attempt)
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+// Ceiling верхняя граница задержки перед попыткой attempt без джиттера
+func (b Backoff) Ceiling(attempt int) time.Duration {
+	if b.BaseDelay <= 0 {
+		return 0
+	}
+	ceiling := b.BaseDelay
+	for i := 0; i < attempt; i++ {
+		ceiling *= 2
+		if b.MaxDelay > 0 && ceiling >= b.MaxDelay {
+			return b.MaxDelay
+		}
+	}
+	if b.MaxDelay > 0 && ceiling > b.MaxDelay {
+		return b.MaxDelay
+	}
+	return ceiling
+}
+
+// Exhausted true, если попытка attempt (с нуля) была последней разрешённой
+func (b Backoff) Exhausted(attempt int) bool {
+	return b.MaxAttempts > 0 && attempt+1 >= b.MaxAttempts
+}
@@ -0,0 +1,39 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffCeiling(t *testing.T) {
+	b := Backoff{MaxAttempts: 5, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	require.Equal(t, 100*time.Millisecond, b.Ceiling(0))
+	require.Equal(t, 200*time.Millisecond, b.Ceiling(1))
+	require.Equal(t, 800*time.Millisecond, b.Ceiling(3))
+	require.Equal(t, time.Second, b.Ceiling(4))
+	require.Equal(t, time.Second, b.Ceiling(100), "ceiling must not overflow")
+}
+
+func TestBackoffDelayJitter(t *testing.T) {
+	b := Backoff{BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		for i := 0; i < 100; i++ {
+			d := b.Delay(attempt)
+			require.GreaterOrEqual(t, d, time.Duration(0))
+			require.LessOrEqual(t, d, b.Ceiling(attempt))
+		}
+	}
+}
+
+func TestBackoffExhausted(t *testing.T) {
+	b := Backoff{MaxAttempts: 3}
+
+	require.False(t, b.Exhausted(0))
+	require.False(t, b.Exhausted(1))
+	require.True(t, b.Exhausted(2))
+	require.False(t, Backoff{}.Exhausted(100), "zero MaxAttempts means unlimited")
+}
@@ -0,0 +1,166 @@
+package schemaregistry
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Типы схем в ответах Confluent Schema Registry; пустой тип означает AVRO
+const (
+	TypeAvro     = "AVRO"
+	TypeProtobuf = "PROTOBUF"
+	TypeJSON     = "JSON"
+)
+
+// magicByte первый байт сообщения в Confluent wire format
+const magicByte = 0
+
+// headerSize magic byte и 4 байта идентификатора схемы
+const headerSize = 5
+
+var (
+	// ErrNotFound схемы с таким идентификатором нет в реестре
+	ErrNotFound = errors.New("schema not found")
+	// ErrUnavailable реестр не ответил; сообщение имеет смысл обработать позже
+	ErrUnavailable = errors.New("schema registry unavailable")
+	// ErrNotWireFormat сообщение не в Confluent wire format
+	ErrNotWireFormat = errors.New("message is not in schema registry wire format")
+)
+
+// Config настройки клиента реестра схем
+type Config struct {
+	// URL адрес реестра, пустой - реестр не используется
+	URL     string        `yaml:"url" env:"SCHEMA_REGISTRY_URL"`
+	Timeout time.Duration `yaml:"timeout" env-default:"5s"`
+}
+
+// Schema схема из реестра
+type Schema struct {
+	ID     int    `json:"id,omitempty"`
+	Type   string `json:"schemaType,omitempty"`
+	Schema string `json:"schema"`
+}
+
+// Client клиент REST API Confluent Schema Registry. Схемы по идентификатору неизменяемы,
+// поэтому однажды полученная схема кэшируется навсегда
+type Client struct {
+	baseURL string
+	http    *http.Client
+
+	mu      sync.RWMutex
+	schemas map[int]*Schema
+}
+
+// NewClient создаёт клиента реестра по адресу cfg.URL
+func NewClient(cfg Config) *Client {
+	return &Client{
+		baseURL: strings.TrimRight(cfg.URL, "/"),
+		http:    &http.Client{Timeout: cfg.Timeout},
+		schemas: make(map[int]*Schema),
+	}
+}
+
+// SchemaByID возвращает схему по идентификатору из wire format
+func (c *Client) SchemaByID(ctx context.Context, id int) (*Schema, error) {
+	c.mu.RLock()
+	schema, ok := c.schemas[id]
+	c.mu.RUnlock()
+	if ok {
+		return schema, nil
+	}
+
+	var resp Schema
+	if err := c.do(ctx, http.MethodGet, "/schemas/ids/"+strconv.Itoa(id), nil, &resp); err != nil {
+		return nil, fmt.Errorf("schema %d: %w", id, err)
+	}
+	resp.ID = id
+	if resp.Type == "" {
+		resp.Type = TypeAvro
+	}
+
+	c.mu.Lock()
+	c.schemas[id] = &resp
+	c.mu.Unlock()
+	return &resp, nil
+}
+
+// Register регистрирует схему в subject и возвращает её идентификатор;
+// повторная регистрация той же схемы возвращает прежний идентификатор
+func (c *Client) Register(ctx context.Context, subject, schemaType, schema string) (int, error) {
+	req := Schema{Type: schemaType, Schema: schema}
+	var resp struct {
+		ID int `json:"id"`
+	}
+	if err := c.do(ctx, http.MethodPost, "/subjects/"+url.PathEscape(subject)+"/versions", req, &resp); err != nil {
+		return 0, fmt.Errorf("register schema in %s: %w", subject, err)
+	}
+
+	c.mu.Lock()
+	c.schemas[resp.ID] = &Schema{ID: resp.ID, Type: schemaType, Schema: schema}
+	c.mu.Unlock()
+	return resp.ID, nil
+}
+
+// do выполняет запрос к реестру; сетевые ошибки и 5xx оборачиваются в ErrUnavailable
+func (c *Client) do(ctx context.Context, method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.schemaregistry.v1+json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return ErrNotFound
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Errorf("%w: status %d", ErrUnavailable, resp.StatusCode)
+	case resp.StatusCode >= http.StatusBadRequest:
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("schema registry: status %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// ParseWireFormat отделяет идентификатор схемы от полезной нагрузки сообщения в Confluent wire format
+func ParseWireFormat(data []byte) (int, []byte, error) {
+	if len(data) < headerSize || data[0] != magicByte {
+		return 0, nil, ErrNotWireFormat
+	}
+	return int(binary.BigEndian.Uint32(data[1:headerSize])), data[headerSize:], nil
+}
+
+// AppendWireFormat дописывает к dst заголовок wire format со схемой id и полезную нагрузку
+func AppendWireFormat(dst []byte, id int, payload []byte) []byte {
+	dst = append(dst, magicByte)
+	dst = binary.BigEndian.AppendUint32(dst, uint32(id))
+	return append(dst, payload...)
+}
@@ -0,0 +1,101 @@
+package schemaregistry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWireFormat(t *testing.T) {
+	data := AppendWireFormat(nil, 258, []byte("payload"))
+	require.Equal(t, []byte{0, 0, 0, 1, 2}, data[:5])
+
+	id, payload, err := ParseWireFormat(data)
+	require.NoError(t, err)
+	require.Equal(t, 258, id)
+	require.Equal(t, []byte("payload"), payload)
+
+	_, _, err = ParseWireFormat([]byte(`{"order_uid":"1"}`))
+	require.ErrorIs(t, err, ErrNotWireFormat)
+	_, _, err = ParseWireFormat([]byte{0, 0, 1})
+	require.ErrorIs(t, err, ErrNotWireFormat)
+}
+
+func TestClientSchemaByID(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		switch r.URL.Path {
+		case "/schemas/ids/1":
+			json.NewEncoder(w).Encode(map[string]string{"schema": `"string"`})
+		case "/schemas/ids/2":
+			json.NewEncoder(w).Encode(map[string]string{"schemaType": TypeProtobuf, "schema": "syntax = \"proto3\";"})
+		case "/schemas/ids/3":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"error_code":40403,"message":"Schema not found"}`))
+		}
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{URL: srv.URL + "/", Timeout: time.Second})
+	ctx := context.Background()
+
+	schema, err := client.SchemaByID(ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, &Schema{ID: 1, Type: TypeAvro, Schema: `"string"`}, schema)
+
+	// схема по идентификатору неизменна: второй запрос не уходит в реестр
+	_, err = client.SchemaByID(ctx, 1)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, requests.Load())
+
+	schema, err = client.SchemaByID(ctx, 2)
+	require.NoError(t, err)
+	require.Equal(t, TypeProtobuf, schema.Type)
+
+	_, err = client.SchemaByID(ctx, 3)
+	require.ErrorIs(t, err, ErrUnavailable)
+
+	_, err = client.SchemaByID(ctx, 4)
+	require.ErrorIs(t, err, ErrNotFound)
+	require.NotErrorIs(t, err, ErrUnavailable)
+}
+
+func TestClientUnreachable(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+
+	_, err := NewClient(Config{URL: srv.URL, Timeout: time.Second}).SchemaByID(context.Background(), 1)
+	require.ErrorIs(t, err, ErrUnavailable)
+}
+
+func TestClientRegister(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "/subjects/orders-value/versions", r.URL.Path)
+
+		var req Schema
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		require.Equal(t, TypeAvro, req.Type)
+		json.NewEncoder(w).Encode(map[string]int{"id": 7})
+	}))
+	defer srv.Close()
+
+	client := NewClient(Config{URL: srv.URL, Timeout: time.Second})
+	id, err := client.Register(context.Background(), "orders-value", TypeAvro, `"string"`)
+	require.NoError(t, err)
+	require.Equal(t, 7, id)
+
+	// зарегистрированная схема уже в кэше, сервер её не отдаёт
+	schema, err := client.SchemaByID(context.Background(), 7)
+	require.NoError(t, err)
+	require.Equal(t, `"string"`, schema.Schema)
+}
@@ -0,0 +1,60 @@
+package order
+
+import (
+	"errors"
+
+	"order-back-end/internal/postgres"
+	order "order-back-end/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// Доменные ошибки сервиса, по ним handler выбирает HTTP статус
+var (
+	ErrOrderNotFound      = errors.New("order not found")
+	ErrInvalidID          = errors.New("invalid order id")
+	ErrInvalidFilter      = errors.New("invalid filter")
+	ErrStorageUnavailable = errors.New("storage unavailable")
+	ErrOrderExists        = errors.New("order already exists")
+	ErrTransactionExists  = errors.New("payment transaction belongs to another order")
+	ErrConflict           = errors.New("order conflicts with stored data")
+	ErrInvalidValue       = errors.New("order value does not fit storage limits")
+)
+
+// maxIDLength совпадает с размером колонок order_uid и track_number в базе
+const maxIDLength = 64
+
+// validateID проверяет идентификатор заказа или трек-номер до похода в базу
+func validateID(id string) error {
+	if id == "" || len(id) > maxIDLength {
+		return ErrInvalidID
+	}
+	for _, r := range id {
+		if r <= ' ' || r == 0x7f {
+			return ErrInvalidID
+		}
+	}
+	return nil
+}
+
+// mapRepoError переводит ошибку репозитория в доменную, сохраняя исходную в цепочке
+func mapRepoError(err error) error {
+	switch {
+	case errors.Is(err, pgx.ErrNoRows):
+		return ErrOrderNotFound
+	case errors.Is(err, order.ErrInvalidCursor):
+		return ErrInvalidFilter
+	case errors.Is(err, order.ErrOrderExists):
+		return ErrOrderExists
+	case errors.Is(err, order.ErrTransactionExists):
+		return ErrTransactionExists
+	case postgres.IsUniqueViolation(err):
+		return ErrConflict
+	case postgres.IsInvalidValue(err):
+		return ErrInvalidValue
+	case postgres.IsTransient(err):
+		return ErrStorageUnavailable
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,176 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"order-back-end/internal/cache"
+	"order-back-end/internal/logger"
+	"order-back-end/internal/model"
+	order "order-back-end/internal/repository"
+	"order-back-end/internal/validator"
+	"slices"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+// Статусы принятого заказа
+const (
+	IngestCreated   = "created"   // заказ записан или заменил прежнюю версию
+	IngestUnchanged = "unchanged" // при замене: такой заказ уже сохранён, ничего не записано
+)
+
+// IngestResult итог приёма заказа
+type IngestResult struct {
+	OrderUID string `json:"order_uid"`
+	Status   string `json:"status"`
+	// Warnings нарушения бизнес-правил, с которыми заказ принят в мягком режиме
+	Warnings []validator.Violation `json:"warnings,omitempty"`
+}
+
+// IngestService приём заказов через HTTP: тот же валидатор и та же запись в базу, что у консьюмеров
+type IngestService struct {
+	repository order.Repo
+	rules      *validator.Validator
+	cache      cache.Cache
+}
+
+// NewIngestService создаем экземпляр IngestService
+func NewIngestService(repository order.Repo, rules *validator.Validator, cache cache.Cache) *IngestService {
+	return &IngestService{
+		repository: repository,
+		rules:      rules,
+		cache:      cache,
+	}
+}
+
+// CreateOrder валидирует заказ и записывает его. Если заказ с таким order_uid уже есть - ErrOrderExists;
+// с replace он заменяется, как из Kafka
+func (s *IngestService) CreateOrder(ctx context.Context, o *model.OrderInfo, replace bool) (IngestResult, error) {
+	warnings, err := s.rules.Validate(o)
+	if err != nil {
+		return IngestResult{}, fmt.Errorf("CreateOrder: %w", err)
+	}
+
+	changed, err := s.saveOrder(ctx, *o, replace)
+	if err != nil {
+		return IngestResult{}, storageError("CreateOrder", err)
+	}
+
+	// кэшируем только закоммиченный заказ; незаписанный может быть старше сохранённого
+	cacheResult(s.cache, o.OrderUID, *o, changed)
+	s.logWarnings(ctx, o.OrderUID, warnings)
+	return IngestResult{OrderUID: o.OrderUID, Status: ingestStatus(changed), Warnings: warnings}, nil
+}
+
+// CreateOrders валидирует все заказы и записывает их одной транзакцией: один невалидный заказ отклоняет
+// весь запрос, пути нарушений начинаются с индекса заказа - [2].payment.amount.
+// Если order_uid повторяется, записывается последняя версия. Без replace уже сохранённый заказ отклоняет
+// весь запрос с ErrOrderExists
+func (s *IngestService) CreateOrders(ctx context.Context, orders []model.OrderInfo, replace bool) ([]IngestResult, error) {
+	results := make([]IngestResult, len(orders))
+	var violations []validator.Violation
+	for i := range orders {
+		warnings, err := s.rules.Validate(&orders[i])
+		var verr *validator.ValidationError
+		if errors.As(err, &verr) {
+			prefix := "[" + strconv.Itoa(i) + "]."
+			for _, v := range verr.Violations {
+				v.Path = prefix + v.Path
+				violations = append(violations, v)
+			}
+			continue
+		}
+		results[i] = IngestResult{OrderUID: orders[i].OrderUID, Warnings: warnings}
+	}
+	if len(violations) > 0 {
+		return nil, fmt.Errorf("CreateOrders: %w", &validator.ValidationError{Violations: violations})
+	}
+
+	latest := make(map[string]model.OrderInfo, len(orders))
+	for _, o := range orders {
+		latest[o.OrderUID] = o
+	}
+	changed, err := s.saveOrders(ctx, latest, replace)
+	if err != nil {
+		return nil, storageError("CreateOrders", err)
+	}
+
+	for uid, o := range latest {
+		cacheResult(s.cache, uid, o, changed[uid])
+	}
+	for i := range results {
+		results[i].Status = ingestStatus(changed[results[i].OrderUID])
+		s.logWarnings(ctx, results[i].OrderUID, results[i].Warnings)
+	}
+	return results, nil
+}
+
+// saveOrder записывает новый заказ или, с replace, заменяет сохранённый; false - заказ уже сохранён в таком виде
+func (s *IngestService) saveOrder(ctx context.Context, o model.OrderInfo, replace bool) (bool, error) {
+	if replace {
+		return s.repository.UpsertOrder(ctx, o)
+	}
+	if err := s.repository.SaveOrder(ctx, o); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// saveOrders записывает заказы одной транзакцией; без replace все они должны быть новыми
+func (s *IngestService) saveOrders(ctx context.Context, orders map[string]model.OrderInfo, replace bool) (map[string]bool, error) {
+	if replace {
+		return s.repository.UpsertOrders(ctx, orders)
+	}
+	changed := make(map[string]bool, len(orders))
+	err := s.repository.WithTx(ctx, func(ctx context.Context, repo order.Repo) error {
+		for _, uid := range slices.Sorted(maps.Keys(orders)) {
+			if err := repo.SaveOrder(ctx, orders[uid]); err != nil {
+				return err
+			}
+			changed[uid] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+func (s *IngestService) logWarnings(ctx context.Context, orderUID string, warnings []validator.Violation) {
+	if len(warnings) == 0 {
+		return
+	}
+	logger.GetOrCreateLoggerFromCtx(ctx).Warn(ctx, "order accepted with business rule violations",
+		zap.String("order_uid", orderUID),
+		zap.Any("violations", warnings),
+	)
+}
+
+func ingestStatus(changed bool) string {
+	if changed {
+		return IngestCreated
+	}
+	return IngestUnchanged
+}
+
+// storageError ошибка записи с доменной ошибкой в цепочке, если она есть
+func storageError(op string, err error) error {
+	if domainErr := mapRepoError(err); domainErr != nil {
+		return fmt.Errorf("%s: %w: %w", op, domainErr, err)
+	}
+	return fmt.Errorf("%s: %w", op, err)
+}
+
+// cacheResult кладёт в кэш записанный заказ. Незаписанный заказ из кэша убирается: в базе может лежать
+// более новая версия, она попадёт в кэш при чтении
+func cacheResult(c cache.Cache, uid string, o model.OrderInfo, changed bool) {
+	if changed {
+		c.Set(uid, o)
+		return
+	}
+	c.Delete(uid)
+}
@@ -0,0 +1,164 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"order-back-end/internal/cache"
+	"order-back-end/internal/model"
+	repository "order-back-end/internal/repository"
+	"order-back-end/internal/repository/mocks"
+	"order-back-end/internal/validator"
+
+	"github.com/golang/mock/gomock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/require"
+)
+
+func ingestOrder(uid string) model.OrderInfo {
+	return model.OrderInfo{
+		OrderUID: uid, TrackNumber: "TRACK", Entry: "WBIL", CustomerID: "test", DeliveryService: "meest",
+		SmID: 99, DateCreated: time.Now(),
+		Delivery: model.Delivery{Name: "Test", Phone: "8 (900) 123-45-67", City: "Moscow", Address: "Lenina 1", Email: "test@test.com"},
+		Payment: model.Payment{Transaction: uid, Currency: "rub", Provider: "wbpay", Amount: 1500,
+			PaymentDT: time.Now().Unix(), DeliveryCost: 500, GoodsTotal: 1000},
+		Items: []model.Item{{ChrtID: 1, TrackNumber: "TRACK", Name: "Item", Price: 1000, TotalPrice: 1000}},
+	}
+}
+
+func newIngestService(t *testing.T) (*IngestService, *mock_order.MockRepo, cache.Cache) {
+	t.Helper()
+	rules, err := validator.New(validator.Config{DefaultRegion: "RU"})
+	require.NoError(t, err)
+	repo := mock_order.NewMockRepo(gomock.NewController(t))
+	c := cache.NewCache(time.Minute, 10)
+	return NewIngestService(repo, rules, c), repo, c
+}
+
+func TestIngestService_CreateOrder(t *testing.T) {
+	s, repo, c := newIngestService(t)
+	ctx := context.Background()
+
+	// записывается и кэшируется нормализованный заказ
+	repo.EXPECT().SaveOrder(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, o model.OrderInfo) error {
+		require.Equal(t, "+79001234567", o.Delivery.Phone)
+		return nil
+	})
+	order := ingestOrder("o1")
+	result, err := s.CreateOrder(ctx, &order, false)
+	require.NoError(t, err)
+	require.Equal(t, IngestResult{OrderUID: "o1", Status: IngestCreated}, result)
+	cached, ok := c.Get("o1")
+	require.True(t, ok)
+	require.Equal(t, "RUB", cached.Payment.Currency)
+
+	// без replace сохранённый заказ не перезаписывается
+	repo.EXPECT().SaveOrder(ctx, gomock.Any()).Return(fmt.Errorf("%w: o1", repository.ErrOrderExists))
+	order = ingestOrder("o1")
+	order.Delivery.City = "Kazan"
+	_, err = s.CreateOrder(ctx, &order, false)
+	require.ErrorIs(t, err, ErrOrderExists)
+	cached, _ = c.Get("o1")
+	require.Equal(t, "Moscow", cached.Delivery.City)
+
+	repo.EXPECT().UpsertOrder(ctx, gomock.Any()).Return(false, nil)
+	order = ingestOrder("o1")
+	result, err = s.CreateOrder(ctx, &order, true)
+	require.NoError(t, err)
+	require.Equal(t, IngestUnchanged, result.Status)
+}
+
+func TestIngestService_CreateOrderErrors(t *testing.T) {
+	s, repo, c := newIngestService(t)
+	ctx := context.Background()
+
+	// невалидный заказ не доходит до репозитория
+	order := ingestOrder("o1")
+	order.Payment.Amount = 0
+	_, err := s.CreateOrder(ctx, &order, false)
+	var verr *validator.ValidationError
+	require.ErrorAs(t, err, &verr)
+
+	repo.EXPECT().SaveOrder(ctx, gomock.Any()).Return(&pgconn.PgError{Code: "57P01"})
+	order = ingestOrder("o1")
+	_, err = s.CreateOrder(ctx, &order, false)
+	require.ErrorIs(t, err, ErrStorageUnavailable)
+	_, ok := c.Get("o1")
+	require.False(t, ok, "an order that was not saved must not be cached")
+
+	// ошибки базы из-за данных клиента не выдаются за внутренние
+	for repoErr, want := range map[error]error{
+		fmt.Errorf("%w: tr-1", repository.ErrTransactionExists):                 ErrTransactionExists,
+		&pgconn.PgError{Code: "23505", ConstraintName: "uq_payments_order_uid"}: ErrConflict,
+		&pgconn.PgError{Code: "22001"}:                                          ErrInvalidValue,
+	} {
+		repo.EXPECT().SaveOrder(ctx, gomock.Any()).Return(repoErr)
+		order = ingestOrder("o1")
+		_, err = s.CreateOrder(ctx, &order, false)
+		require.ErrorIs(t, err, want)
+	}
+}
+
+func TestIngestService_CreateOrders(t *testing.T) {
+	s, repo, _ := newIngestService(t)
+	ctx := context.Background()
+
+	// повтор order_uid в запросе записывается последней версией
+	second := ingestOrder("o1")
+	second.Delivery.City = "Kazan"
+	repo.EXPECT().UpsertOrders(ctx, gomock.Any()).DoAndReturn(func(_ context.Context, orders map[string]model.OrderInfo) (map[string]bool, error) {
+		require.Len(t, orders, 2)
+		require.Equal(t, "Kazan", orders["o1"].Delivery.City)
+		return map[string]bool{"o2": true}, nil
+	})
+	results, err := s.CreateOrders(ctx, []model.OrderInfo{ingestOrder("o1"), ingestOrder("o2"), second}, true)
+	require.NoError(t, err)
+	require.Equal(t, []IngestResult{
+		{OrderUID: "o1", Status: IngestUnchanged},
+		{OrderUID: "o2", Status: IngestCreated},
+		{OrderUID: "o1", Status: IngestUnchanged},
+	}, results)
+
+	// один невалидный заказ отклоняет весь запрос
+	bad := ingestOrder("o4")
+	bad.Items = nil
+	_, err = s.CreateOrders(ctx, []model.OrderInfo{ingestOrder("o3"), bad}, true)
+	var verr *validator.ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Contains(t, verr.Violations, validator.Violation{
+		Path: "[1].items", Rule: validator.RuleMinItems, Message: "at least one item is required",
+	})
+
+	repo.EXPECT().UpsertOrders(ctx, gomock.Any()).Return(nil, errors.New("boom"))
+	_, err = s.CreateOrders(ctx, []model.OrderInfo{ingestOrder("o5")}, true)
+	require.Error(t, err)
+	require.NotErrorIs(t, err, ErrStorageUnavailable)
+}
+
+func TestIngestService_CreateOrdersWithoutReplace(t *testing.T) {
+	s, repo, c := newIngestService(t)
+	ctx := context.Background()
+
+	// новые заказы записываются в одной транзакции
+	repo.EXPECT().WithTx(ctx, gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(context.Context, repository.Repo) error) error {
+		return fn(ctx, repo)
+	}).Times(2)
+	repo.EXPECT().SaveOrder(ctx, gomock.Any()).Return(nil).Times(2)
+	results, err := s.CreateOrders(ctx, []model.OrderInfo{ingestOrder("o1"), ingestOrder("o2")}, false)
+	require.NoError(t, err)
+	require.Equal(t, []IngestResult{
+		{OrderUID: "o1", Status: IngestCreated},
+		{OrderUID: "o2", Status: IngestCreated},
+	}, results)
+
+	// сохранённый заказ отклоняет весь запрос, в кэш ничего не попадает
+	repo.EXPECT().SaveOrder(ctx, gomock.Any()).Return(nil)
+	repo.EXPECT().SaveOrder(ctx, gomock.Any()).Return(fmt.Errorf("%w: o4", repository.ErrOrderExists))
+	_, err = s.CreateOrders(ctx, []model.OrderInfo{ingestOrder("o3"), ingestOrder("o4")}, false)
+	require.ErrorIs(t, err, ErrOrderExists)
+	_, ok := c.Get("o3")
+	require.False(t, ok)
+}
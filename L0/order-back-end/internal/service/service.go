@@ -21,16 +21,59 @@ func NewOrderService(repository order.Repo) *OrderService {
 }
 
 func (s *OrderService) GetOrderFromDB(ctx context.Context, orderID string, cache cache.Cache) (*model.OrderInfo, error) {
-	fmt.Println("GetOrderFromDB: ", orderID)
+	if err := validateID(orderID); err != nil {
+		return nil, err
+	}
+
 	if cachedOrder, ok := cache.Get(orderID); ok {
 		return &cachedOrder, nil
 	}
 
 	dbOrder, err := s.repository.GetOrderFromDB(ctx, orderID)
 	if err != nil {
+		if domainErr := mapRepoError(err); domainErr != nil {
+			return nil, fmt.Errorf("GetOrderFromDB: %w: %w", domainErr, err)
+		}
 		return nil, fmt.Errorf("GetOrderFromDB: %w", err)
 	}
 
 	cache.Set(orderID, *dbOrder)
 	return dbOrder, nil
 }
+
+// GetOrdersByTrack ищет заказы по track_number в кэше, если там лежит весь трек, иначе в базе
+func (s *OrderService) GetOrdersByTrack(ctx context.Context, trackNumber string, cache cache.Cache) ([]model.OrderInfo, error) {
+	if err := validateID(trackNumber); err != nil {
+		return nil, err
+	}
+
+	if cachedOrders, ok := cache.GetByTrack(trackNumber); ok {
+		return cachedOrders, nil
+	}
+
+	dbOrders, err := s.repository.GetOrdersByTrack(ctx, trackNumber)
+	if err != nil {
+		if domainErr := mapRepoError(err); domainErr != nil {
+			return nil, fmt.Errorf("GetOrdersByTrack: %w: %w", domainErr, err)
+		}
+		return nil, fmt.Errorf("GetOrdersByTrack: %w", err)
+	}
+	if len(dbOrders) == 0 {
+		return nil, ErrOrderNotFound
+	}
+
+	cache.SetTrack(trackNumber, dbOrders)
+	return dbOrders, nil
+}
+
+// ListOrders возвращает страницу заказов по фильтру
+func (s *OrderService) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	page, err := s.repository.ListOrders(ctx, filter)
+	if err != nil {
+		if domainErr := mapRepoError(err); domainErr != nil {
+			return nil, fmt.Errorf("ListOrders: %w: %w", domainErr, err)
+		}
+		return nil, fmt.Errorf("ListOrders: %w", err)
+	}
+	return page, nil
+}
@@ -9,8 +9,12 @@ import (
 	"order-back-end/internal/cache"
 	"order-back-end/internal/model"
 	"order-back-end/internal/repository/mocks"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
 func TestOrderService_GetOrderFromDB(t *testing.T) {
@@ -80,3 +84,200 @@ func TestOrderService_GetOrderFromDB_Error(t *testing.T) {
 	require.Error(t, err)
 	require.Equal(t, fmt.Errorf("GetOrderFromDB: %w", repoErr), err)
 }
+
+func TestOrderService_ListOrders(t *testing.T) {
+	ctr := gomock.NewController(t)
+	defer ctr.Finish()
+
+	repo := mock_order.NewMockRepo(ctr)
+
+	ctx := context.Background()
+	filter := model.OrderFilter{CustomerID: "test", Limit: 2}
+	page := &model.OrderPage{
+		Orders:     []model.OrderInfo{{OrderUID: "1"}, {OrderUID: "2"}},
+		NextCursor: "next",
+	}
+
+	repo.EXPECT().ListOrders(ctx, filter).Return(page, nil).Times(1)
+
+	service := NewOrderService(repo)
+	got, err := service.ListOrders(ctx, filter)
+	require.NoError(t, err)
+	require.Len(t, got.Orders, 2)
+	require.Equal(t, "next", got.NextCursor)
+}
+
+func TestOrderService_ListOrders_Error(t *testing.T) {
+	ctr := gomock.NewController(t)
+	defer ctr.Finish()
+
+	repo := mock_order.NewMockRepo(ctr)
+
+	ctx := context.Background()
+	repoErr := errors.New("db is down")
+
+	repo.EXPECT().ListOrders(ctx, gomock.Any()).Return(nil, repoErr).Times(1)
+
+	service := NewOrderService(repo)
+	_, err := service.ListOrders(ctx, model.OrderFilter{})
+	require.ErrorIs(t, err, repoErr)
+}
+
+func TestOrderService_GetOrdersByTrack(t *testing.T) {
+	ctr := gomock.NewController(t)
+	defer ctr.Finish()
+
+	repo := mock_order.NewMockRepo(ctr)
+
+	ctx := context.Background()
+	cache := cache.NewCache(time.Second*10, 10)
+	orders := []model.OrderInfo{{OrderUID: "123", TrackNumber: "TRACK"}}
+
+	// первый запрос идёт в базу, второй должен быть обслужен кэшем
+	repo.EXPECT().GetOrdersByTrack(ctx, "TRACK").Return(orders, nil).Times(1)
+
+	service := NewOrderService(repo)
+	got, err := service.GetOrdersByTrack(ctx, "TRACK", cache)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+
+	got, err = service.GetOrdersByTrack(ctx, "TRACK", cache)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	require.Equal(t, "123", got[0].OrderUID)
+}
+
+func TestOrderService_GetOrdersByTrack_PartialCache(t *testing.T) {
+	ctr := gomock.NewController(t)
+	defer ctr.Finish()
+
+	repo := mock_order.NewMockRepo(ctr)
+
+	ctx := context.Background()
+	cache := cache.NewCache(time.Second*10, 10)
+	orders := []model.OrderInfo{{OrderUID: "123", TrackNumber: "TRACK"}, {OrderUID: "456", TrackNumber: "TRACK"}}
+
+	// консьюмер положил в кэш только один заказ трека: ответ должен прийти из базы целиком
+	cache.Set("123", orders[0])
+	repo.EXPECT().GetOrdersByTrack(ctx, "TRACK").Return(orders, nil).Times(1)
+
+	service := NewOrderService(repo)
+	got, err := service.GetOrdersByTrack(ctx, "TRACK", cache)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+}
+
+func TestOrderService_GetOrderFromDB_NotFound(t *testing.T) {
+	ctr := gomock.NewController(t)
+	defer ctr.Finish()
+
+	repo := mock_order.NewMockRepo(ctr)
+
+	ctx := context.Background()
+	cache := cache.NewCache(time.Second*10, 10)
+
+	repo.EXPECT().GetOrderFromDB(ctx, "missing").Return(nil, pgx.ErrNoRows).Times(1)
+
+	service := NewOrderService(repo)
+	_, err := service.GetOrderFromDB(ctx, "missing", cache)
+	require.ErrorIs(t, err, ErrOrderNotFound)
+}
+
+func TestOrderService_GetOrderFromDB_StorageUnavailable(t *testing.T) {
+	ctr := gomock.NewController(t)
+	defer ctr.Finish()
+
+	repo := mock_order.NewMockRepo(ctr)
+
+	ctx := context.Background()
+	cache := cache.NewCache(time.Second*10, 10)
+
+	repoErr := &pgconn.PgError{Code: "57P03", Message: "the database system is starting up"}
+	repo.EXPECT().GetOrderFromDB(ctx, "123").Return(nil, repoErr).Times(1)
+
+	service := NewOrderService(repo)
+	_, err := service.GetOrderFromDB(ctx, "123", cache)
+	require.ErrorIs(t, err, ErrStorageUnavailable)
+	require.ErrorIs(t, err, repoErr, "original error must stay in the chain")
+}
+
+func TestOrderService_GetOrderFromDB_InvalidID(t *testing.T) {
+	ctr := gomock.NewController(t)
+	defer ctr.Finish()
+
+	repo := mock_order.NewMockRepo(ctr)
+	repo.EXPECT().GetOrderFromDB(gomock.Any(), gomock.Any()).Times(0)
+
+	service := NewOrderService(repo)
+	for _, id := range []string{"", "with space", strings.Repeat("a", 65)} {
+		_, err := service.GetOrderFromDB(context.Background(), id, cache.NewCache(time.Second*10, 10))
+		require.ErrorIs(t, err, ErrInvalidID, "id %q", id)
+	}
+}
+
+func TestOrderService_WarmUpCache_StopsAtCapacity(t *testing.T) {
+	ctr := gomock.NewController(t)
+	defer ctr.Finish()
+
+	repo := mock_order.NewMockRepo(ctr)
+
+	ctx := context.Background()
+	cache := cache.NewCache(time.Second*10, 3)
+
+	gomock.InOrder(
+		repo.EXPECT().ListOrders(ctx, model.OrderFilter{Limit: 2}).Return(&model.OrderPage{
+			Orders:     []model.OrderInfo{{OrderUID: "1"}, {OrderUID: "2"}},
+			NextCursor: "c1",
+		}, nil),
+		// осталось место только под один заказ
+		repo.EXPECT().ListOrders(ctx, model.OrderFilter{Limit: 1, Cursor: "c1"}).Return(&model.OrderPage{
+			Orders:     []model.OrderInfo{{OrderUID: "3"}},
+			NextCursor: "c2",
+		}, nil),
+	)
+
+	service := NewOrderService(repo)
+	report, err := service.WarmUpCache(ctx, cache, 2)
+	require.NoError(t, err)
+	require.Equal(t, 3, report.Loaded)
+	require.Equal(t, 2, report.Chunks)
+	require.Equal(t, 3, cache.Len())
+}
+
+func TestOrderService_WarmUpCache_EmptyTable(t *testing.T) {
+	ctr := gomock.NewController(t)
+	defer ctr.Finish()
+
+	repo := mock_order.NewMockRepo(ctr)
+
+	ctx := context.Background()
+	cache := cache.NewCache(time.Second*10, 10)
+
+	repo.EXPECT().ListOrders(ctx, gomock.Any()).Return(&model.OrderPage{Orders: []model.OrderInfo{}}, nil).Times(1)
+
+	service := NewOrderService(repo)
+	report, err := service.WarmUpCache(ctx, cache, 5)
+	require.NoError(t, err)
+	require.Equal(t, 0, report.Loaded)
+	require.Equal(t, 0, cache.Len())
+}
+
+func TestOrderService_WarmUpCache_DefaultChunk(t *testing.T) {
+	ctr := gomock.NewController(t)
+	repo := mock_order.NewMockRepo(ctr)
+	ctx := context.Background()
+	cache := cache.NewCache(time.Second*10, 1000)
+
+	// без размера порции прогрев не пропускается, а идёт порциями по умолчанию
+	repo.EXPECT().ListOrders(ctx, model.OrderFilter{Limit: DefaultWarmUpChunk}).Return(&model.OrderPage{
+		Orders: []model.OrderInfo{{OrderUID: "1"}},
+	}, nil).Times(2)
+
+	service := NewOrderService(repo)
+	for _, chunkSize := range []int{0, -1} {
+		report, err := service.WarmUpCache(ctx, cache, chunkSize)
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Loaded)
+	}
+	require.Equal(t, 1, cache.Len())
+}
@@ -0,0 +1,77 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"order-back-end/internal/cache"
+	"order-back-end/internal/logger"
+	"order-back-end/internal/model"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// DefaultWarmUpChunk размер порции прогрева, если в конфиге задан chunkSize <= 0
+const DefaultWarmUpChunk = 100
+
+// WarmUpReport итог прогрева кэша
+type WarmUpReport struct {
+	Loaded   int
+	Chunks   int
+	Duration time.Duration
+}
+
+// WarmUpCache наполняет кэш самыми свежими заказами порциями по chunkSize и останавливается,
+// как только кэш заполнен. Память и время прогрева зависят от размера кэша, а не таблицы.
+// chunkSize <= 0 заменяется на DefaultWarmUpChunk
+func (s *OrderService) WarmUpCache(ctx context.Context, cache cache.Cache, chunkSize int) (WarmUpReport, error) {
+	log := logger.GetOrCreateLoggerFromCtx(ctx)
+	start := time.Now()
+
+	if chunkSize <= 0 {
+		log.Warn(ctx, "cache warm-up chunk must be positive, using default",
+			zap.Int("chunk_size", chunkSize), zap.Int("default", DefaultWarmUpChunk))
+		chunkSize = DefaultWarmUpChunk
+	}
+
+	var report WarmUpReport
+	capacity := cache.Capacity()
+	filter := model.OrderFilter{}
+
+	for {
+		limit := chunkSize
+		if capacity > 0 && capacity-report.Loaded < limit {
+			limit = capacity - report.Loaded
+		}
+		if limit <= 0 {
+			break
+		}
+		filter.Limit = limit
+
+		page, err := s.repository.ListOrders(ctx, filter)
+		if err != nil {
+			report.Duration = time.Since(start)
+			return report, fmt.Errorf("WarmUpCache: %w", err)
+		}
+
+		for _, o := range page.Orders {
+			cache.Set(o.OrderUID, o)
+		}
+		report.Loaded += len(page.Orders)
+		report.Chunks++
+
+		log.Info(ctx, "cache warm-up progress",
+			zap.Int("loaded", report.Loaded),
+			zap.Int("capacity", capacity),
+			zap.Int("chunk", report.Chunks),
+		)
+
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	report.Duration = time.Since(start)
+	return report, nil
+}
@@ -0,0 +1,190 @@
+package validator
+
+import (
+	"errors"
+	"fmt"
+	"order-back-end/internal/model"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// Режимы проверки бизнес-правил
+const (
+	// ModeStrict нарушение бизнес-правила отклоняет заказ
+	ModeStrict = "strict"
+	// ModeLenient заказ принимается, нарушения бизнес-правил возвращаются как предупреждения
+	ModeLenient = "lenient"
+)
+
+// Коды бизнес-правил
+const (
+	RuleGoodsTotal  = "goods_total_mismatch"  // goods_total равен сумме total_price товаров
+	RuleAmount      = "amount_mismatch"       // amount = goods_total + delivery_cost + custom_fee
+	RuleTotalPrice  = "total_price_mismatch"  // total_price = price со скидкой sale процентов
+	RuleSaleRange   = "sale_range"            // скидка от 0 до 100 процентов
+	RuleTrackNumber = "track_number_mismatch" // товар относится к отправлению заказа
+)
+
+// DefaultPhoneRegion страна телефонов в национальном формате, если ключ default_region в конфиге не задан
+const DefaultPhoneRegion = "RU"
+
+// ErrInvalidMode неизвестный режим проверки
+var ErrInvalidMode = errors.New("invalid validation mode")
+
+// Config настройки проверки заказов
+type Config struct {
+	// Mode strict или lenient
+	Mode string `yaml:"mode" env:"VALIDATION_MODE" env-default:"strict"`
+	// Tolerance допустимое расхождение денежных сумм в минимальных единицах валюты, покрывает округления
+	Tolerance int `yaml:"tolerance" env-default:"0"`
+	// DefaultRegion страна (ISO 3166-1 alpha-2) для телефонов в национальном формате;
+	// пустая - принимаются только номера в международном формате. Без ключа в конфиге - DefaultPhoneRegion
+	DefaultRegion string `yaml:"default_region"`
+	// StrictPhoneLengths отклонять международные номера, длина которых не совпадает с правилами их страны;
+	// по умолчанию такие номера проверяются только по общим ограничениям E.164
+	StrictPhoneLengths bool `yaml:"strict_phone_lengths" env-default:"false"`
+	// ZipCountry страна (ISO 3166-1 alpha-2), в формате которой проверяются индексы доставки. Страну
+	// получателя заказ не содержит, а по телефону её определять нельзя; пустая - индекс только нормализуется
+	ZipCountry string `yaml:"zip_country"`
+	// Rules правила полей заказа; без секции действуют DefaultRules, пустой список отключает их
+	Rules []RuleSpec `yaml:"rules"`
+}
+
+// Validator проверяет поля заказа по декларативным правилам, форматы контактных и платёжных полей
+// и согласованность сумм. Нулевое значение - строгий режим без допуска с DefaultRules,
+// телефоны только в международном формате
+type Validator struct {
+	state atomic.Pointer[settings]
+}
+
+// settings скомпилированные настройки валидатора, при перезагрузке заменяются целиком
+type settings struct {
+	lenient       bool
+	tolerance     int
+	defaultRegion string
+	strictPhones  bool
+	zipCountry    string
+	specs         []RuleSpec
+	rules         ruleSet
+}
+
+var defaultSettings = &settings{specs: DefaultRules(), rules: defaultRules}
+
+// New создаёт валидатор по настройкам
+func New(cfg Config) (*Validator, error) {
+	v := &Validator{}
+	if err := v.Reload(cfg); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Reload компилирует настройки и правила и заменяет ими текущие без остановки проверок.
+// При ошибке продолжают действовать прежние настройки
+func (v *Validator) Reload(cfg Config) error {
+	s, err := compileSettings(cfg)
+	if err != nil {
+		return err
+	}
+	v.state.Store(s)
+	return nil
+}
+
+func compileSettings(cfg Config) (*settings, error) {
+	s := &settings{
+		tolerance:     cfg.Tolerance,
+		defaultRegion: strings.ToUpper(cfg.DefaultRegion),
+		strictPhones:  cfg.StrictPhoneLengths,
+		zipCountry:    strings.ToUpper(cfg.ZipCountry),
+		specs:         DefaultRules(),
+		rules:         defaultRules,
+	}
+	switch cfg.Mode {
+	case "", ModeStrict:
+	case ModeLenient:
+		s.lenient = true
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMode, cfg.Mode)
+	}
+	if cfg.Tolerance < 0 {
+		return nil, fmt.Errorf("validation tolerance must be >= 0, got %d", cfg.Tolerance)
+	}
+	if _, ok := phoneRegions[s.defaultRegion]; s.defaultRegion != "" && !ok {
+		return nil, fmt.Errorf("validation default_region %q is not supported", cfg.DefaultRegion)
+	}
+	if _, ok := zipPatterns[s.zipCountry]; s.zipCountry != "" && !ok {
+		return nil, fmt.Errorf("validation zip_country %q is not supported", cfg.ZipCountry)
+	}
+	if cfg.Rules != nil {
+		rules, err := compileRules(cfg.Rules)
+		if err != nil {
+			return nil, err
+		}
+		s.specs, s.rules = slices.Clone(cfg.Rules), rules
+	}
+	return s, nil
+}
+
+// load текущие настройки, для нулевого валидатора - настройки по умолчанию
+func (v *Validator) load() *settings {
+	if s := v.state.Load(); s != nil {
+		return s
+	}
+	return defaultSettings
+}
+
+// Validate возвращает *ValidationError со всеми нарушениями, из-за которых заказ отклоняется.
+// Корректные email, телефон, индекс, валюта и локаль записываются в заказ в нормализованном виде.
+// В мягком режиме нарушения бизнес-правил заказ не отклоняют и возвращаются в warnings
+func (v *Validator) Validate(order *model.OrderInfo) (warnings []Violation, err error) {
+	s := v.load()
+	r := &report{}
+	checkFields(r, s.rules, order)
+	s.checkFormats(r, order)
+	checkLengths(r, order)
+
+	business := &report{}
+	s.checkConsistency(business, order)
+	if s.lenient {
+		return business.violations, r.err()
+	}
+	r.violations = append(r.violations, business.violations...)
+	return nil, r.err()
+}
+
+// checkConsistency сверяет суммы заказа между собой и товары с заказом
+func (s *settings) checkConsistency(r *report, order *model.OrderInfo) {
+	goodsTotal := 0
+	for i, item := range order.Items {
+		path := itemPath(i)
+		if item.TrackNumber != "" && order.TrackNumber != "" && item.TrackNumber != order.TrackNumber {
+			r.add(path+".track_number", RuleTrackNumber,
+				fmt.Sprintf("%s.track_number %q differs from order track_number %q", path, item.TrackNumber, order.TrackNumber))
+		}
+		if item.Sale < 0 || item.Sale > 100 {
+			r.add(path+".sale", RuleSaleRange, path+".sale must be between 0 and 100")
+		} else if want := item.Price * (100 - item.Sale) / 100; !s.within(item.TotalPrice, want) {
+			r.add(path+".total_price", RuleTotalPrice,
+				fmt.Sprintf("%s.total_price %d does not match price %d with sale %d%%: want %d",
+					path, item.TotalPrice, item.Price, item.Sale, want))
+		}
+		goodsTotal += item.TotalPrice
+	}
+
+	p := order.Payment
+	if !s.within(p.GoodsTotal, goodsTotal) {
+		r.add("payment.goods_total", RuleGoodsTotal,
+			fmt.Sprintf("payment.goods_total %d does not match sum of items total_price %d", p.GoodsTotal, goodsTotal))
+	}
+	if want := p.GoodsTotal + p.DeliveryCost + p.CustomFee; !s.within(p.Amount, want) {
+		r.add("payment.amount", RuleAmount,
+			fmt.Sprintf("payment.amount %d does not match goods_total + delivery_cost + custom_fee %d", p.Amount, want))
+	}
+}
+
+// within сумма отличается от ожидаемой не больше чем на допуск
+func (s *settings) within(got, want int) bool {
+	diff := got - want
+	return diff <= s.tolerance && -diff <= s.tolerance
+}
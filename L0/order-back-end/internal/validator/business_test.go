@@ -0,0 +1,115 @@
+package validator_test
+
+import (
+	"testing"
+
+	"order-back-end/internal/model"
+	"order-back-end/internal/validator"
+
+	"github.com/stretchr/testify/require"
+)
+
+// makeConsistentOrder валидный заказ с корректными форматами полей и согласованными суммами:
+// 500 за товары и 500 за доставку
+func makeConsistentOrder() *model.OrderInfo {
+	order := makeValidOrder()
+	order.Delivery.Phone = "+79001234567"
+	order.Payment.GoodsTotal = 500
+	order.Payment.DeliveryCost = 500
+	return order
+}
+
+func newValidator(t *testing.T, cfg validator.Config) *validator.Validator {
+	t.Helper()
+	v, err := validator.New(cfg)
+	require.NoError(t, err)
+	return v
+}
+
+func TestValidatorConsistentOrder(t *testing.T) {
+	order := makeConsistentOrder()
+	order.Items = append(order.Items, model.Item{
+		ChrtID: 2, TrackNumber: "TRACK123", Name: "Item2", Price: 453, Sale: 30, TotalPrice: 317,
+	})
+	order.Payment.GoodsTotal = 817
+	order.Payment.CustomFee = 7
+	order.Payment.Amount = 1324
+
+	warnings, err := newValidator(t, validator.Config{}).Validate(order)
+	require.NoError(t, err)
+	require.Empty(t, warnings)
+}
+
+func TestValidatorStrictReportsMismatches(t *testing.T) {
+	order := makeConsistentOrder()
+	order.Items[0].TrackNumber = "OTHER"
+	order.Items[0].Sale = 10 // 500 со скидкой 10% это 450, а не 500
+	order.Payment.GoodsTotal = 400
+	order.Payment.Amount = 1000
+
+	warnings, err := newValidator(t, validator.Config{Mode: validator.ModeStrict}).Validate(order)
+	require.Empty(t, warnings)
+	var verr *validator.ValidationError
+	require.ErrorAs(t, err, &verr)
+
+	rules := map[string]string{}
+	for _, v := range verr.Violations {
+		rules[v.Path] = v.Rule
+	}
+	require.Equal(t, map[string]string{
+		"items[0].track_number": validator.RuleTrackNumber,
+		"items[0].total_price":  validator.RuleTotalPrice,
+		"payment.goods_total":   validator.RuleGoodsTotal,
+		"payment.amount":        validator.RuleAmount,
+	}, rules)
+}
+
+func TestValidatorLenientAcceptsWithWarnings(t *testing.T) {
+	order := makeConsistentOrder()
+	order.Payment.Amount = 999
+
+	warnings, err := newValidator(t, validator.Config{Mode: validator.ModeLenient}).Validate(order)
+	require.NoError(t, err)
+	require.Equal(t, []validator.Violation{{
+		Path:    "payment.amount",
+		Rule:    validator.RuleAmount,
+		Message: "payment.amount 999 does not match goods_total + delivery_cost + custom_fee 1000",
+	}}, warnings)
+
+	// обязательные поля отклоняют заказ и в мягком режиме
+	order.OrderUID = ""
+	warnings, err = newValidator(t, validator.Config{Mode: validator.ModeLenient}).Validate(order)
+	require.Len(t, warnings, 1)
+	requireViolation(t, err, "order_uid", validator.RuleRequired, "order_uid is required")
+}
+
+func TestValidatorTolerance(t *testing.T) {
+	order := makeConsistentOrder()
+	order.Items[0].Price = 453
+	order.Items[0].Sale = 30
+	order.Items[0].TotalPrice = 318 // округление вверх: 453 * 0.7 = 317.1
+	order.Payment.GoodsTotal = 318
+	order.Payment.Amount = 819
+
+	_, err := newValidator(t, validator.Config{}).Validate(order)
+	require.Error(t, err)
+
+	_, err = newValidator(t, validator.Config{Tolerance: 1}).Validate(order)
+	require.NoError(t, err)
+}
+
+func TestValidatorSaleRange(t *testing.T) {
+	order := makeConsistentOrder()
+	order.Items[0].Sale = 120
+
+	_, err := newValidator(t, validator.Config{}).Validate(order)
+	requireViolation(t, err, "items[0].sale", validator.RuleSaleRange, "items[0].sale must be between 0 and 100")
+}
+
+func TestNewValidatorRejectsInvalidConfig(t *testing.T) {
+	_, err := validator.New(validator.Config{Mode: "relaxed"})
+	require.ErrorIs(t, err, validator.ErrInvalidMode)
+
+	_, err = validator.New(validator.Config{Tolerance: -1})
+	require.Error(t, err)
+}
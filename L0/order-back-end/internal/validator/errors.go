@@ -0,0 +1,71 @@
+package validator
+
+import (
+	"strings"
+)
+
+// Коды правил валидации
+const (
+	RuleRequired    = "required"     // строка не пустая
+	RulePositive    = "positive"     // число > 0
+	RuleNonNegative = "non_negative" // число >= 0
+	RuleMinItems    = "min_items"    // в массиве есть хотя бы один элемент
+	RuleTimestamp   = "timestamp"    // время задано и правдоподобно
+)
+
+// Violation нарушенное правило
+type Violation struct {
+	// Path JSON путь к полю: payment.amount, items[1].price
+	Path    string `json:"path"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationError все нарушения, найденные в заказе
+type ValidationError struct {
+	Violations []Violation `json:"violations"`
+}
+
+// Error сообщения нарушений через "; "
+func (e *ValidationError) Error() string {
+	messages := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		messages[i] = v.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// report копит нарушения по ходу проверки
+type report struct {
+	violations []Violation
+}
+
+func (r *report) add(path, rule, message string) {
+	r.violations = append(r.violations, Violation{Path: path, Rule: rule, Message: message})
+}
+
+func (r *report) required(path, value string) {
+	if strings.TrimSpace(value) == "" {
+		r.add(path, RuleRequired, path+" is required")
+	}
+}
+
+func (r *report) positive(path string, value int64) {
+	if value <= 0 {
+		r.add(path, RulePositive, path+" must be > 0")
+	}
+}
+
+func (r *report) nonNegative(path string, value int64) {
+	if value < 0 {
+		r.add(path, RuleNonNegative, path+" must be >= 0")
+	}
+}
+
+// err nil без нарушений, иначе *ValidationError
+func (r *report) err() error {
+	if len(r.violations) == 0 {
+		return nil
+	}
+	return &ValidationError{Violations: r.violations}
+}
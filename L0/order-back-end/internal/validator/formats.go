@@ -0,0 +1,224 @@
+package validator
+
+import (
+	"fmt"
+	"net/mail"
+	"order-back-end/internal/model"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Коды правил формата полей
+const (
+	RuleEmail    = "email"    // адрес по RFC 5322
+	RulePhone    = "phone"    // номер приводится к E.164 и подходит под правила страны
+	RuleCurrency = "currency" // код валюты ISO 4217
+	RuleLocale   = "locale"   // язык ISO 639-1, опционально с регионом: en, en-US
+	RuleZip      = "zip"      // индекс в формате страны zip_country
+)
+
+// phoneRegion правила номеров страны
+type phoneRegion struct {
+	code    string // код страны в E.164
+	lengths []int  // допустимые длины национального номера
+	trunk   string // префикс национального формата, который отбрасывается: 8 в 8 (900) 123-45-67
+}
+
+// phoneRegions страны по ISO 3166-1 alpha-2
+var phoneRegions = map[string]phoneRegion{
+	"RU": {code: "7", lengths: []int{10}, trunk: "8"},
+	"KZ": {code: "7", lengths: []int{10}, trunk: "8"},
+	"BY": {code: "375", lengths: []int{9}, trunk: "80"},
+	"UA": {code: "380", lengths: []int{9}, trunk: "0"},
+	"AM": {code: "374", lengths: []int{8}, trunk: "0"},
+	"AZ": {code: "994", lengths: []int{9}, trunk: "0"},
+	"GE": {code: "995", lengths: []int{9}, trunk: "0"},
+	"KG": {code: "996", lengths: []int{9}, trunk: "0"},
+	"UZ": {code: "998", lengths: []int{9}},
+	"US": {code: "1", lengths: []int{10}, trunk: "1"},
+	"GB": {code: "44", lengths: []int{9, 10}, trunk: "0"},
+	"DE": {code: "49", lengths: []int{7, 8, 9, 10, 11}, trunk: "0"},
+	"FR": {code: "33", lengths: []int{9}, trunk: "0"},
+	"ES": {code: "34", lengths: []int{9}},
+	"IT": {code: "39", lengths: []int{6, 7, 8, 9, 10, 11}},
+	"PL": {code: "48", lengths: []int{9}},
+	"NL": {code: "31", lengths: []int{9}, trunk: "0"},
+	"TR": {code: "90", lengths: []int{10}, trunk: "0"},
+	"IL": {code: "972", lengths: []int{8, 9}, trunk: "0"},
+	"CN": {code: "86", lengths: []int{10, 11}, trunk: "0"},
+	"IN": {code: "91", lengths: []int{10}, trunk: "0"},
+}
+
+// regionByCode страна по коду E.164; для общих кодов берётся основная страна
+var regionByCode = func() map[string]string {
+	byCode := map[string]string{"7": "RU", "1": "US"}
+	for region, rules := range phoneRegions {
+		if _, ok := byCode[rules.code]; !ok {
+			byCode[rules.code] = region
+		}
+	}
+	return byCode
+}()
+
+// zipPatterns форматы почтовых индексов после нормализации
+var zipPatterns = map[string]*regexp.Regexp{
+	"RU": regexp.MustCompile(`^\d{6}$`),
+	"KZ": regexp.MustCompile(`^\d{6}$`),
+	"BY": regexp.MustCompile(`^\d{6}$`),
+	"UA": regexp.MustCompile(`^\d{5}$`),
+	"AM": regexp.MustCompile(`^\d{4}$`),
+	"AZ": regexp.MustCompile(`^AZ ?\d{4}$`),
+	"GE": regexp.MustCompile(`^\d{4}$`),
+	"KG": regexp.MustCompile(`^\d{6}$`),
+	"UZ": regexp.MustCompile(`^\d{6}$`),
+	"US": regexp.MustCompile(`^\d{5}(-\d{4})?$`),
+	"GB": regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? \d[A-Z]{2}$`),
+	"DE": regexp.MustCompile(`^\d{5}$`),
+	"FR": regexp.MustCompile(`^\d{5}$`),
+	"ES": regexp.MustCompile(`^\d{5}$`),
+	"IT": regexp.MustCompile(`^\d{5}$`),
+	"PL": regexp.MustCompile(`^\d{2}-\d{3}$`),
+	"NL": regexp.MustCompile(`^\d{4} [A-Z]{2}$`),
+	"TR": regexp.MustCompile(`^\d{5}$`),
+	"IL": regexp.MustCompile(`^\d{5}(\d{2})?$`),
+	"CN": regexp.MustCompile(`^\d{6}$`),
+	"IN": regexp.MustCompile(`^\d{6}$`),
+}
+
+// checkFormats проверяет форматы контактных и платёжных полей и записывает в заказ нормализованные значения.
+// Пустые значения пропускаются: обязательность проверяет checkFields
+func (s *settings) checkFormats(r *report, order *model.OrderInfo) {
+	d := &order.Delivery
+
+	if d.Email != "" {
+		if email, ok := normalizeEmail(d.Email); ok {
+			d.Email = email
+		} else {
+			r.add("delivery.email", RuleEmail, "delivery.email is not a valid email address")
+		}
+	}
+
+	if d.Phone != "" {
+		phone, _, err := normalizePhone(d.Phone, s.defaultRegion, s.strictPhones)
+		if err != nil {
+			r.add("delivery.phone", RulePhone, "delivery.phone "+err.Error())
+		} else {
+			d.Phone = phone
+		}
+	}
+
+	// страна телефона не обязана совпадать со страной доставки, поэтому формат индекса берётся из настроек
+	if d.Zip != "" {
+		d.Zip = strings.Join(strings.Fields(strings.ToUpper(d.Zip)), " ")
+		if pattern, ok := zipPatterns[s.zipCountry]; ok && !pattern.MatchString(d.Zip) {
+			r.add("delivery.zip", RuleZip, fmt.Sprintf("delivery.zip %q does not match postal code format of %s", d.Zip, s.zipCountry))
+		}
+	}
+
+	if order.Payment.Currency != "" {
+		currency := strings.ToUpper(strings.TrimSpace(order.Payment.Currency))
+		if _, ok := currencies[currency]; ok {
+			order.Payment.Currency = currency
+		} else {
+			r.add("payment.currency", RuleCurrency, fmt.Sprintf("payment.currency %q is not an ISO 4217 code", order.Payment.Currency))
+		}
+	}
+
+	if order.Locale != "" {
+		if locale, ok := normalizeLocale(order.Locale); ok {
+			order.Locale = locale
+		} else {
+			r.add("locale", RuleLocale, fmt.Sprintf("locale %q is not an ISO 639-1 language", order.Locale))
+		}
+	}
+}
+
+// normalizeEmail разбирает адрес по RFC 5322 и возвращает его без отображаемого имени, с доменом в нижнем регистре
+func normalizeEmail(raw string) (string, bool) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(raw))
+	if err != nil {
+		return "", false
+	}
+	at := strings.LastIndexByte(addr.Address, '@')
+	return addr.Address[:at] + "@" + strings.ToLower(addr.Address[at+1:]), true
+}
+
+// normalizePhone приводит номер к E.164 и возвращает страну, правила которой к нему применены.
+// Номер без + или 00 считается национальным номером страны defaultRegion. Международный номер,
+// не подходящий по длине под правила своей страны, без strict проверяется только по общим ограничениям E.164
+func normalizePhone(raw, defaultRegion string, strict bool) (phone, region string, err error) {
+	var b strings.Builder
+	for i, c := range strings.TrimSpace(raw) {
+		switch {
+		case c >= '0' && c <= '9':
+			b.WriteRune(c)
+		case c == '+' && i == 0:
+		case strings.ContainsRune(" -().", c):
+		default:
+			return "", "", fmt.Errorf("contains %q", c)
+		}
+	}
+	digits := b.String()
+
+	international := strings.HasPrefix(strings.TrimSpace(raw), "+")
+	if !international && strings.HasPrefix(digits, "00") {
+		digits, international = digits[2:], true
+	}
+
+	if !international {
+		rules, ok := phoneRegions[defaultRegion]
+		if !ok {
+			return "", "", fmt.Errorf("must be in international format +<country code><number>")
+		}
+		national := digits
+		if rules.trunk != "" && strings.HasPrefix(digits, rules.trunk) &&
+			slices.Contains(rules.lengths, len(digits)-len(rules.trunk)) {
+			national = digits[len(rules.trunk):]
+		}
+		if !slices.Contains(rules.lengths, len(national)) {
+			return "", "", fmt.Errorf("is not a valid %s number", defaultRegion)
+		}
+		return "+" + rules.code + national, defaultRegion, nil
+	}
+
+	// E.164: код страны не начинается с нуля, всего не больше 15 цифр
+	if len(digits) < 8 || len(digits) > 15 || digits[0] == '0' {
+		return "", "", fmt.Errorf("is not a valid E.164 number")
+	}
+	for n := 3; n >= 1; n-- {
+		if region, ok := regionByCode[digits[:n]]; ok {
+			if strict && !slices.Contains(phoneRegions[region].lengths, len(digits)-n) {
+				return "", "", fmt.Errorf("is not a valid %s number", region)
+			}
+			return "+" + digits, region, nil
+		}
+	}
+	// для стран без правил проверяются только общие ограничения E.164
+	return "+" + digits, "", nil
+}
+
+// normalizeLocale приводит локаль к виду en или en-US
+func normalizeLocale(raw string) (string, bool) {
+	lang, region, hasRegion := strings.Cut(strings.ReplaceAll(strings.TrimSpace(raw), "_", "-"), "-")
+	lang = strings.ToLower(lang)
+	if _, ok := languages[lang]; !ok {
+		return "", false
+	}
+	if !hasRegion {
+		return lang, true
+	}
+	if len(region) != 2 || !isLetters(region) {
+		return "", false
+	}
+	return lang + "-" + strings.ToUpper(region), true
+}
+
+func isLetters(s string) bool {
+	for _, c := range s {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') {
+			return false
+		}
+	}
+	return true
+}
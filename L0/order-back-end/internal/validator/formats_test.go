@@ -0,0 +1,151 @@
+package validator_test
+
+import (
+	"testing"
+
+	"order-back-end/internal/validator"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorNormalizesFormats(t *testing.T) {
+	order := makeConsistentOrder()
+	order.Delivery.Email = "Test Testov <Test.User@Gmail.COM>"
+	order.Delivery.Phone = "8 (900) 123-45-67"
+	order.Delivery.Zip = " 123456 "
+	order.Payment.Currency = "rub"
+	order.Locale = "ru_ru"
+
+	_, err := newValidator(t, validator.Config{DefaultRegion: "RU"}).Validate(order)
+	require.NoError(t, err)
+	require.Equal(t, "Test.User@gmail.com", order.Delivery.Email)
+	require.Equal(t, "+79001234567", order.Delivery.Phone)
+	require.Equal(t, "123456", order.Delivery.Zip)
+	require.Equal(t, "RUB", order.Payment.Currency)
+	require.Equal(t, "ru-RU", order.Locale)
+}
+
+func TestValidatorPhoneRegions(t *testing.T) {
+	tests := []struct {
+		phone string
+		want  string // пусто - номер невалиден
+	}{
+		{phone: "+7 900 123-45-67", want: "+79001234567"},
+		{phone: "0079001234567", want: "+79001234567"},
+		{phone: "89001234567", want: "+79001234567"},
+		{phone: "9001234567", want: "+79001234567"},
+		{phone: "+972 54-123-4567", want: "+972541234567"},
+		{phone: "+44 20 7946 0958", want: "+442079460958"},
+		{phone: "+1 (212) 555-0100", want: "+12125550100"},
+		{phone: "+595 21 123456", want: "+59521123456"}, // страна без правил: только E.164
+		{phone: "+9720000000", want: "+9720000000"},     // длина не по правилам IL: только E.164
+		{phone: "+7 900 123-45-6", want: "+7900123456"},
+		{phone: "900 123-45-6"}, // национальный формат всегда проверяется по правилам страны
+		{phone: "123456"},
+		{phone: "+0 123 456 789"},
+		{phone: "+7 900 123 45 67 ext 1"},
+		{phone: "+1234567890123456"},
+	}
+
+	v := newValidator(t, validator.Config{DefaultRegion: "RU"})
+	for _, tt := range tests {
+		t.Run(tt.phone, func(t *testing.T) {
+			order := makeConsistentOrder()
+			order.Delivery.Phone = tt.phone
+			_, err := v.Validate(order)
+			if tt.want == "" {
+				var verr *validator.ValidationError
+				require.ErrorAs(t, err, &verr)
+				require.Len(t, verr.Violations, 1)
+				require.Equal(t, "delivery.phone", verr.Violations[0].Path)
+				require.Equal(t, validator.RulePhone, verr.Violations[0].Rule)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tt.want, order.Delivery.Phone)
+		})
+	}
+
+	// без страны по умолчанию национальный формат не принимается
+	order := makeConsistentOrder()
+	order.Delivery.Phone = "89001234567"
+	_, err := newValidator(t, validator.Config{}).Validate(order)
+	requireViolation(t, err, "delivery.phone", validator.RulePhone,
+		"delivery.phone must be in international format +<country code><number>")
+}
+
+func TestValidatorStrictPhoneLengths(t *testing.T) {
+	v := newValidator(t, validator.Config{DefaultRegion: "RU", StrictPhoneLengths: true})
+
+	for phone, message := range map[string]string{
+		"+9720000000":     "delivery.phone is not a valid IL number",
+		"+7 900 123-45-6": "delivery.phone is not a valid RU number",
+	} {
+		order := makeConsistentOrder()
+		order.Delivery.Phone = phone
+		_, err := v.Validate(order)
+		requireViolation(t, err, "delivery.phone", validator.RulePhone, message)
+	}
+
+	order := makeConsistentOrder()
+	order.Delivery.Phone = "+972 54-123-4567"
+	_, err := v.Validate(order)
+	require.NoError(t, err)
+}
+
+func TestValidatorZipCountry(t *testing.T) {
+	v := newValidator(t, validator.Config{DefaultRegion: "RU", ZipCountry: "IL"})
+
+	// формат индекса задаётся настройкой, а не кодом телефона
+	order := makeConsistentOrder()
+	order.Delivery.Phone = "+79001234567"
+	order.Delivery.Zip = "2639809"
+	_, err := v.Validate(order)
+	require.NoError(t, err)
+
+	order.Delivery.Zip = "26398"
+	_, err = v.Validate(order)
+	require.NoError(t, err, "legacy 5-digit code")
+
+	order.Delivery.Zip = "2639-809"
+	_, err = v.Validate(order)
+	requireViolation(t, err, "delivery.zip", validator.RuleZip, `delivery.zip "2639-809" does not match postal code format of IL`)
+
+	order = makeConsistentOrder()
+	order.Delivery.Zip = "sw1a   1aa"
+	_, err = newValidator(t, validator.Config{ZipCountry: "gb"}).Validate(order)
+	require.NoError(t, err)
+	require.Equal(t, "SW1A 1AA", order.Delivery.Zip)
+
+	// без zip_country индекс не проверяется по стране телефона или default_region
+	order = makeConsistentOrder()
+	order.Delivery.Phone = "+972541234567"
+	order.Delivery.Zip = "sw1a   1aa"
+	_, err = newValidator(t, validator.Config{DefaultRegion: "RU"}).Validate(order)
+	require.NoError(t, err)
+	require.Equal(t, "SW1A 1AA", order.Delivery.Zip)
+
+	_, err = validator.New(validator.Config{ZipCountry: "XX"})
+	require.EqualError(t, err, `validation zip_country "XX" is not supported`)
+}
+
+func TestValidatorRejectsInvalidFormats(t *testing.T) {
+	order := makeConsistentOrder()
+	order.Delivery.Email = "not an email"
+	order.Payment.Currency = "RUR"
+	order.Locale = "english"
+
+	_, err := newValidator(t, validator.Config{Mode: validator.ModeLenient}).Validate(order)
+	var verr *validator.ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Equal(t, []validator.Violation{
+		{Path: "delivery.email", Rule: validator.RuleEmail, Message: "delivery.email is not a valid email address"},
+		{Path: "payment.currency", Rule: validator.RuleCurrency, Message: `payment.currency "RUR" is not an ISO 4217 code`},
+		{Path: "locale", Rule: validator.RuleLocale, Message: `locale "english" is not an ISO 639-1 language`},
+	}, verr.Violations)
+}
+
+func TestNewValidatorRejectsUnknownRegion(t *testing.T) {
+	_, err := validator.New(validator.Config{DefaultRegion: "XX"})
+	require.Error(t, err)
+}
@@ -0,0 +1,91 @@
+package validator
+
+import (
+	"fmt"
+	"order-back-end/internal/model"
+	"unicode/utf8"
+)
+
+// RuleMaxLength строка помещается в колонку базы
+const RuleMaxLength = "max_length"
+
+// columnLength поле заказа и размер его колонки VARCHAR(n) в базе
+type columnLength struct {
+	path  string
+	max   int
+	value func(o *model.OrderInfo) string
+	// normalized значение нормализуется в checkFormats, в схеме длина исходной строки не ограничивается
+	normalized bool
+}
+
+// columnLengths размеры колонок из migrations; items[] проверяется у каждого товара
+var columnLengths = []columnLength{
+	{path: "order_uid", max: 64, value: func(o *model.OrderInfo) string { return o.OrderUID }},
+	{path: "track_number", max: 64, value: func(o *model.OrderInfo) string { return o.TrackNumber }},
+	{path: "entry", max: 16, value: func(o *model.OrderInfo) string { return o.Entry }},
+	{path: "locale", max: 8, value: func(o *model.OrderInfo) string { return o.Locale }, normalized: true},
+	{path: "customer_id", max: 64, value: func(o *model.OrderInfo) string { return o.CustomerID }},
+	{path: "delivery_service", max: 64, value: func(o *model.OrderInfo) string { return o.DeliveryService }},
+	{path: "shardkey", max: 8, value: func(o *model.OrderInfo) string { return o.ShardKey }},
+	{path: "oof_shard", max: 8, value: func(o *model.OrderInfo) string { return o.OofShard }},
+
+	{path: "delivery.name", max: 255, value: func(o *model.OrderInfo) string { return o.Delivery.Name }},
+	{path: "delivery.phone", max: 32, value: func(o *model.OrderInfo) string { return o.Delivery.Phone }, normalized: true},
+	{path: "delivery.zip", max: 16, value: func(o *model.OrderInfo) string { return o.Delivery.Zip }, normalized: true},
+	{path: "delivery.city", max: 128, value: func(o *model.OrderInfo) string { return o.Delivery.City }},
+	{path: "delivery.region", max: 128, value: func(o *model.OrderInfo) string { return o.Delivery.Region }},
+	{path: "delivery.email", max: 255, value: func(o *model.OrderInfo) string { return o.Delivery.Email }, normalized: true},
+
+	{path: "payment.transaction", max: 64, value: func(o *model.OrderInfo) string { return o.Payment.Transaction }},
+	{path: "payment.request_id", max: 64, value: func(o *model.OrderInfo) string { return o.Payment.RequestID }},
+	{path: "payment.currency", max: 8, value: func(o *model.OrderInfo) string { return o.Payment.Currency }, normalized: true},
+	{path: "payment.provider", max: 64, value: func(o *model.OrderInfo) string { return o.Payment.Provider }},
+	{path: "payment.bank", max: 64, value: func(o *model.OrderInfo) string { return o.Payment.Bank }},
+}
+
+// itemColumnLengths размеры колонок items
+var itemColumnLengths = []struct {
+	name  string
+	max   int
+	value func(item *model.Item) string
+}{
+	{name: "track_number", max: 64, value: func(item *model.Item) string { return item.TrackNumber }},
+	{name: "rid", max: 64, value: func(item *model.Item) string { return item.RID }},
+	{name: "name", max: 255, value: func(item *model.Item) string { return item.Name }},
+	{name: "size", max: 16, value: func(item *model.Item) string { return item.Size }},
+	{name: "brand", max: 128, value: func(item *model.Item) string { return item.Brand }},
+}
+
+// checkLengths проверяет, что строки заказа помещаются в колонки базы; вызывается после нормализации форматов.
+// VARCHAR(n) ограничивает число символов, а не байт
+func checkLengths(r *report, order *model.OrderInfo) {
+	for _, col := range columnLengths {
+		maxLength(r, col.path, col.value(order), col.max)
+	}
+	for i := range order.Items {
+		path := itemPath(i)
+		for _, col := range itemColumnLengths {
+			maxLength(r, path+"."+col.name, col.value(&order.Items[i]), col.max)
+		}
+	}
+}
+
+func maxLength(r *report, path, value string, limit int) {
+	if utf8.RuneCountInString(value) > limit {
+		r.add(path, RuleMaxLength, fmt.Sprintf("%s must be at most %d characters", path, limit))
+	}
+}
+
+// schemaMaxLengths размеры колонок для схемы: поля, которые валидатор не нормализует, по JSON путям схемы
+func schemaMaxLengths() map[string]int {
+	lengths := make(map[string]int, len(columnLengths)+len(itemColumnLengths))
+	for _, col := range columnLengths {
+		if !col.normalized {
+			lengths[col.path] = col.max
+		}
+	}
+	for _, col := range itemColumnLengths {
+		lengths["items[]."+col.name] = col.max
+	}
+	return lengths
+}
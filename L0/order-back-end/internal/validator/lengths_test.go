@@ -0,0 +1,42 @@
+package validator_test
+
+import (
+	"strings"
+	"testing"
+
+	"order-back-end/internal/validator"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorColumnLengths(t *testing.T) {
+	v := newValidator(t, validator.Config{DefaultRegion: "RU"})
+
+	// строки длиной с колонку проходят, длина считается в символах
+	order := makeConsistentOrder()
+	order.OrderUID = strings.Repeat("я", 64)
+	order.Entry = strings.Repeat("e", 16)
+	_, err := v.Validate(order)
+	require.NoError(t, err)
+
+	order = makeConsistentOrder()
+	order.OrderUID = strings.Repeat("u", 65)
+	order.Entry = strings.Repeat("e", 17)
+	order.ShardKey = "123456789"
+	order.Delivery.Zip = strings.Repeat("1", 17)
+	order.Items[0].Size = strings.Repeat("s", 17)
+	_, err = v.Validate(order)
+	for path, limit := range map[string]string{
+		"order_uid": "64", "entry": "16", "shardkey": "8", "delivery.zip": "16", "items[0].size": "16",
+	} {
+		requireViolation(t, err, path, validator.RuleMaxLength, path+" must be at most "+limit+" characters")
+	}
+}
+
+func TestSchemaMaxLength(t *testing.T) {
+	schema := newValidator(t, validator.Config{}).Schema(false)
+	require.Equal(t, 64, *schema.Property("order_uid").MaxLength)
+	require.Equal(t, 16, *schema.Property("items[].size").MaxLength)
+	// нормализуемые поля ограничиваются после нормализации, исходная строка может быть длиннее
+	require.Nil(t, schema.Property("delivery.phone").MaxLength)
+}
@@ -0,0 +1,318 @@
+package validator
+
+import (
+	"fmt"
+	"math"
+	"order-back-end/internal/model"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Коды декларативных правил
+const (
+	RuleRange    = "range"     // число в границах min и max
+	RulePattern  = "pattern"   // строка подходит под регулярное выражение
+	RuleOneOf    = "one_of"    // значение из списка допустимых
+	RuleMaxItems = "max_items" // в массиве не больше max_items элементов
+)
+
+// RuleSpec правило для поля заказа из секции validation.rules конфига.
+// Проверки одного правила выполняются в порядке полей структуры, правила - в порядке объявления
+type RuleSpec struct {
+	// Field JSON путь к полю: order_uid, payment.amount; items[].price проверяет поле каждого товара
+	Field string `yaml:"field"`
+	// Required строка не пустая, значение другого типа не нулевое
+	Required    bool   `yaml:"required"`
+	Positive    bool   `yaml:"positive"`
+	NonNegative bool   `yaml:"non_negative"`
+	Min         *int64 `yaml:"min"`
+	Max         *int64 `yaml:"max"`
+	// Pattern регулярное выражение RE2 для строки, пустая строка не проверяется
+	Pattern string `yaml:"pattern"`
+	// OneOf допустимые значения строки, пустая строка не проверяется
+	OneOf    []string `yaml:"one_of"`
+	MinItems *int     `yaml:"min_items"`
+	MaxItems *int     `yaml:"max_items"`
+	// Message текст нарушения вместо стандартного
+	Message string `yaml:"message"`
+}
+
+// DefaultRules правила, которые действуют без секции validation.rules в конфиге
+func DefaultRules() []RuleSpec {
+	one := 1
+	return []RuleSpec{
+		{Field: "order_uid", Required: true},
+		{Field: "track_number", Required: true},
+		{Field: "entry", Required: true},
+		{Field: "customer_id", Required: true},
+		{Field: "delivery_service", Required: true},
+		{Field: "sm_id", Positive: true},
+
+		{Field: "delivery.name", Required: true},
+		{Field: "delivery.phone", Required: true},
+		{Field: "delivery.address", Required: true},
+		{Field: "delivery.city", Required: true},
+		{Field: "delivery.email", Required: true},
+
+		{Field: "payment.transaction", Required: true},
+		{Field: "payment.currency", Required: true},
+		{Field: "payment.provider", Required: true},
+		{Field: "payment.amount", Positive: true},
+
+		{Field: "items", MinItems: &one, Message: "at least one item is required"},
+		{Field: "items[].chrt_id", Positive: true},
+		{Field: "items[].track_number", Required: true},
+		{Field: "items[].name", Required: true},
+		{Field: "items[].price", Positive: true},
+		{Field: "items[].total_price", NonNegative: true},
+	}
+}
+
+// defaultRules скомпилированные DefaultRules
+var defaultRules = mustCompileRules(DefaultRules())
+
+// ruleSet скомпилированные правила
+type ruleSet []compiledRule
+
+// compiledRule правило с разобранным путём к полю и готовыми проверками
+type compiledRule struct {
+	steps  []fieldStep
+	checks []check
+}
+
+// fieldStep шаг пути: поле структуры, each - поле является массивом и проверяется каждый элемент
+type fieldStep struct {
+	name  string
+	index int
+	each  bool
+}
+
+// check проверка значения поля, path - JSON путь с индексами товаров
+type check func(r *report, path string, value reflect.Value)
+
+var orderType = reflect.TypeOf(model.OrderInfo{})
+
+// compileRules проверяет, что поля правил существуют и проверки подходят к их типам
+func compileRules(specs []RuleSpec) (ruleSet, error) {
+	rules := make(ruleSet, 0, len(specs))
+	for i, spec := range specs {
+		rule, err := compileRule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("validation rule %d (%s): %w", i, spec.Field, err)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func mustCompileRules(specs []RuleSpec) ruleSet {
+	rules, err := compileRules(specs)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+func compileRule(spec RuleSpec) (compiledRule, error) {
+	steps, typ, err := resolveField(spec.Field)
+	if err != nil {
+		return compiledRule{}, err
+	}
+	var rule compiledRule
+	rule.steps = steps
+	add := func(c check) { rule.checks = append(rule.checks, c) }
+	message := func(def string) func(path string) string {
+		if spec.Message != "" {
+			return func(string) string { return spec.Message }
+		}
+		return func(path string) string { return path + def }
+	}
+	isInt := typ.Kind() >= reflect.Int && typ.Kind() <= reflect.Int64
+	isString := typ.Kind() == reflect.String
+
+	if spec.Required {
+		msg := message(" is required")
+		add(func(r *report, path string, v reflect.Value) {
+			if v.IsZero() || (v.Kind() == reflect.String && strings.TrimSpace(v.String()) == "") ||
+				(v.Kind() == reflect.Slice && v.Len() == 0) {
+				r.add(path, RuleRequired, msg(path))
+			}
+		})
+	}
+
+	if (spec.Positive || spec.NonNegative || spec.Min != nil || spec.Max != nil) && !isInt {
+		return compiledRule{}, fmt.Errorf("positive, non_negative, min and max apply to integer fields, got %s", typ)
+	}
+	if spec.Positive {
+		msg := message(" must be > 0")
+		add(func(r *report, path string, v reflect.Value) {
+			if v.Int() <= 0 {
+				r.add(path, RulePositive, msg(path))
+			}
+		})
+	}
+	if spec.NonNegative {
+		msg := message(" must be >= 0")
+		add(func(r *report, path string, v reflect.Value) {
+			if v.Int() < 0 {
+				r.add(path, RuleNonNegative, msg(path))
+			}
+		})
+	}
+	if spec.Min != nil || spec.Max != nil {
+		lo, hi := int64(math.MinInt64), int64(math.MaxInt64)
+		var msg func(string) string
+		switch {
+		case spec.Min != nil && spec.Max != nil:
+			lo, hi = *spec.Min, *spec.Max
+			if lo > hi {
+				return compiledRule{}, fmt.Errorf("min %d is greater than max %d", lo, hi)
+			}
+			msg = message(fmt.Sprintf(" must be between %d and %d", lo, hi))
+		case spec.Min != nil:
+			lo = *spec.Min
+			msg = message(fmt.Sprintf(" must be >= %d", lo))
+		default:
+			hi = *spec.Max
+			msg = message(fmt.Sprintf(" must be <= %d", hi))
+		}
+		add(func(r *report, path string, v reflect.Value) {
+			if n := v.Int(); n < lo || n > hi {
+				r.add(path, RuleRange, msg(path))
+			}
+		})
+	}
+
+	if (spec.Pattern != "" || len(spec.OneOf) > 0) && !isString {
+		return compiledRule{}, fmt.Errorf("pattern and one_of apply to string fields, got %s", typ)
+	}
+	if spec.Pattern != "" {
+		re, err := regexp.Compile(spec.Pattern)
+		if err != nil {
+			return compiledRule{}, fmt.Errorf("pattern: %w", err)
+		}
+		msg := message(" does not match pattern " + spec.Pattern)
+		add(func(r *report, path string, v reflect.Value) {
+			if s := v.String(); s != "" && !re.MatchString(s) {
+				r.add(path, RulePattern, msg(path))
+			}
+		})
+	}
+	if len(spec.OneOf) > 0 {
+		allowed := slices.Clone(spec.OneOf)
+		msg := message(" must be one of " + strings.Join(allowed, ", "))
+		add(func(r *report, path string, v reflect.Value) {
+			if s := v.String(); s != "" && !slices.Contains(allowed, s) {
+				r.add(path, RuleOneOf, msg(path))
+			}
+		})
+	}
+
+	if (spec.MinItems != nil || spec.MaxItems != nil) && typ.Kind() != reflect.Slice {
+		return compiledRule{}, fmt.Errorf("min_items and max_items apply to arrays, got %s", typ)
+	}
+	if spec.MinItems != nil {
+		n := *spec.MinItems
+		msg := message(" must have at least " + strconv.Itoa(n) + " elements")
+		add(func(r *report, path string, v reflect.Value) {
+			if v.Len() < n {
+				r.add(path, RuleMinItems, msg(path))
+			}
+		})
+	}
+	if spec.MaxItems != nil {
+		n := *spec.MaxItems
+		msg := message(" must have at most " + strconv.Itoa(n) + " elements")
+		add(func(r *report, path string, v reflect.Value) {
+			if v.Len() > n {
+				r.add(path, RuleMaxItems, msg(path))
+			}
+		})
+	}
+
+	if len(rule.checks) == 0 {
+		return compiledRule{}, fmt.Errorf("no checks declared")
+	}
+	return rule, nil
+}
+
+// resolveField разбирает путь вида payment.amount или items[].price по JSON тегам модели
+func resolveField(field string) ([]fieldStep, reflect.Type, error) {
+	if field == "" {
+		return nil, nil, fmt.Errorf("field is required")
+	}
+	typ := orderType
+	var steps []fieldStep
+	for _, part := range strings.Split(field, ".") {
+		name, each := strings.CutSuffix(part, "[]")
+		if typ.Kind() != reflect.Struct {
+			return nil, nil, fmt.Errorf("%q is not an object", strings.Join(stepNames(steps), "."))
+		}
+		index := jsonField(typ, name)
+		if index < 0 {
+			return nil, nil, fmt.Errorf("unknown field %q", name)
+		}
+		typ = typ.Field(index).Type
+		if each {
+			if typ.Kind() != reflect.Slice {
+				return nil, nil, fmt.Errorf("%q is not an array", name)
+			}
+			typ = typ.Elem()
+		}
+		steps = append(steps, fieldStep{name: name, index: index, each: each})
+	}
+	return steps, typ, nil
+}
+
+// jsonField индекс поля структуры по JSON тегу
+func jsonField(typ reflect.Type, name string) int {
+	for i := range typ.NumField() {
+		tag, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		if tag == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func stepNames(steps []fieldStep) []string {
+	names := make([]string, len(steps))
+	for i, s := range steps {
+		names[i] = s.name
+	}
+	return names
+}
+
+// check применяет правила к заказу
+func (rs ruleSet) check(r *report, order *model.OrderInfo) {
+	root := reflect.ValueOf(order).Elem()
+	for _, rule := range rs {
+		rule.walk(r, root, rule.steps, "")
+	}
+}
+
+// walk спускается по пути к полю, для массивов - к полю каждого элемента
+func (rule compiledRule) walk(r *report, v reflect.Value, steps []fieldStep, path string) {
+	if len(steps) == 0 {
+		for _, c := range rule.checks {
+			c(r, path, v)
+		}
+		return
+	}
+	step := steps[0]
+	if path != "" {
+		path += "."
+	}
+	path += step.name
+	v = v.Field(step.index)
+	if !step.each {
+		rule.walk(r, v, steps[1:], path)
+		return
+	}
+	for i := range v.Len() {
+		rule.walk(r, v.Index(i), steps[1:], path+"["+strconv.Itoa(i)+"]")
+	}
+}
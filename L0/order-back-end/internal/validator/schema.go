@@ -0,0 +1,152 @@
+package validator
+
+import (
+	"order-back-end/internal/jsonschema"
+	"order-back-end/internal/model"
+	"reflect"
+	"strconv"
+)
+
+// SchemaID идентификатор схемы заказа, по нему схема отдаётся в API
+const SchemaID = "/schema/order"
+
+// Schema JSON Schema заказа: структура model.OrderInfo и действующие правила валидатора.
+// closed запрещает неизвестные свойства, как строгий разбор сообщений.
+// Согласованность сумм схемой не выражается и описана в description полей
+func (v *Validator) Schema(closed bool) *jsonschema.Schema {
+	s := v.load()
+	root := jsonschema.Generate(reflect.TypeOf(model.OrderInfo{}), closed)
+	root.Schema = jsonschema.Draft
+	root.ID = SchemaID
+	root.Title = "OrderInfo"
+
+	for _, spec := range s.specs {
+		applyRule(root, spec)
+	}
+
+	// проверки, которые заданы в коде
+	for path, n := range schemaMaxLengths() {
+		root.Property(path).MaxLength = ptr(n)
+	}
+	root.Property("date_created").Description = "RFC 3339, not later than 24 hours from now"
+	root.Require("date_created")
+	root.Property("payment.payment_dt").ExclusiveMinimum = ptr[int64](0)
+	root.Property("payment.payment_dt").Description = "unix timestamp, seconds"
+	root.Require("payment.payment_dt")
+	root.Property("delivery.email").Format = "email"
+	root.Property("delivery.phone").Description = "E.164, e.g. +79001234567"
+	if s.defaultRegion != "" {
+		root.Property("delivery.phone").Description += ", numbers without + are treated as " + s.defaultRegion
+	}
+	root.Property("delivery.zip").Description = "postal code"
+	if s.zipCountry != "" {
+		root.Property("delivery.zip").Description += " in the format of " + s.zipCountry
+	}
+	root.Property("payment.currency").Description = "ISO 4217 currency code"
+	root.Property("locale").Description = "ISO 639-1 language, optionally with a region: en, en-US"
+
+	sale := root.Property("items[].sale")
+	sale.Minimum, sale.Maximum = atLeast(sale.Minimum, 0), atMost(sale.Maximum, 100)
+	sale.Description = "discount percent"
+	root.Property("items[].total_price").Description = "price with sale percent discount"
+	root.Property("items[].track_number").Description = "equals order track_number"
+	root.Property("payment.goods_total").Description = "sum of items total_price"
+	root.Property("payment.amount").Description = "goods_total + delivery_cost + custom_fee"
+	if s.tolerance > 0 {
+		for _, path := range []string{"items[].total_price", "payment.goods_total", "payment.amount"} {
+			root.Property(path).Description += ", ±" + strconv.Itoa(s.tolerance)
+		}
+	}
+	return root
+}
+
+// nonBlank строка содержит что-то кроме пробелов: required обрезает пробелы
+const nonBlank = `\S`
+
+// applyRule переносит декларативное правило в схему свойства. Нулевое значение не проходит
+// positive и min_items, поэтому такие свойства тоже обязательны
+func applyRule(root *jsonschema.Schema, spec RuleSpec) {
+	p := root.Property(spec.Field)
+	if p == nil {
+		return
+	}
+	if spec.Required || spec.Positive || (spec.MinItems != nil && *spec.MinItems > 0) {
+		root.Require(spec.Field)
+	}
+	if spec.Required {
+		switch p.Type {
+		case "string":
+			p.MinLength = ptr(1)
+			constrain(p, &jsonschema.Schema{Pattern: nonBlank})
+		case "array":
+			p.MinItems = atLeast(p.MinItems, 1)
+		}
+	}
+	if spec.Positive {
+		p.ExclusiveMinimum = ptr[int64](0)
+	}
+	if spec.NonNegative {
+		p.Minimum = atLeast(p.Minimum, 0)
+	}
+	if spec.Min != nil {
+		p.Minimum = atLeast(p.Minimum, *spec.Min)
+	}
+	if spec.Max != nil {
+		p.Maximum = atMost(p.Maximum, *spec.Max)
+	}
+	if spec.Pattern != "" {
+		constrain(p, orEmpty(&jsonschema.Schema{Pattern: spec.Pattern}, spec.Required))
+	}
+	if len(spec.OneOf) > 0 {
+		constrain(p, orEmpty(&jsonschema.Schema{Enum: spec.OneOf}, spec.Required))
+	}
+	if spec.MinItems != nil {
+		p.MinItems = atLeast(p.MinItems, *spec.MinItems)
+	}
+	if spec.MaxItems != nil {
+		p.MaxItems = atMost(p.MaxItems, *spec.MaxItems)
+	}
+}
+
+// orEmpty pattern и one_of пустую строку не проверяют: если поле не обязательное, она тоже подходит
+func orEmpty(c *jsonschema.Schema, required bool) *jsonschema.Schema {
+	if required {
+		return c
+	}
+	return &jsonschema.Schema{AnyOf: []*jsonschema.Schema{c, {Const: ptr("")}}}
+}
+
+// constrain добавляет к схеме свойства ограничение из pattern, enum или anyOf: в саму схему,
+// если такое ключевое слово в ней ещё не занято, иначе в allOf
+func constrain(p, c *jsonschema.Schema) {
+	switch {
+	case c.Pattern != "" && p.Pattern == "":
+		p.Pattern = c.Pattern
+	case c.Enum != nil && p.Enum == nil:
+		p.Enum = c.Enum
+	case c.AnyOf != nil && p.AnyOf == nil:
+		p.AnyOf = c.AnyOf
+	default:
+		p.AllOf = append(p.AllOf, c)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+// atLeast более строгая из нижних границ: текущей и n
+func atLeast[T int | int64](bound *T, n T) *T {
+	if bound != nil && *bound > n {
+		return bound
+	}
+	return &n
+}
+
+// atMost более строгая из верхних границ: текущей и n
+func atMost[T int | int64](bound *T, n T) *T {
+	if bound != nil && *bound < n {
+		return bound
+	}
+	return &n
+}
@@ -0,0 +1,118 @@
+package validator_test
+
+import (
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"order-back-end/internal/jsonschema"
+	"order-back-end/internal/model"
+	"order-back-end/internal/validator"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatorSchema(t *testing.T) {
+	schema := (&validator.Validator{}).Schema(false)
+	require.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema.Schema)
+	require.Equal(t, validator.SchemaID, schema.ID)
+	require.Nil(t, schema.AdditionalProperties)
+	require.Subset(t, schema.Required, []string{"order_uid", "delivery", "payment", "items", "sm_id", "date_created"})
+	require.NotContains(t, schema.Required, "locale")
+
+	require.Equal(t, "string", schema.Property("order_uid").Type)
+	require.Equal(t, 1, *schema.Property("order_uid").MinLength)
+	require.Equal(t, "date-time", schema.Property("date_created").Format)
+	require.Equal(t, "email", schema.Property("delivery.email").Format)
+	require.Equal(t, int64(0), *schema.Property("payment.amount").ExclusiveMinimum)
+	require.Equal(t, 1, *schema.Property("items").MinItems)
+	require.Equal(t, int64(100), *schema.Property("items[].sale").Maximum)
+	require.Contains(t, schema.Property("items").Items.Required, "price")
+}
+
+func TestValidatorSchemaFollowsRules(t *testing.T) {
+	limit, count := int64(5000), 10
+	rules := append(validator.DefaultRules(),
+		validator.RuleSpec{Field: "delivery_service", OneOf: []string{"DHL", "CDEK"}},
+		validator.RuleSpec{Field: "payment.amount", Max: &limit},
+		validator.RuleSpec{Field: "items", MaxItems: &count},
+		validator.RuleSpec{Field: "items[].rid", Pattern: `^[a-z0-9]+$`},
+	)
+	v := newValidator(t, validator.Config{Rules: rules})
+
+	schema := v.Schema(true)
+	require.False(t, *schema.AdditionalProperties)
+	require.False(t, *schema.Property("items").Items.AdditionalProperties)
+	empty := ""
+	require.Equal(t, []*jsonschema.Schema{{Enum: []string{"DHL", "CDEK"}}, {Const: &empty}}, schema.Property("delivery_service").AnyOf)
+	require.Equal(t, int64(5000), *schema.Property("payment.amount").Maximum)
+	require.Equal(t, 10, *schema.Property("items").MaxItems)
+	require.Equal(t, []*jsonschema.Schema{{Pattern: `^[a-z0-9]+$`}, {Const: &empty}}, schema.Property("items[].rid").AnyOf)
+
+	// схема строится по действующим правилам и меняется после перезагрузки
+	require.NoError(t, v.Reload(validator.Config{}))
+	require.Empty(t, v.Schema(true).Property("delivery_service").AnyOf)
+}
+
+// acceptsString проверяет строку по строковым ключевым словам схемы, которые выдаёт Schema
+func acceptsString(t *testing.T, s *jsonschema.Schema, v string) bool {
+	t.Helper()
+	if s.MinLength != nil && len([]rune(v)) < *s.MinLength || s.MaxLength != nil && len([]rune(v)) > *s.MaxLength {
+		return false
+	}
+	if s.Pattern != "" && !regexp.MustCompile(s.Pattern).MatchString(v) {
+		return false
+	}
+	if s.Enum != nil && !slices.Contains(s.Enum, v) || s.Const != nil && *s.Const != v {
+		return false
+	}
+	for _, sub := range s.AllOf {
+		if !acceptsString(t, sub, v) {
+			return false
+		}
+	}
+	if s.AnyOf == nil {
+		return true
+	}
+	for _, sub := range s.AnyOf {
+		if acceptsString(t, sub, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSchemaAgreesWithValidator(t *testing.T) {
+	rules := append(validator.DefaultRules(),
+		validator.RuleSpec{Field: "delivery_service", OneOf: []string{"DHL", "CDEK"}},
+		validator.RuleSpec{Field: "payment.bank", OneOf: []string{"alpha", "sber"}},
+		validator.RuleSpec{Field: "payment.provider", Required: true, Pattern: `^[a-z]+$`},
+		validator.RuleSpec{Field: "items[].rid", Pattern: `^[a-z0-9]+$`},
+	)
+	v := newValidator(t, validator.Config{Mode: validator.ModeLenient, Rules: rules})
+	schema := v.Schema(true)
+
+	fields := map[string]func(o *model.OrderInfo) *string{
+		"order_uid":        func(o *model.OrderInfo) *string { return &o.OrderUID },
+		"delivery_service": func(o *model.OrderInfo) *string { return &o.DeliveryService },
+		"payment.bank":     func(o *model.OrderInfo) *string { return &o.Payment.Bank },
+		"payment.provider": func(o *model.OrderInfo) *string { return &o.Payment.Provider },
+		"items[].rid":      func(o *model.OrderInfo) *string { return &o.Items[0].RID },
+	}
+	values := []string{"", "   ", "DHL", "dhl", "alpha", "abc123", "ABC", " wbpay "}
+
+	for path, field := range fields {
+		for _, value := range values {
+			order := makeConsistentOrder()
+			*field(order) = value
+			_, err := v.Validate(order)
+			var verr *validator.ValidationError
+			valid := !errors.As(err, &verr) || !slices.ContainsFunc(verr.Violations, func(violation validator.Violation) bool {
+				return violation.Path == strings.Replace(path, "[]", "[0]", 1)
+			})
+			require.Equal(t, valid, acceptsString(t, schema.Property(path), value), "%s = %q", path, value)
+		}
+	}
+}
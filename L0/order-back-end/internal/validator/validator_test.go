@@ -2,12 +2,15 @@ package validator_test
 
 import (
 	"encoding/json"
+	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"order-back-end/internal/model"
 	"order-back-end/internal/validator"
 
+	"github.com/ilyakaznacheev/cleanenv"
 	"github.com/stretchr/testify/require"
 )
 
@@ -46,132 +49,286 @@ func makeValidOrder() *model.OrderInfo {
 	}
 }
 
-func TestValidateOrderInfo_Valid(t *testing.T) {
-	order := makeValidOrder()
-	data, _ := json.Marshal(order)
+// requireViolation проверяет, что среди нарушений есть нарушение правила rule в поле path
+func requireViolation(t *testing.T, err error, path, rule, message string) {
+	t.Helper()
+	var verr *validator.ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Contains(t, verr.Violations, validator.Violation{Path: path, Rule: rule, Message: message})
+}
 
-	var parsed model.OrderInfo
-	err := validator.ValidateOrderInfo(data, &parsed)
-	require.NoError(t, err, "valid order must pass validation")
+// validateFields проверяет заказ настроенным валидатором в мягком режиме: бизнес-правила заказ не отклоняют,
+// и ошибка содержит только нарушения правил полей и форматов
+func validateFields(t *testing.T, order *model.OrderInfo) error {
+	t.Helper()
+	_, err := newValidator(t, validator.Config{Mode: validator.ModeLenient}).Validate(order)
+	return err
 }
 
-func TestValidateOrderInfo_InvalidJSON(t *testing.T) {
-	var parsed model.OrderInfo
-	err := validator.ValidateOrderInfo([]byte("{invalid json}"), &parsed)
-	require.Error(t, err)
-	require.Contains(t, err.Error(), "invalid JSON")
+func TestValidatorFields_Valid(t *testing.T) {
+	order := makeConsistentOrder()
+	err := validateFields(t, order)
+	require.NoError(t, err, "valid order must pass validation")
 }
 
-func TestValidateOrderInfo_MissingFields(t *testing.T) {
+func TestValidatorFields_MissingFields(t *testing.T) {
 	// пример: убираем OrderUID
-	order := makeValidOrder()
+	order := makeConsistentOrder()
 	order.OrderUID = ""
-	data, _ := json.Marshal(order)
-
-	var parsed model.OrderInfo
-	err := validator.ValidateOrderInfo(data, &parsed)
+	err := validateFields(t, order)
 	require.Error(t, err)
 	require.Equal(t, "order_uid is required", err.Error())
 }
 
-func TestValidateOrderInfo_InvalidPayment(t *testing.T) {
-	order := makeValidOrder()
+func TestValidatorFields_InvalidPayment(t *testing.T) {
+	order := makeConsistentOrder()
 	order.Payment.Amount = 0
-	data, _ := json.Marshal(order)
-
-	var parsed model.OrderInfo
-	err := validator.ValidateOrderInfo(data, &parsed)
+	err := validateFields(t, order)
 	require.Error(t, err)
 	require.Equal(t, "payment.amount must be > 0", err.Error())
 }
 
-func TestValidateOrderInfo_NoItems(t *testing.T) {
-	order := makeValidOrder()
+func TestValidatorFields_NoItems(t *testing.T) {
+	order := makeConsistentOrder()
 	order.Items = []model.Item{}
-	data, _ := json.Marshal(order)
-
-	var parsed model.OrderInfo
-	err := validator.ValidateOrderInfo(data, &parsed)
+	err := validateFields(t, order)
 	require.Error(t, err)
 	require.Equal(t, "at least one item is required", err.Error())
 }
 
-func TestValidateOrderInfo_InvalidTrackNumber(t *testing.T) {
-	order := model.OrderInfo{
-		OrderUID:    "123",
-		TrackNumber: "",
-	}
-
-	data, _ := json.Marshal(order)
+func TestValidatorFields_InvalidTrackNumber(t *testing.T) {
+	order := makeConsistentOrder()
+	order.TrackNumber = ""
 
-	var parsed model.OrderInfo
-	err := validator.ValidateOrderInfo(data, &parsed)
+	err := validateFields(t, order)
 	require.Error(t, err)
 	require.Equal(t, "track_number is required", err.Error())
 }
 
-func TestValidateOrderInfo_InvalidEntry(t *testing.T) {
-	order := model.OrderInfo{
-		OrderUID:    "123",
-		TrackNumber: "TRACK123",
-		Entry:       "",
-	}
-
-	data, _ := json.Marshal(order)
+func TestValidatorFields_InvalidEntry(t *testing.T) {
+	order := makeConsistentOrder()
+	order.Entry = ""
 
-	var parsed model.OrderInfo
-	err := validator.ValidateOrderInfo(data, &parsed)
+	err := validateFields(t, order)
 	require.Error(t, err)
 	require.Equal(t, "entry is required", err.Error())
 }
 
-func TestValidateOrderInfo_InvalidCustomerID(t *testing.T) {
-	order := model.OrderInfo{
-		OrderUID:    "123",
-		TrackNumber: "TRACK123",
-		Entry:       "Entry",
-		CustomerID:  "",
-	}
-
-	data, _ := json.Marshal(order)
+func TestValidatorFields_InvalidCustomerID(t *testing.T) {
+	order := makeConsistentOrder()
+	order.CustomerID = ""
 
-	var parsed model.OrderInfo
-	err := validator.ValidateOrderInfo(data, &parsed)
+	err := validateFields(t, order)
 	require.Error(t, err)
 	require.Equal(t, "customer_id is required", err.Error())
 }
 
-func TestValidateOrderInfo_InvalidDeliveryService(t *testing.T) {
-	order := model.OrderInfo{
-		OrderUID:        "123",
-		TrackNumber:     "TRACK123",
-		Entry:           "Entry",
-		CustomerID:      "29384",
-		DeliveryService: "",
-	}
-
-	data, _ := json.Marshal(order)
+func TestValidatorFields_InvalidDeliveryService(t *testing.T) {
+	order := makeConsistentOrder()
+	order.DeliveryService = ""
 
-	var parsed model.OrderInfo
-	err := validator.ValidateOrderInfo(data, &parsed)
+	err := validateFields(t, order)
 	require.Error(t, err)
 	require.Equal(t, "delivery_service is required", err.Error())
 }
 
-func TestValidateOrderInfo_InvalidSmID(t *testing.T) {
-	order := model.OrderInfo{
-		OrderUID:        "123",
-		TrackNumber:     "TRACK123",
-		Entry:           "Entry",
-		CustomerID:      "29384",
-		DeliveryService: "delivery-service",
-		SmID:            0,
-	}
-
-	data, _ := json.Marshal(order)
+func TestValidatorFields_InvalidSmID(t *testing.T) {
+	order := makeConsistentOrder()
+	order.SmID = 0
 
-	var parsed model.OrderInfo
-	err := validator.ValidateOrderInfo(data, &parsed)
+	err := validateFields(t, order)
 	require.Error(t, err)
 	require.Equal(t, "sm_id must be > 0", err.Error())
 }
+
+func TestValidatorFields_ViolationRules(t *testing.T) {
+	tests := []struct {
+		clear func(o *model.OrderInfo)
+		want  validator.Violation
+	}{
+		{clear: func(o *model.OrderInfo) { o.TrackNumber = "" }, want: validator.Violation{Path: "track_number", Rule: validator.RuleRequired, Message: "track_number is required"}},
+		{clear: func(o *model.OrderInfo) { o.Entry = "" }, want: validator.Violation{Path: "entry", Rule: validator.RuleRequired, Message: "entry is required"}},
+		{clear: func(o *model.OrderInfo) { o.CustomerID = "" }, want: validator.Violation{Path: "customer_id", Rule: validator.RuleRequired, Message: "customer_id is required"}},
+		{clear: func(o *model.OrderInfo) { o.DeliveryService = "" }, want: validator.Violation{Path: "delivery_service", Rule: validator.RuleRequired, Message: "delivery_service is required"}},
+		{clear: func(o *model.OrderInfo) { o.SmID = 0 }, want: validator.Violation{Path: "sm_id", Rule: validator.RulePositive, Message: "sm_id must be > 0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want.Path, func(t *testing.T) {
+			order := makeConsistentOrder()
+			tt.clear(order)
+			err := validateFields(t, order)
+			var verr *validator.ValidationError
+			require.ErrorAs(t, err, &verr)
+			require.Equal(t, []validator.Violation{tt.want}, verr.Violations)
+		})
+	}
+}
+
+func TestValidatorFields_ReportsAllViolations(t *testing.T) {
+	order := makeConsistentOrder()
+	order.OrderUID = " "
+	order.Payment.Amount = -5
+	order.Items = append(order.Items, model.Item{ChrtID: 2, TrackNumber: "TRACK123", Name: "Item2", TotalPrice: -1})
+
+	err := validateFields(t, order)
+	var verr *validator.ValidationError
+	require.ErrorAs(t, err, &verr)
+	require.Equal(t, []validator.Violation{
+		{Path: "order_uid", Rule: validator.RuleRequired, Message: "order_uid is required"},
+		{Path: "payment.amount", Rule: validator.RulePositive, Message: "payment.amount must be > 0"},
+		{Path: "items[1].price", Rule: validator.RulePositive, Message: "items[1].price must be > 0"},
+		{Path: "items[1].total_price", Rule: validator.RuleNonNegative, Message: "items[1].total_price must be >= 0"},
+	}, verr.Violations)
+	require.Equal(t, "order_uid is required; payment.amount must be > 0; items[1].price must be > 0; "+
+		"items[1].total_price must be >= 0", err.Error())
+}
+
+func TestValidatorFields_ItemPathsUseDecimalIndex(t *testing.T) {
+	order := makeConsistentOrder()
+	for range 11 {
+		order.Items = append(order.Items, order.Items[0])
+	}
+	order.Items[11].Name = ""
+
+	requireViolation(t, validateFields(t, order), "items[11].name", validator.RuleRequired, "items[11].name is required")
+}
+
+func TestValidatorRules(t *testing.T) {
+	limit := func(n int64) *int64 { return &n }
+	count := func(n int) *int { return &n }
+
+	tests := []struct {
+		name   string
+		rule   validator.RuleSpec
+		mutate func(order *model.OrderInfo)
+		want   *validator.Violation
+	}{
+		{
+			name:   "required",
+			rule:   validator.RuleSpec{Field: "delivery.region", Required: true},
+			mutate: func(o *model.OrderInfo) { o.Delivery.Region = " " },
+			want:   &validator.Violation{Path: "delivery.region", Rule: validator.RuleRequired, Message: "delivery.region is required"},
+		},
+		{
+			name:   "range",
+			rule:   validator.RuleSpec{Field: "payment.amount", Min: limit(100), Max: limit(500)},
+			mutate: func(o *model.OrderInfo) { o.Payment.Amount = 1000 },
+			want:   &validator.Violation{Path: "payment.amount", Rule: validator.RuleRange, Message: "payment.amount must be between 100 and 500"},
+		},
+		{
+			name:   "max only",
+			rule:   validator.RuleSpec{Field: "items[].sale", Max: limit(50)},
+			mutate: func(o *model.OrderInfo) { o.Items[0].Sale = 70 },
+			want:   &validator.Violation{Path: "items[0].sale", Rule: validator.RuleRange, Message: "items[0].sale must be <= 50"},
+		},
+		{
+			name:   "pattern",
+			rule:   validator.RuleSpec{Field: "track_number", Pattern: `^[A-Z]+\d+$`},
+			mutate: func(o *model.OrderInfo) { o.TrackNumber = "track-1" },
+			want:   &validator.Violation{Path: "track_number", Rule: validator.RulePattern, Message: `track_number does not match pattern ^[A-Z]+\d+$`},
+		},
+		{
+			name:   "one of",
+			rule:   validator.RuleSpec{Field: "delivery_service", OneOf: []string{"DHL", "CDEK"}},
+			mutate: func(o *model.OrderInfo) { o.DeliveryService = "Boxberry" },
+			want:   &validator.Violation{Path: "delivery_service", Rule: validator.RuleOneOf, Message: "delivery_service must be one of DHL, CDEK"},
+		},
+		{
+			name:   "one of accepts listed value",
+			rule:   validator.RuleSpec{Field: "delivery_service", OneOf: []string{"DHL", "CDEK"}},
+			mutate: func(o *model.OrderInfo) {},
+		},
+		{
+			name:   "max items with message",
+			rule:   validator.RuleSpec{Field: "items", MaxItems: count(1), Message: "too many items"},
+			mutate: func(o *model.OrderInfo) { o.Items = append(o.Items, o.Items[0]) },
+			want:   &validator.Violation{Path: "items", Rule: validator.RuleMaxItems, Message: "too many items"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := makeConsistentOrder()
+			tt.mutate(order)
+
+			v := newValidator(t, validator.Config{Rules: []validator.RuleSpec{tt.rule}})
+			_, err := v.Validate(order)
+			if tt.want == nil {
+				require.NoError(t, err)
+				return
+			}
+			requireViolation(t, err, tt.want.Path, tt.want.Rule, tt.want.Message)
+		})
+	}
+}
+
+func TestValidatorRulesReplaceDefaults(t *testing.T) {
+	order := makeConsistentOrder()
+	order.CustomerID = ""
+
+	_, err := newValidator(t, validator.Config{}).Validate(order)
+	requireViolation(t, err, "customer_id", validator.RuleRequired, "customer_id is required")
+
+	_, err = newValidator(t, validator.Config{Rules: []validator.RuleSpec{}}).Validate(order)
+	require.NoError(t, err)
+}
+
+func TestValidatorReload(t *testing.T) {
+	order := makeConsistentOrder()
+	v := newValidator(t, validator.Config{})
+	_, err := v.Validate(order)
+	require.NoError(t, err)
+
+	rules := append(validator.DefaultRules(), validator.RuleSpec{Field: "delivery_service", OneOf: []string{"CDEK"}})
+	require.NoError(t, v.Reload(validator.Config{Rules: rules}))
+	_, err = v.Validate(order)
+	requireViolation(t, err, "delivery_service", validator.RuleOneOf, "delivery_service must be one of CDEK")
+
+	// неверные правила не заменяют действующие
+	require.Error(t, v.Reload(validator.Config{Rules: []validator.RuleSpec{{Field: "delivery_service", Pattern: "("}}}))
+	_, err = v.Validate(order)
+	requireViolation(t, err, "delivery_service", validator.RuleOneOf, "delivery_service must be one of CDEK")
+}
+
+func TestNewValidatorRejectsInvalidRules(t *testing.T) {
+	limit := int64(1)
+	for _, rule := range []validator.RuleSpec{
+		{Field: "unknown", Required: true},
+		{Field: "items.price", Positive: true},
+		{Field: "order_uid", Positive: true},
+		{Field: "sm_id", Pattern: "^1$"},
+		{Field: "order_uid", MaxItems: new(int)},
+		{Field: "payment.amount", Min: &limit, Max: new(int64)},
+		{Field: "order_uid"},
+	} {
+		_, err := validator.New(validator.Config{Rules: []validator.RuleSpec{rule}})
+		require.Error(t, err, rule.Field)
+	}
+}
+
+// TestValidatorAcceptsReadmeOrder эталонный заказ из README проходит проверку с настройками из configs/config.yaml
+func TestValidatorAcceptsReadmeOrder(t *testing.T) {
+	var cfg struct {
+		Validation validator.Config `yaml:"validation"`
+	}
+	require.NoError(t, cleanenv.ReadConfig("../../configs/config.yaml", &cfg))
+
+	readme, err := os.ReadFile("../../../README.md")
+	require.NoError(t, err)
+	var sample string
+	for _, block := range strings.Split(string(readme), "```json\n")[1:] {
+		block, _, _ = strings.Cut(block, "```")
+		if strings.Contains(block, `"order_uid": "123456"`) {
+			sample = block
+			break
+		}
+	}
+	require.NotEmpty(t, sample, "README has no sample order")
+
+	var order model.OrderInfo
+	require.NoError(t, json.Unmarshal([]byte(sample), &order))
+	_, err = newValidator(t, cfg.Validation).Validate(&order)
+	require.NoError(t, err)
+	require.Equal(t, "+9720000000", order.Delivery.Phone)
+}
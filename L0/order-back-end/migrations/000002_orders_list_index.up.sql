@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_orders_date_created_uid ON orders (date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders (customer_id);
+CREATE INDEX IF NOT EXISTS idx_payments_order_uid ON payments (order_uid);
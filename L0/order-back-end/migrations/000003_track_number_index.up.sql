@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders (track_number);
+CREATE INDEX IF NOT EXISTS idx_items_track_number ON items (track_number);
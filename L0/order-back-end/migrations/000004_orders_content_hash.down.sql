@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_items_order_uid;
+CREATE INDEX IF NOT EXISTS idx_payments_order_uid ON payments (order_uid);
+DROP INDEX IF EXISTS uq_payments_order_uid;
+ALTER TABLE orders DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,4 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS content_hash CHAR(64);
+CREATE UNIQUE INDEX IF NOT EXISTS uq_payments_order_uid ON payments (order_uid);
+DROP INDEX IF EXISTS idx_payments_order_uid;
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items (order_uid);